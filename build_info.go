@@ -0,0 +1,25 @@
+package main
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newBuildInfoCollector returns a collector exporting a constant metric
+// labeled with the build information of the running binary, how TLS
+// certificates are verified, making insecure setups visible, and how host
+// names are resolved.
+func newBuildInfoCollector(tlsMode, resolver string) prometheus.Collector {
+	buildInfo := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "build_info",
+			Help:      "A metric with a constant '1' value labeled by version, revision and goversion from which fastcom-exporter was built, tls verification mode and dns resolver",
+		},
+		[]string{"version", "revision", "goversion", "tls", "resolver"},
+	)
+	buildInfo.WithLabelValues(version, commit, runtime.Version(), tlsMode, resolver).Set(1)
+	return buildInfo
+}
@@ -2,10 +2,18 @@ module github.com/caarlos0/fastcom-exporter
 
 require (
 	github.com/alecthomas/kingpin v2.2.6+incompatible
+	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d
+	github.com/cespare/xxhash/v2 v2.1.1
+	github.com/oschwald/maxminddb-golang v1.8.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/common v0.26.0
 	github.com/rs/zerolog v1.23.0
+	golang.org/x/mod v0.4.2
+	golang.org/x/net v0.0.0-20210525063256-abc453219eb5
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
+	golang.org/x/sys v0.0.0-20210603081109-ebe580a85c40
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 go 1.16
@@ -0,0 +1,115 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed grafana/dashboard.json.tmpl
+var dashboardTemplateSource string
+
+// nolint: gochecknoglobals
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		bts, err := json.Marshal(v)
+		return string(bts), err
+	},
+}).Parse(dashboardTemplateSource))
+
+type dashboardPanel struct {
+	ID    int
+	Title string
+	Expr  string
+	Unit  string
+	X, Y  int
+}
+
+type dashboard struct {
+	Variables []string
+	Panels    []dashboardPanel
+}
+
+// newDashboard returns a dashboard with panels for the metrics the given
+// configuration exports, filtered by the instance and the static labels.
+func newDashboard(opts collector.Options, labels map[string]string) dashboard {
+	variables := []string{"instance"}
+	for name := range labels {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables[1:])
+
+	matchers := make([]string, 0, len(variables))
+	for _, name := range variables {
+		matchers = append(matchers, fmt.Sprintf(`%s=~"$%s"`, name, name))
+	}
+	selector := "{" + strings.Join(matchers, ",") + "}"
+
+	var panels []dashboardPanel
+	add := func(title, expr, unit string) {
+		i := len(panels)
+		panels = append(panels, dashboardPanel{
+			ID:    i + 1,
+			Title: title,
+			Expr:  expr,
+			Unit:  unit,
+			X:     (i % 2) * 12,
+			Y:     (i / 2) * 8,
+		})
+	}
+
+	add("Download speed", "fastcom_download_bytes_second"+selector, "Bps")
+	if opts.Upload != nil {
+		add("Upload speed", "fastcom_upload_bytes_second"+selector, "Bps")
+	}
+	if opts.Continuous != nil {
+		add("Continuous download speed estimate", "fastcom_continuous_download_bytes_second"+selector, "Bps")
+	}
+	add(
+		"Daily download speed median",
+		"histogram_quantile(0.5, sum by (instance, le) (increase(fastcom_download_measurements_bytes_second_bucket"+selector+"[1d])))",
+		"Bps",
+	)
+	if opts.Latency != nil {
+		add("Idle latency", "fastcom_idle_latency_seconds"+selector, "s")
+		add("Idle jitter", "fastcom_idle_jitter_seconds"+selector, "s")
+	}
+	add("Up", "fastcom_up"+selector, "none")
+	add("CPU limited", "fastcom_cpu_limited"+selector, "none")
+	if opts.Duplex && opts.Upload != nil {
+		add("Loaded latency", "fastcom_loaded_latency_seconds"+selector, "s")
+	}
+	if opts.Measure.TCPInfo {
+		add("TCP retransmissions", "fastcom_tcp_retransmit_ratio"+selector, "percentunit")
+		add("TCP round trip time", "fastcom_tcp_rtt_seconds"+selector, "s")
+	}
+	if opts.CaptivePortal != nil {
+		add("Captive portal detected", "fastcom_captive_portal_detected"+selector, "none")
+	}
+	if opts.Measure.Traceroute {
+		add("Hops to test server", "fastcom_path_hops"+selector, "none")
+		add("First hop latency", "fastcom_path_first_hop_latency_seconds"+selector, "s")
+	}
+	add("Scrape duration", "fastcom_scrape_duration_seconds"+selector, "s")
+
+	return dashboard{
+		Variables: variables,
+		Panels:    panels,
+	}
+}
+
+func dashboardHandler(d dashboard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := dashboardTemplate.Execute(w, d); err != nil {
+			log.Error().Err(err).Msg("failed to render grafana dashboard")
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+)
+
+// nolint: gochecknoglobals
+var importers = map[string]func(io.Reader) ([]history.Entry, error){
+	"speedtest-cli-csv": importSpeedtestCSV,
+	"json":              importSpeedtestJSON,
+}
+
+// importSpeedtestCSV reads the output of speedtest-cli --csv, with or without
+// the --csv-header line.
+// Speeds are in bits per second.
+func importSpeedtestCSV(r io.Reader) ([]history.Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []history.Entry
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == "Server ID" {
+			continue
+		}
+		// Server ID,Sponsor,Server Name,Timestamp,Distance,Ping,Download,Upload,...
+		if len(record) < 8 {
+			return nil, fmt.Errorf("line %d: expected at least 8 fields, got %d", i+1, len(record))
+		}
+		t, err := time.Parse(time.RFC3339Nano, record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		download, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid download: %w", i+1, err)
+		}
+		upload, err := strconv.ParseFloat(record[7], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid upload: %w", i+1, err)
+		}
+		entries = append(entries, importedEntry("speedtest-cli", t, download, upload))
+	}
+	return entries, nil
+}
+
+type speedtestResult struct {
+	Download  float64   `json:"download"`
+	Upload    float64   `json:"upload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// importSpeedtestJSON reads the output of speedtest-cli --json, one result per
+// line, or an array of them.
+// Speeds are in bits per second.
+func importSpeedtestJSON(r io.Reader) ([]history.Entry, error) {
+	var entries []history.Entry
+	dec := json.NewDecoder(r)
+	for i := 1; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("result %d: %w", i, err)
+		}
+		var results []speedtestResult
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			if err := json.Unmarshal(raw, &results); err != nil {
+				return nil, fmt.Errorf("result %d: %w", i, err)
+			}
+		} else {
+			var result speedtestResult
+			if err := json.Unmarshal(raw, &result); err != nil {
+				return nil, fmt.Errorf("result %d: %w", i, err)
+			}
+			results = append(results, result)
+		}
+		for _, result := range results {
+			if result.Timestamp.IsZero() {
+				return nil, fmt.Errorf("result %d: missing timestamp", i)
+			}
+			entries = append(entries, importedEntry("speedtest-cli", result.Timestamp, result.Download, result.Upload))
+		}
+	}
+}
+
+// importedEntry returns an entry with speeds converted from bits to bytes per
+// second, and an ID derived from the source and time so importing the same
+// file twice doesn't duplicate it.
+func importedEntry(source string, t time.Time, downloadBits, uploadBits float64) history.Entry {
+	return history.Entry{
+		ID:            fmt.Sprintf("%s-%d", source, t.UnixNano()),
+		Time:          t,
+		DownloadSpeed: float64(units.FromBitsPerSecond(downloadBits)),
+		UploadSpeed:   float64(units.FromBitsPerSecond(uploadBits)),
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// nolint: gochecknoglobals
+var (
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "fastcom",
+		Subsystem: "exporter",
+		Name:      "http_requests_in_flight",
+		Help:      "Current number of HTTP requests being served",
+	})
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests, by handler",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60},
+		},
+		[]string{"handler", "code", "method"},
+	)
+	httpResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP responses, by handler",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 6),
+		},
+		[]string{"handler", "code", "method"},
+	)
+)
+
+// newRegistry returns a registry with the exporter own metrics, along with
+// the Go runtime and process metrics if enabled.
+// Measurement metrics are registered per scrape, see metricsHandler.
+func newRegistry(goMetrics, processMetrics bool, tlsMode, resolver string) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newBuildInfoCollector(tlsMode, resolver), httpInFlight, httpDuration, httpResponseSize)
+	if goMetrics {
+		registry.MustRegister(prometheus.NewGoCollector())
+	}
+	if processMetrics {
+		registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+	return registry
+}
+
+// instrument wraps the given handler with in-flight, duration and response
+// size metrics, labeled with the given handler name.
+func instrument(name string, handler http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerInFlight(
+		httpInFlight,
+		promhttp.InstrumentHandlerDuration(
+			httpDuration.MustCurryWith(labels),
+			promhttp.InstrumentHandlerResponseSize(
+				httpResponseSize.MustCurryWith(labels),
+				handler,
+			),
+		),
+	)
+}
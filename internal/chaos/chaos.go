@@ -0,0 +1,154 @@
+// Package chaos injects faults into HTTP requests, so the way the exporter
+// copes with discovery failures, slow responses and connection resets can be
+// checked end to end, along with the metrics they show up in.
+package chaos
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Fault is a kind of fault injected into a request.
+type Fault string
+
+const (
+	// DiscoveryFailure fails requests to fast.com itself.
+	DiscoveryFailure Fault = "discovery-failure"
+	// SlowResponse delays the response, then reads its body slowly.
+	SlowResponse Fault = "slow-response"
+	// ConnectionReset resets the connection while reading the response
+	// body.
+	ConnectionReset Fault = "connection-reset"
+)
+
+const (
+	// maxDelay is the maximum delay of slow responses.
+	maxDelay = 5 * time.Second
+	// slowReadDelay is how long each read of the body of slow responses
+	// waits.
+	slowReadDelay = 10 * time.Millisecond
+	// maxResetBytes is the maximum amount of bytes read before a
+	// connection is reset.
+	maxResetBytes = 8 << 20
+)
+
+// Transport is an http.RoundTripper injecting a fault into requests with
+// the given probability.
+type Transport struct {
+	base  http.RoundTripper
+	rate  float64
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// New wraps base, defaulting to http.DefaultTransport, injecting faults into
+// a rate, from 0 to 1, of the requests.
+func New(base http.RoundTripper, rate float64) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base: base,
+		rate: rate,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())), // nolint: gosec
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, n := t.pick(isDiscovery(req))
+	if fault == "" {
+		return t.base.RoundTrip(req)
+	}
+	log.Debug().Str("url", req.URL.String()).Str("fault", string(fault)).Msg("injecting fault")
+	switch fault {
+	case DiscoveryFailure:
+		closeBody(req)
+		return nil, fmt.Errorf("chaos: injected %s", fault)
+	case SlowResponse:
+		delay := time.Duration(n % int64(maxDelay))
+		select {
+		case <-req.Context().Done():
+			closeBody(req)
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch fault {
+	case SlowResponse:
+		resp.Body = &slowBody{ReadCloser: resp.Body}
+	case ConnectionReset:
+		resp.Body = &resetBody{ReadCloser: resp.Body, left: n % maxResetBytes}
+	}
+	return resp, nil
+}
+
+// pick returns the fault to inject into the next request, if any, along with
+// a random number to size it.
+func (t *Transport) pick(discovery bool) (Fault, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.rand.Float64() >= t.rate {
+		return "", 0
+	}
+	faults := []Fault{SlowResponse, ConnectionReset}
+	if discovery {
+		faults = append(faults, DiscoveryFailure)
+	}
+	return faults[t.rand.Intn(len(faults))], t.rand.Int63()
+}
+
+// closeBody closes the body of a request that is not sent, as round
+// trippers must.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// isDiscovery returns whether req is to fast.com or its API, and not to a
+// test server.
+func isDiscovery(req *http.Request) bool {
+	host := req.URL.Hostname()
+	return host == "fast.com" || strings.HasSuffix(host, ".fast.com")
+}
+
+// slowBody waits before each read.
+type slowBody struct {
+	io.ReadCloser
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	time.Sleep(slowReadDelay)
+	return b.ReadCloser.Read(p)
+}
+
+// resetBody fails with a connection reset once left bytes were read.
+type resetBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *resetBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, fmt.Errorf("chaos: injected %s: %w", ConnectionReset, syscall.ECONNRESET)
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,456 @@
+// Package config handles the exporter configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/caarlos0/fastcom-exporter/pkg/sinks"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the exporter configuration file.
+type Config struct {
+	// Labels are static labels added to all exported fast.com metrics.
+	Labels map[string]string `yaml:"labels"`
+
+	// Sinks receive every new measurement result.
+	Sinks []Sink `yaml:"sinks"`
+
+	// Thresholds are the minimum expected speeds, used to generate alerting
+	// rules.
+	Thresholds Thresholds `yaml:"thresholds"`
+
+	// Telegram enables the Telegram bot if set.
+	Telegram *Telegram `yaml:"telegram"`
+
+	// Profiles are extra measurements, each on its own schedule, exported
+	// with a profile label.
+	Profiles []Profile `yaml:"profiles"`
+
+	// Providers are other speed test servers, measured right after fast.com
+	// and exported with a provider label, to compare them.
+	Providers []Provider `yaml:"providers"`
+
+	// API configures the HTTP API.
+	API API `yaml:"api"`
+}
+
+// API configures the HTTP API.
+type API struct {
+	// Tokens are the bearer tokens required by the endpoints that measure
+	// or pause, which are open if there are none.
+	Tokens []string `yaml:"tokens"`
+	// TokensFile is read for more tokens, one per line, e.g. a Docker or
+	// Kubernetes secret.
+	TokensFile string `yaml:"tokens_file"`
+	// CORSOrigins are the origins allowed to call the API from browsers,
+	// e.g. https://dashboard.example.com, or * for any.
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+// Provider is a speed test server measured by downloading from its URLs.
+type Provider struct {
+	Name string `yaml:"name"`
+	// URLs are downloaded from in turns, e.g.
+	// https://speed.cloudflare.com/__down?bytes=25000000.
+	URLs []string `yaml:"urls"`
+}
+
+// DefaultProfile is the profile label of the measurements configured by
+// flags, when there are other profiles.
+const DefaultProfile = "default"
+
+// Profile is a named measurement with its own schedule, always measured in
+// the background.
+// Unset fields use the values of the flags.
+type Profile struct {
+	Name     string        `yaml:"name"`
+	Interval time.Duration `yaml:"interval"`
+	Jitter   time.Duration `yaml:"jitter"`
+
+	Connections int           `yaml:"connections"`
+	MaxDuration time.Duration `yaml:"max_duration"`
+	Strategy    string        `yaml:"strategy"`
+	Burst       bool          `yaml:"burst"`
+
+	// Upload also measures the upload speed, with the upload flags.
+	Upload bool `yaml:"upload"`
+}
+
+// Telegram configures the Telegram bot, which measures on the /speed command
+// and pushes results below the thresholds, if any.
+type Telegram struct {
+	Token string `yaml:"token"`
+	// TokenFile is read for the token instead, e.g. a Docker or Kubernetes
+	// secret.
+	TokenFile string `yaml:"token_file"`
+	// Chats are the IDs of the only chats the bot talks to.
+	Chats []int64 `yaml:"chats"`
+}
+
+// Thresholds are the minimum expected speeds.
+// Zero values are not checked.
+type Thresholds struct {
+	DownloadMbps float64 `yaml:"download_mbps"`
+	UploadMbps   float64 `yaml:"upload_mbps"`
+
+	// For is how long speeds need to stay below the thresholds to alert,
+	// defaults to 1h.
+	For time.Duration `yaml:"for"`
+}
+
+// Sink configures where results are pushed to.
+// Exactly one sink type must be set.
+type Sink struct {
+	Webhook  *Webhook  `yaml:"webhook"`
+	HTTP     *HTTP     `yaml:"http"`
+	Email    *Email    `yaml:"email"`
+	Journald *Journald `yaml:"journald"`
+
+	// MinChange, if set, only writes results whose speed changed more than
+	// this fraction (e.g. 0.1 for 10%) since the last written result.
+	MinChange float64 `yaml:"min_change"`
+
+	// OnlyBreaches only writes results below the configured thresholds.
+	OnlyBreaches bool `yaml:"only_breaches"`
+}
+
+// Webhook POSTs results as JSON to an URL.
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// HTTP sends results to an URL, in a body rendered from a Go template, e.g.
+// to IFTTT or Google Apps Script webhooks.
+type HTTP struct {
+	URL string `yaml:"url"`
+	// Method defaults to POST.
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	// Body is a text/template rendering the result, sent as JSON unless the
+	// headers set another Content-Type.
+	Body string `yaml:"body"`
+}
+
+// Journald writes results and the exporter lifecycle events as structured
+// journal entries, on Linux with systemd.
+type Journald struct {
+	// Identifier is the syslog identifier of the entries, defaults to
+	// fastcom-exporter.
+	Identifier string `yaml:"identifier"`
+}
+
+// Email sends results through SMTP.
+type Email struct {
+	// Addr is the SMTP server address, as host:port.
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+
+	// PasswordFile is read for the password instead, e.g. a Docker or
+	// Kubernetes secret.
+	PasswordFile string `yaml:"password_file"`
+
+	// Every, if set, sends a summary of all results at most this often
+	// instead of an email per result.
+	Every time.Duration `yaml:"every"`
+}
+
+// Load reads and validates the configuration file at the given path.
+// An empty path returns an empty configuration.
+func Load(path string) (*Config, error) {
+	var cfg Config
+	if path == "" {
+		return &cfg, nil
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+	if err := yaml.UnmarshalStrict([]byte(expandEnv(string(bts))), &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	if err := cfg.readSecrets(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// expandEnv replaces ${VAR} and $VAR with the value of the environment
+// variable, $$ being an escaped $.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
+
+// readSecrets reads the secrets referenced by *_file fields.
+func (c *Config) readSecrets() error {
+	if t := c.Telegram; t != nil {
+		if err := readSecret(&t.Token, t.TokenFile); err != nil {
+			return fmt.Errorf("telegram: token_file: %w", err)
+		}
+	}
+	if path := c.API.TokensFile; path != "" {
+		bts, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("api: tokens_file: %w", err)
+		}
+		for _, line := range strings.Split(string(bts), "\n") {
+			if token := strings.TrimSpace(line); token != "" {
+				c.API.Tokens = append(c.API.Tokens, token)
+			}
+		}
+	}
+	for i, sink := range c.Sinks {
+		if e := sink.Email; e != nil {
+			if err := readSecret(&e.Password, e.PasswordFile); err != nil {
+				return fmt.Errorf("sinks[%d]: password_file: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readSecret sets value to the contents of path, without the trailing
+// newline, if path is set.
+func readSecret(value *string, path string) error {
+	if path == "" {
+		return nil
+	}
+	if *value != "" {
+		return errors.New("the secret is also set inline")
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	*value = strings.TrimRight(string(bts), "\r\n")
+	return nil
+}
+
+// Validate checks the configuration for errors.
+func (c *Config) Validate() error {
+	for name := range c.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+		if name == "profile" && len(c.Profiles) > 0 {
+			return errors.New(`label name "profile" is reserved when profiles are set`)
+		}
+	}
+	for i, token := range c.API.Tokens {
+		if token == "" {
+			return fmt.Errorf("api: tokens[%d] is empty, is its environment variable set?", i)
+		}
+	}
+	for i, origin := range c.API.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if err := ValidateURL(origin); err != nil {
+			return fmt.Errorf("api: cors_origins[%d]: %w", i, err)
+		}
+	}
+	if err := c.Thresholds.Validate(); err != nil {
+		return fmt.Errorf("thresholds: %w", err)
+	}
+	if c.Telegram != nil {
+		if err := c.Telegram.Validate(); err != nil {
+			return fmt.Errorf("telegram: %w", err)
+		}
+	}
+	names := map[string]bool{DefaultProfile: true}
+	for i, profile := range c.Profiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("profiles[%d]: %w", i, err)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("profiles[%d]: duplicated name %q", i, profile.Name)
+		}
+		names[profile.Name] = true
+	}
+	providers := map[string]bool{"fast.com": true}
+	for i, provider := range c.Providers {
+		if err := provider.Validate(); err != nil {
+			return fmt.Errorf("providers[%d]: %w", i, err)
+		}
+		if providers[provider.Name] {
+			return fmt.Errorf("providers[%d]: duplicated name %q", i, provider.Name)
+		}
+		providers[provider.Name] = true
+	}
+	for i, sink := range c.Sinks {
+		if err := sink.Validate(); err != nil {
+			return fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		if sink.OnlyBreaches && c.Thresholds.DownloadMbps == 0 && c.Thresholds.UploadMbps == 0 {
+			return fmt.Errorf("sinks[%d]: only_breaches requires thresholds", i)
+		}
+	}
+	return nil
+}
+
+// Validate checks the thresholds for errors.
+func (t Thresholds) Validate() error {
+	if t.DownloadMbps < 0 {
+		return fmt.Errorf("download_mbps must not be negative, got %v", t.DownloadMbps)
+	}
+	if t.UploadMbps < 0 {
+		return fmt.Errorf("upload_mbps must not be negative, got %v", t.UploadMbps)
+	}
+	if t.For < 0 {
+		return fmt.Errorf("for must not be negative, got %s", t.For)
+	}
+	return nil
+}
+
+// Validate checks the profile for errors.
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", p.Interval)
+	}
+	if p.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative, got %s", p.Jitter)
+	}
+	if p.Connections < 0 {
+		return fmt.Errorf("connections must not be negative, got %d", p.Connections)
+	}
+	if p.MaxDuration < 0 {
+		return fmt.Errorf("max_duration must not be negative, got %s", p.MaxDuration)
+	}
+	if p.Strategy != "" && !validStrategy(p.Strategy) {
+		return fmt.Errorf("invalid strategy %q", p.Strategy)
+	}
+	return nil
+}
+
+// Validate checks the provider for errors.
+func (p Provider) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(p.URLs) == 0 {
+		return errors.New("urls are required")
+	}
+	for _, u := range p.URLs {
+		if err := ValidateURL(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validStrategy(s string) bool {
+	for _, strategy := range fast.Strategies {
+		if string(strategy) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the sink configuration for errors.
+func (s Sink) Validate() error {
+	if s.MinChange < 0 {
+		return fmt.Errorf("min_change must not be negative, got %v", s.MinChange)
+	}
+	var types int
+	for _, set := range []bool{s.Webhook != nil, s.HTTP != nil, s.Email != nil, s.Journald != nil} {
+		if set {
+			types++
+		}
+	}
+	switch {
+	case types > 1:
+		return errors.New("more than one sink type set")
+	case s.Webhook != nil:
+		return ValidateURL(s.Webhook.URL)
+	case s.HTTP != nil:
+		return s.HTTP.Validate()
+	case s.Email != nil:
+		return s.Email.Validate()
+	case s.Journald != nil:
+		return nil
+	default:
+		return errors.New("no sink type set")
+	}
+}
+
+// Validate checks the HTTP sink configuration for errors.
+func (h HTTP) Validate() error {
+	if err := ValidateURL(h.URL); err != nil {
+		return err
+	}
+	if h.Method != "" && strings.ToUpper(h.Method) != h.Method {
+		return fmt.Errorf("method must be uppercase, got %q", h.Method)
+	}
+	_, err := sinks.ParseTemplate(h.Body)
+	return err
+}
+
+// Validate checks the Telegram configuration for errors.
+func (t Telegram) Validate() error {
+	if t.Token == "" {
+		return errors.New("token is required")
+	}
+	if len(t.Chats) == 0 {
+		return errors.New("chats is required")
+	}
+	return nil
+}
+
+// Validate checks the email configuration for errors.
+func (e Email) Validate() error {
+	if _, _, err := net.SplitHostPort(e.Addr); err != nil {
+		return fmt.Errorf("invalid addr %q: %w", e.Addr, err)
+	}
+	if e.From == "" {
+		return errors.New("from is required")
+	}
+	if len(e.To) == 0 {
+		return errors.New("to is required")
+	}
+	if e.Every < 0 {
+		return fmt.Errorf("every must not be negative, got %s", e.Every)
+	}
+	return nil
+}
+
+// ValidateURL checks that s is an absolute http or https URL.
+func ValidateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", s, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid url %q: missing host", s)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config string
+		labels map[string]string
+		err    bool
+	}{
+		{name: "empty", config: ""},
+		{name: "labels", config: "labels:\n  site: home\n  isp: foo\n", labels: map[string]string{"site": "home", "isp": "foo"}},
+		{name: "invalid label", config: "labels:\n  not-valid: home\n", err: true},
+		{name: "reserved label", config: "labels:\n  __site: home\n", err: true},
+		{name: "unknown field", config: "lables:\n  site: home\n", err: true},
+		{name: "invalid yaml", config: "labels: [", err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Labels) != len(tt.labels) {
+				t.Fatalf("expected labels %v, got %v", tt.labels, cfg.Labels)
+			}
+			for k, v := range tt.labels {
+				if cfg.Labels[k] != v {
+					t.Fatalf("expected labels %v, got %v", tt.labels, cfg.Labels)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadWithoutPath(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Labels) != 0 {
+		t.Fatalf("expected an empty config, got %+v", cfg)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Fatal("expected an error loading a missing file")
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	const name = "FASTCOM_EXPORTER_CONFIG_TEST"
+	old, ok := os.LookupEnv(name)
+	_ = os.Setenv(name, "secret")
+	defer func() {
+		if ok {
+			_ = os.Setenv(name, old)
+		} else {
+			_ = os.Unsetenv(name)
+		}
+	}()
+	for in, want := range map[string]string{
+		"token: ${" + name + "}":   "token: secret",
+		"token: $" + name:          "token: secret",
+		"token: $$" + name:         "token: $" + name,
+		"token: ${" + name + "_X}": "token: ",
+		"token: plain":             "token: plain",
+	} {
+		if got := expandEnv(in); got != want {
+			t.Errorf("expected %q to expand to %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	const email = "sinks:\n- email:\n    addr: smtp.example.com:587\n    from: a@example.com\n    to: [b@example.com]\n"
+
+	for _, tt := range []struct {
+		name   string
+		config string
+		secret func(*Config) string
+		err    bool
+	}{
+		{
+			name:   "telegram token file",
+			config: "telegram:\n  token_file: " + secret + "\n  chats: [1]\n",
+			secret: func(c *Config) string { return c.Telegram.Token },
+		},
+		{
+			name:   "email password file",
+			config: email + "    password_file: " + secret + "\n",
+			secret: func(c *Config) string { return c.Sinks[0].Email.Password },
+		},
+		{
+			name:   "inline and file",
+			config: email + "    password: inline\n    password_file: " + secret + "\n",
+			err:    true,
+		},
+		{
+			name:   "missing file",
+			config: "telegram:\n  token_file: " + missing + "\n  chats: [1]\n",
+			err:    true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.secret(cfg); got != "s3cr3t" {
+				t.Fatalf("expected the secret without the newline, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package dirs locates the configuration, state and cache directories of the
+// exporter, following the XDG base directory specification on Linux and the
+// platform conventions elsewhere, e.g. APPDATA on Windows.
+package dirs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// name is the subdirectory of the exporter in each base directory.
+const name = "fastcom-exporter"
+
+// Config returns the directory of the configuration file, e.g.
+// ~/.config/fastcom-exporter, or an empty string if there is none.
+func Config() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// State returns the directory of the data persisted across restarts, like
+// the history, e.g. ~/.local/state/fastcom-exporter, or an empty string if
+// there is none.
+// Only Linux and other Unix systems have a state directory, the
+// configuration one is used elsewhere.
+func State() string {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		return Config()
+	}
+	// relative paths are invalid, as per the specification
+	dir := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, name)
+}
+
+// Cache returns the directory of data that can be lost, like the discovery
+// cache, e.g. ~/.cache/fastcom-exporter, or an empty string if there is none.
+func Cache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// File returns the path of the named file in dir, creating dir if needed.
+func File(dir, file string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, file), nil
+}
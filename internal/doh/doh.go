@@ -0,0 +1,231 @@
+// Package doh resolves host names with DNS-over-HTTPS (RFC 8484), bypassing
+// the system resolver on networks where the ISP DNS interferes.
+package doh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// maxResponseSize is the maximum size of a DNS message.
+	maxResponseSize = 64 << 10
+	// minTTL avoids querying again on every connection for records with
+	// tiny TTLs.
+	minTTL = 30 * time.Second
+)
+
+// DialContextFunc dials a network connection.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Resolver resolves host names through a DoH endpoint, caching the answers
+// for their TTL.
+type Resolver struct {
+	url    string
+	client *http.Client
+	mutex  sync.Mutex
+	cache  map[string]answer
+}
+
+type answer struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// New returns a resolver querying the DoH endpoint at url, e.g.
+// https://1.1.1.1/dns-query, with client.
+// The host name of the endpoint itself is resolved by client, so an IP
+// address avoids depending on the system resolver at all.
+func New(url string, client *http.Client) *Resolver {
+	return &Resolver{
+		url:    url,
+		client: client,
+		cache:  map[string]answer{},
+	}
+}
+
+// Dial wraps dial, resolving the host names of the addresses it is called
+// with through DoH, and dialing their addresses in turn until one connects.
+func (r *Resolver) Dial(dial DialContextFunc) DialContextFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := r.LookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, ip := range ips {
+			if (network == "tcp4" && ip.To4() == nil) || (network == "tcp6" && ip.To4() != nil) {
+				continue
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no %s address for %s", network, host)
+		}
+		return nil, firstErr
+	}
+}
+
+// LookupIP returns the IPv4 and IPv6 addresses of host, IPv4 first.
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	r.mutex.Lock()
+	cached, ok := r.cache[host]
+	r.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.ips, nil
+	}
+
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	var firstErr error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, t, err := r.query(ctx, host, qtype)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ips = append(ips, found...)
+		if len(found) > 0 && (ttl < 0 || t < ttl) {
+			ttl = t
+		}
+	}
+	if len(ips) == 0 {
+		if firstErr != nil {
+			return nil, fmt.Errorf("could not resolve %s with doh: %w", host, firstErr)
+		}
+		return nil, fmt.Errorf("could not resolve %s with doh: no addresses", host)
+	}
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	r.mutex.Lock()
+	r.cache[host] = answer{ips: ips, expires: time.Now().Add(ttl)}
+	r.mutex.Unlock()
+	return ips, nil
+}
+
+// query sends a query for the records of the given type, returning their
+// addresses and lowest TTL.
+func (r *Resolver) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	msg, err := newQuery(host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s returned %s", r.url, resp.Status)
+	}
+	bts, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseResponse(bts, qtype)
+}
+
+// newQuery builds a recursive query, with ID 0 as recommended for DoH.
+func newQuery(host string, qtype dnsmessage.Type) ([]byte, error) {
+	if host == "" {
+		return nil, fmt.Errorf("invalid host name %q", host)
+	}
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q: %w", host, err)
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	return msg.Pack()
+}
+
+// parseResponse returns the addresses of the answers of the given type, and
+// their lowest TTL.
+func parseResponse(msg []byte, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(msg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("dns query failed with %s", header.RCode)
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+	}
+
+	var ips []net.IP
+	var ttl time.Duration = -1
+	for {
+		answer, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+		}
+		// CNAMEs come along with the records they point to
+		if answer.Type != qtype || answer.Class != dnsmessage.ClassINET {
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+			}
+			continue
+		}
+		switch qtype {
+		case dnsmessage.TypeA:
+			record, err := parser.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+			}
+			ips = append(ips, net.IP(record.A[:]))
+		case dnsmessage.TypeAAAA:
+			record, err := parser.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("malformed dns response: %w", err)
+			}
+			ips = append(ips, net.IP(record.AAAA[:]))
+		}
+		if t := time.Duration(answer.TTL) * time.Second; ttl < 0 || t < ttl {
+			ttl = t
+		}
+	}
+	return ips, ttl, nil
+}
@@ -0,0 +1,32 @@
+//go:build go1.18
+// +build go1.18
+
+package doh
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func FuzzParseResponse(f *testing.F) {
+	f.Add(response(f, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeA,
+		cname("api.fast.com.", 10, "a1.w10.akamai.net."),
+		a("a1.w10.akamai.net.", 120, "192.0.2.3"),
+	), uint16(dnsmessage.TypeA))
+	f.Add(response(f, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeAAAA,
+		aaaa("api.fast.com.", 90, "2001:db8::1"),
+	), uint16(dnsmessage.TypeAAAA))
+	f.Fuzz(func(t *testing.T, msg []byte, qtype uint16) {
+		ips, _, err := parseResponse(msg, dnsmessage.Type(qtype))
+		if err != nil {
+			return
+		}
+		for _, ip := range ips {
+			if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+				t.Fatalf("invalid address %v", []byte(ip))
+			}
+		}
+	})
+}
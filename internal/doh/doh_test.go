@@ -0,0 +1,248 @@
+package doh
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// response packs a compressed response to a query for host with the given
+// answers.
+func response(t testing.TB, rcode dnsmessage.RCode, host string, qtype dnsmessage.Type, answers ...func(*dnsmessage.Builder) error) []byte {
+	t.Helper()
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, RCode: rcode})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(host),
+		Type:  qtype,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.StartAnswers(); err != nil {
+		t.Fatal(err)
+	}
+	for _, answer := range answers {
+		if err := answer(&builder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func header(host string, ttl uint32) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(host), Class: dnsmessage.ClassINET, TTL: ttl}
+}
+
+func a(host string, ttl uint32, ip string) func(*dnsmessage.Builder) error {
+	return func(b *dnsmessage.Builder) error {
+		var record dnsmessage.AResource
+		copy(record.A[:], net.ParseIP(ip).To4())
+		return b.AResource(header(host, ttl), record)
+	}
+}
+
+func aaaa(host string, ttl uint32, ip string) func(*dnsmessage.Builder) error {
+	return func(b *dnsmessage.Builder) error {
+		var record dnsmessage.AAAAResource
+		copy(record.AAAA[:], net.ParseIP(ip))
+		return b.AAAAResource(header(host, ttl), record)
+	}
+}
+
+func cname(host string, ttl uint32, target string) func(*dnsmessage.Builder) error {
+	return func(b *dnsmessage.Builder) error {
+		return b.CNAMEResource(header(host, ttl), dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)})
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	bts, err := newQuery("api.fast.com", dnsmessage.TypeAAAA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(bts); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 0 || !msg.RecursionDesired || len(msg.Questions) != 1 {
+		t.Fatalf("expected a single recursive question with ID 0, got %+v", msg.Header)
+	}
+	if q := msg.Questions[0]; q.Name.String() != "api.fast.com." || q.Type != dnsmessage.TypeAAAA || q.Class != dnsmessage.ClassINET {
+		t.Fatalf("unexpected question %+v", q)
+	}
+
+	for _, host := range []string{"", "api..fast.com", "a" + string(make([]byte, 64)) + ".com"} {
+		if _, err := newQuery(host, dnsmessage.TypeA); err == nil {
+			t.Errorf("expected an error querying %q", host)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	full := response(t, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeA,
+		a("api.fast.com.", 300, "192.0.2.1"),
+		a("api.fast.com.", 60, "192.0.2.2"),
+	)
+	for _, tt := range []struct {
+		name    string
+		msg     []byte
+		qtype   dnsmessage.Type
+		want    []string
+		wantTTL time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "lowest ttl",
+			msg:     full,
+			qtype:   dnsmessage.TypeA,
+			want:    []string{"192.0.2.1", "192.0.2.2"},
+			wantTTL: 60 * time.Second,
+		},
+		{
+			name: "cname chain",
+			msg: response(t, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeA,
+				cname("api.fast.com.", 10, "api.fast.com.edgesuite.net."),
+				cname("api.fast.com.edgesuite.net.", 20, "a1.w10.akamai.net."),
+				a("a1.w10.akamai.net.", 120, "192.0.2.3"),
+			),
+			qtype:   dnsmessage.TypeA,
+			want:    []string{"192.0.2.3"},
+			wantTTL: 120 * time.Second,
+		},
+		{
+			name: "ipv6",
+			msg: response(t, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeAAAA,
+				aaaa("api.fast.com.", 90, "2001:db8::1"),
+			),
+			qtype:   dnsmessage.TypeAAAA,
+			want:    []string{"2001:db8::1"},
+			wantTTL: 90 * time.Second,
+		},
+		{
+			name: "other types skipped",
+			msg: response(t, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeA,
+				aaaa("api.fast.com.", 90, "2001:db8::1"),
+			),
+			qtype:   dnsmessage.TypeA,
+			wantTTL: -1,
+		},
+		{
+			name:    "no answers",
+			msg:     response(t, dnsmessage.RCodeSuccess, "api.fast.com.", dnsmessage.TypeA),
+			qtype:   dnsmessage.TypeA,
+			wantTTL: -1,
+		},
+		{
+			name:    "rcode",
+			msg:     response(t, dnsmessage.RCodeNameError, "api.fast.com.", dnsmessage.TypeA),
+			qtype:   dnsmessage.TypeA,
+			wantErr: true,
+		},
+		{
+			name:    "short header",
+			msg:     full[:8],
+			qtype:   dnsmessage.TypeA,
+			wantErr: true,
+		},
+		{
+			name:    "truncated answer",
+			msg:     full[:len(full)-2],
+			qtype:   dnsmessage.TypeA,
+			wantErr: true,
+		},
+		{
+			name: "pointer loop",
+			msg: []byte{
+				0, 0, 0x81, 0x80, 0, 0, 0, 1, 0, 0, 0, 0,
+				0xc0, 12, // the answer name points to itself
+				0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0, 2, 1,
+			},
+			qtype:   dnsmessage.TypeA,
+			wantErr: true,
+		},
+		{
+			name: "pointer out of bounds",
+			msg: []byte{
+				0, 0, 0x81, 0x80, 0, 0, 0, 1, 0, 0, 0, 0,
+				0xc0, 0xff,
+				0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0, 2, 1,
+			},
+			qtype:   dnsmessage.TypeA,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, ttl, err := parseResponse(tt.msg, tt.qtype)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", ips)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ips) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, ips)
+			}
+			for i, ip := range ips {
+				if ip.String() != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, ips)
+				}
+			}
+			if ttl != tt.wantTTL {
+				t.Fatalf("expected a ttl of %s, got %s", tt.wantTTL, ttl)
+			}
+		})
+	}
+}
+
+func TestLookupIP(t *testing.T) {
+	var queries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		bts, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if err := query.Unpack(bts); err != nil || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		question := query.Questions[0]
+		answer := a(question.Name.String(), 1, "192.0.2.1")
+		if question.Type == dnsmessage.TypeAAAA {
+			answer = aaaa(question.Name.String(), 1, "2001:db8::1")
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(response(t, dnsmessage.RCodeSuccess, question.Name.String(), question.Type, answer))
+	}))
+	defer srv.Close()
+
+	r := New(srv.URL, srv.Client())
+	for i := 0; i < 2; i++ {
+		ips, err := r.LookupIP(context.Background(), "API.fast.com.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ips) != 2 || ips[0].String() != "192.0.2.1" || ips[1].String() != "2001:db8::1" {
+			t.Fatalf("expected the IPv4 then the IPv6 address, got %v", ips)
+		}
+	}
+	// tiny TTLs are cached for minTTL
+	if queries != 2 {
+		t.Fatalf("expected one query per record type, got %d", queries)
+	}
+}
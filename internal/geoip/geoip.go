@@ -0,0 +1,85 @@
+// Package geoip locates IP addresses with MaxMind GeoLite2 or GeoIP2
+// databases, e.g. GeoLite2-ASN and GeoLite2-City.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DB locates IP addresses with one or more databases, merging what each of
+// them knows.
+type DB struct {
+	readers []*maxminddb.Reader
+}
+
+// record holds the fields of the ASN and City databases the exporter uses,
+// the ones a database does not have are left empty.
+type record struct {
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+	Country      struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// Open reads the databases at the given paths into memory.
+func Open(paths ...string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		bts, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open geoip database: %w", err)
+		}
+		r, err := maxminddb.FromBytes(bts)
+		if err != nil {
+			return nil, fmt.Errorf("could not open geoip database %s: %w", path, err)
+		}
+		db.readers = append(db.readers, r)
+	}
+	return db, nil
+}
+
+// Types returns the types of the databases, e.g. GeoLite2-City.
+func (db *DB) Types() []string {
+	types := make([]string, 0, len(db.readers))
+	for _, r := range db.readers {
+		types = append(types, r.Metadata.DatabaseType)
+	}
+	return types
+}
+
+// Locate implements fast.Locator.
+func (db *DB) Locate(ip net.IP) (fast.Location, bool) {
+	var location fast.Location
+	for _, r := range db.readers {
+		var record record
+		if err := r.Lookup(ip, &record); err != nil {
+			continue
+		}
+		if location.ASN == 0 {
+			location.ASN = record.ASN
+			location.Organization = record.Organization
+		}
+		if location.Country == "" {
+			location.Country = record.Country.ISOCode
+		}
+		if location.City == "" {
+			location.City = record.City.Names["en"]
+		}
+	}
+	return location, location != fast.Location{}
+}
+
+// LookupASN implements fast.ASNResolver.
+func (db *DB) LookupASN(ip net.IP) (uint, string, bool) {
+	location, _ := db.Locate(ip)
+	return location.ASN, location.Organization, location.ASN != 0
+}
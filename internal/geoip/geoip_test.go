@@ -0,0 +1,97 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+// The test databases know 198.51.100.0/24 and 2001:db8::/32 in the ASN one,
+// and 198.51.100.0/24 and 203.0.113.0/24 in the City one.
+const (
+	asnDB  = "testdata/GeoLite2-ASN-Test.mmdb"
+	cityDB = "testdata/GeoLite2-City-Test.mmdb"
+)
+
+func TestLocate(t *testing.T) {
+	db, err := Open(asnDB, cityDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		ip     string
+		want   fast.Location
+		wantOK bool
+	}{
+		{
+			ip:     "198.51.100.7",
+			want:   fast.Location{Country: "US", City: "Los Gatos", ASN: 2906, Organization: "AS-SSI"},
+			wantOK: true,
+		},
+		{
+			ip:     "203.0.113.1",
+			want:   fast.Location{Country: "BR"},
+			wantOK: true,
+		},
+		{
+			ip:     "2001:db8::1",
+			want:   fast.Location{ASN: 64496, Organization: "Example ISP"},
+			wantOK: true,
+		},
+		{ip: "192.0.2.1"},
+		{ip: "2001:db9::1"},
+	} {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := db.Locate(net.ParseIP(tt.ip))
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("expected %+v, %v, got %+v, %v", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestLookupASN(t *testing.T) {
+	db, err := Open(cityDB, asnDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asn, org, ok := db.LookupASN(net.ParseIP("198.51.100.7")); !ok || asn != 2906 || org != "AS-SSI" {
+		t.Fatalf("expected AS2906 AS-SSI, got AS%d %s, %v", asn, org, ok)
+	}
+	if _, _, ok := db.LookupASN(net.ParseIP("203.0.113.1")); ok {
+		t.Fatal("expected no ASN for an address only the City database knows")
+	}
+}
+
+func TestTypes(t *testing.T) {
+	db, err := Open(asnDB, cityDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := db.Types()
+	if len(types) != 2 || types[0] != "GeoLite2-ASN" || types[1] != "GeoLite2-City" {
+		t.Fatalf("expected the ASN and City types, got %v", types)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if _, err := Open("testdata/missing.mmdb"); err == nil {
+		t.Fatal("expected an error opening a missing database")
+	}
+	bts, err := os.ReadFile(cityDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "truncated.mmdb")
+	for _, size := range []int{0, 16, len(bts) / 2, len(bts) - 8} {
+		if err := os.WriteFile(path, bts[:size], 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(path); err == nil {
+			t.Errorf("expected an error opening a database truncated to %d bytes", size)
+		}
+	}
+}
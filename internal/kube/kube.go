@@ -0,0 +1,158 @@
+// Package kube writes measurement results to the Kubernetes API of the
+// cluster the exporter runs in, as Events or ConfigMaps, without the whole
+// client-go dependency.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir is where Kubernetes mounts the service account
+// credentials in every pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ErrNotInCluster happens when not running inside a Kubernetes pod.
+var ErrNotInCluster = errors.New("not running in a kubernetes cluster")
+
+// Client talks to the Kubernetes API using the pod service account.
+type Client struct {
+	host      string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// InCluster returns a client for the cluster the pod runs in.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %w", err)
+	}
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account namespace: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account ca")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return &Client{
+		host:      "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: strings.TrimSpace(string(namespace)),
+		http:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Event is a Kubernetes Event about a pod.
+type Event struct {
+	// Pod is the name of the pod the event is about.
+	Pod string
+	// Warning sets the event type to Warning instead of Normal.
+	Warning bool
+	Reason  string
+	Message string
+}
+
+// CreateEvent creates an event in the pod namespace.
+func (c *Client) CreateEvent(ctx context.Context, e Event) error {
+	eventType := "Normal"
+	if e.Warning {
+		eventType = "Warning"
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	body := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]interface{}{
+			"generateName": "fastcom-exporter-",
+			"namespace":    c.namespace,
+		},
+		"involvedObject": map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"name":       e.Pod,
+			"namespace":  c.namespace,
+		},
+		"reason":         e.Reason,
+		"message":        e.Message,
+		"type":           eventType,
+		"count":          1,
+		"firstTimestamp": now,
+		"lastTimestamp":  now,
+		"source": map[string]interface{}{
+			"component": "fastcom-exporter",
+		},
+	}
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/namespaces/"+c.namespace+"/events", body)
+	return err
+}
+
+// ApplyConfigMap replaces the data of the named ConfigMap in the pod
+// namespace, creating it if needed.
+func (c *Client) ApplyConfigMap(ctx context.Context, name string, data map[string]string) error {
+	body := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": c.namespace,
+		},
+		"data": data,
+	}
+	path := "/api/v1/namespaces/" + c.namespace + "/configmaps"
+	status, err := c.do(ctx, http.MethodPut, path+"/"+name, body)
+	if status == http.StatusNotFound {
+		_, err = c.do(ctx, http.MethodPost, path, body)
+	}
+	return err
+}
+
+// do sends body as JSON, returning the response status code.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (int, error) {
+	bts, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, bytes.NewReader(bts))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("kubernetes api %s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.StatusCode, nil
+}
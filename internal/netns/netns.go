@@ -0,0 +1,34 @@
+// Package netns creates network connections inside named Linux network
+// namespaces, as managed by `ip netns`.
+package netns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DialContextFunc dials a network connection.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Transport returns an HTTP transport whose connections are created inside
+// the given network namespace.
+func Transport(name string) (*http.Transport, error) {
+	dial, err := Dialer(name)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	return transport, nil
+}
+
+// nolint: gochecknoglobals
+var dialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+	// dial serially, in the calling goroutine, so the socket is created in
+	// the thread that switched namespaces.
+	FallbackDelay: -1,
+}
@@ -0,0 +1,72 @@
+package netns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// dir is where `ip netns` keeps the named namespaces.
+const dir = "/var/run/netns"
+
+// Dialer returns a function that dials connections inside the given named
+// network namespace.
+//
+// Only the sockets are created in the namespace: DNS resolution still happens
+// in the namespace of the exporter.
+func Dialer(name string) (DialContextFunc, error) {
+	target, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("could not open network namespace %q: %w", name, err)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+		return dialIn(ctx, target, network, net.JoinHostPort(ips[0].IP.String(), port))
+	}, nil
+}
+
+func dialIn(ctx context.Context, target *os.File, network, addr string) (net.Conn, error) {
+	// namespaces are per thread, so we must not be moved to another one
+	// while dialing.
+	runtime.LockOSThread()
+
+	origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("could not open current network namespace: %w", err)
+	}
+	defer origin.Close()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("could not switch network namespace: %w", err)
+	}
+
+	conn, dialErr := dialer.DialContext(ctx, network, addr)
+
+	if err := unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET); err != nil {
+		// leave the thread locked so it is terminated with the goroutine
+		// instead of being reused in the wrong namespace.
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, fmt.Errorf("could not switch back network namespace: %w", err)
+	}
+	runtime.UnlockOSThread()
+	return conn, dialErr
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package netns
+
+import "errors"
+
+// Dialer is only supported on Linux.
+func Dialer(string) (DialContextFunc, error) {
+	return nil, errors.New("network namespaces are only supported on Linux")
+}
@@ -0,0 +1,355 @@
+// Package selfupdate replaces the running binary with the latest GitHub
+// release, for installs without a package manager.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	repository = "caarlos0/fastcom-exporter"
+	binary     = "fastcom-exporter"
+	checksums  = "checksums.txt"
+	// maxArchiveSize bounds downloads, the archives are a few MB.
+	maxArchiveSize = 100 << 20
+)
+
+// Release is a GitHub release.
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Version is the release version, without the v prefix.
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// ErrNotNewer happens when updating to a release that is not newer than the
+// running version, or whose version can not be compared to it, e.g. of a
+// build from source.
+var ErrNotNewer = errors.New("release is not newer than the running version")
+
+// ErrNoPublicKey happens when updating without a public key to verify the
+// checksums signature with.
+var ErrNoPublicKey = errors.New("no public key to verify the release signature with")
+
+// Newer reports whether the release is newer than the current version, both
+// semantic versions with or without the v prefix.
+// It fails if either is not a semantic version, e.g. master on builds from
+// source.
+func (r *Release) Newer(current string) (bool, error) {
+	latest, running := canonical(r.Tag), canonical(current)
+	if !semver.IsValid(latest) {
+		return false, fmt.Errorf("invalid release version %q", r.Tag)
+	}
+	if !semver.IsValid(running) {
+		return false, fmt.Errorf("invalid running version %q", current)
+	}
+	return semver.Compare(latest, running) > 0, nil
+}
+
+func canonical(version string) string {
+	return "v" + strings.TrimPrefix(version, "v")
+}
+
+// Latest returns the latest release.
+func Latest(ctx context.Context, client *http.Client) (*Release, error) {
+	bts, err := get(ctx, client, "https://api.github.com/repos/"+repository+"/releases/latest", 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the latest release: %w", err)
+	}
+	var release Release
+	if err := json.Unmarshal(bts, &release); err != nil {
+		return nil, fmt.Errorf("could not parse the latest release: %w", err)
+	}
+	return &release, nil
+}
+
+// Options configures an update.
+type Options struct {
+	// OS and Arch select the release archive, e.g. linux and arm64.
+	// An arm Arch without version picks the lowest available, armv6 or
+	// armv7 select one.
+	OS   string
+	Arch string
+	// Current is the running version, the release must be newer.
+	Current string
+	// Force updates to releases that are not newer, e.g. downgrades or over
+	// builds from source.
+	Force bool
+	// PublicKey is a PEM encoded ECDSA or Ed25519 public key the checksums
+	// file signature is verified with.
+	PublicKey []byte
+	// SkipSignature verifies the checksums only, without a PublicKey.
+	SkipSignature bool
+	Client        *http.Client
+}
+
+// Update downloads the release archive for the platform, verifies it, and
+// replaces the running binary with the one in it.
+// It fails with ErrNotNewer unless the release is newer than the running
+// version, and with ErrNoPublicKey unless its signature can be verified.
+func Update(ctx context.Context, release *Release, opts Options) error {
+	if !opts.Force {
+		newer, err := release.Newer(opts.Current)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrNotNewer, err)
+		}
+		if !newer {
+			return fmt.Errorf("%w: %s is not newer than %s", ErrNotNewer, release.Version(), opts.Current)
+		}
+	}
+	if len(opts.PublicKey) == 0 && !opts.SkipSignature {
+		return ErrNoPublicKey
+	}
+	archive, err := release.archive(opts.OS, opts.Arch)
+	if err != nil {
+		return err
+	}
+	sums, err := release.download(ctx, opts.Client, checksums)
+	if err != nil {
+		return err
+	}
+	if !opts.SkipSignature {
+		sig, err := release.download(ctx, opts.Client, checksums+".sig")
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(opts.PublicKey, sums, sig); err != nil {
+			return err
+		}
+	}
+	bts, err := release.download(ctx, opts.Client, archive)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(sums, archive, bts); err != nil {
+		return err
+	}
+	bin, err := extract(bts)
+	if err != nil {
+		return fmt.Errorf("could not extract %s: %w", archive, err)
+	}
+	return replace(bin)
+}
+
+// archive finds the name of the tar.gz archive for the platform.
+func (r *Release) archive(goos, goarch string) (string, error) {
+	arm := goarch == "arm"
+	var candidates []string
+	for _, a := range r.Assets {
+		name := strings.ToLower(a.Name)
+		if !strings.HasSuffix(name, ".tar.gz") {
+			continue
+		}
+		fields := strings.FieldsFunc(strings.TrimSuffix(name, ".tar.gz"), func(r rune) bool {
+			return r == '_' || r == '-'
+		})
+		if !contains(fields, goos) {
+			continue
+		}
+		for _, f := range fields {
+			if archMatches(f, goarch) || (arm && strings.HasPrefix(f, "armv")) {
+				candidates = append(candidates, a.Name)
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("release %s has no archive for %s/%s", r.Tag, goos, goarch)
+	}
+	// armv6 before armv7, which runs on both
+	sort.Strings(candidates)
+	return candidates[0], nil
+}
+
+func archMatches(field, goarch string) bool {
+	switch goarch {
+	case "amd64":
+		// x86_64 is split in x86 and 64
+		return field == "amd64" || field == "x86"
+	case "386":
+		return field == "386" || field == "i386"
+	}
+	return field == goarch
+}
+
+func contains(fields []string, s string) bool {
+	for _, f := range fields {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Release) download(ctx context.Context, client *http.Client, name string) ([]byte, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			bts, err := get(ctx, client, a.URL, maxArchiveSize)
+			if err != nil {
+				return nil, fmt.Errorf("could not download %s: %w", name, err)
+			}
+			return bts, nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no %s", r.Tag, name)
+}
+
+func get(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	bts, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(bts)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return bts, nil
+}
+
+// verifyChecksum checks bts against the SHA-256 of name in the checksums
+// file, in the sha256sum format.
+func verifyChecksum(sums []byte, name string, bts []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(bts)
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum for %s", name)
+}
+
+// verifySignature checks the base64 encoded signature of the checksums
+// file, as created by cosign sign-blob --key, or a raw Ed25519 one.
+func verifySignature(publicKey, sums, sig []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("invalid public key: no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		raw = sig
+	}
+	var ok bool
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(sums)
+		ok = ecdsa.VerifyASN1(key, sum[:], raw)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, sums, raw)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !ok {
+		return errors.New("invalid checksums signature")
+	}
+	return nil
+}
+
+// extract returns the binary in the tar.gz archive.
+func extract(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no %s in archive", binary)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == binary {
+			return io.ReadAll(io.LimitReader(tr, maxArchiveSize))
+		}
+	}
+}
+
+// replace atomically replaces the running binary with bin, through a
+// temporary file in the same directory.
+func replace(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the running binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("could not find the running binary: %w", err)
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+binary+"-*")
+	if err != nil {
+		return fmt.Errorf("could not write the new binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write the new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write the new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("could not replace %s: %w", exe, err)
+	}
+	return nil
+}
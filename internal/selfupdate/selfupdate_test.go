@@ -0,0 +1,189 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	for _, tt := range []struct {
+		tag, current string
+		newer        bool
+		err          bool
+	}{
+		{tag: "v1.2.0", current: "1.1.9", newer: true},
+		{tag: "v1.10.0", current: "1.9.0", newer: true},
+		{tag: "v1.2.0", current: "v1.2.0-rc1", newer: true},
+		{tag: "v1.2.0", current: "1.2.0"},
+		{tag: "v1.2.0", current: "1.3.0"},
+		{tag: "v1.2.0", current: "master", err: true},
+		{tag: "latest", current: "1.2.0", err: true},
+	} {
+		t.Run(tt.tag+" over "+tt.current, func(t *testing.T) {
+			newer, err := (&Release{Tag: tt.tag}).Newer(tt.current)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newer != tt.newer {
+				t.Fatalf("expected newer to be %v, got %v", tt.newer, newer)
+			}
+		})
+	}
+}
+
+func TestUpdateRefuses(t *testing.T) {
+	release := &Release{Tag: "v1.2.0"}
+	for _, tt := range []struct {
+		name string
+		opts Options
+		err  error
+	}{
+		{name: "downgrade", opts: Options{Current: "1.3.0", SkipSignature: true}, err: ErrNotNewer},
+		{name: "same version", opts: Options{Current: "1.2.0", SkipSignature: true}, err: ErrNotNewer},
+		{name: "build from source", opts: Options{Current: "master", SkipSignature: true}, err: ErrNotNewer},
+		{name: "no public key", opts: Options{Current: "1.1.0"}, err: ErrNoPublicKey},
+		{name: "forced without public key", opts: Options{Current: "master", Force: true}, err: ErrNoPublicKey},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// fails before downloading anything, as the release has no assets
+			if err := Update(context.Background(), release, tt.opts); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	release := &Release{Tag: "v1.2.0"}
+	for _, name := range []string{
+		"checksums.txt",
+		"fastcom-exporter_1.2.0_darwin_amd64.tar.gz",
+		"fastcom-exporter_1.2.0_linux_x86_64.tar.gz",
+		"fastcom-exporter_1.2.0_linux_i386.tar.gz",
+		"fastcom-exporter_1.2.0_linux_arm64.tar.gz",
+		"fastcom-exporter_1.2.0_linux_armv7.tar.gz",
+		"fastcom-exporter_1.2.0_linux_armv6.tar.gz",
+		"fastcom-exporter_1.2.0_linux_amd64.deb",
+	} {
+		release.Assets = append(release.Assets, Asset{Name: name})
+	}
+	for _, tt := range []struct {
+		os, arch string
+		archive  string
+	}{
+		{os: "linux", arch: "amd64", archive: "fastcom-exporter_1.2.0_linux_x86_64.tar.gz"},
+		{os: "linux", arch: "386", archive: "fastcom-exporter_1.2.0_linux_i386.tar.gz"},
+		{os: "linux", arch: "arm64", archive: "fastcom-exporter_1.2.0_linux_arm64.tar.gz"},
+		{os: "linux", arch: "arm", archive: "fastcom-exporter_1.2.0_linux_armv6.tar.gz"},
+		{os: "linux", arch: "armv7", archive: "fastcom-exporter_1.2.0_linux_armv7.tar.gz"},
+		{os: "darwin", arch: "amd64", archive: "fastcom-exporter_1.2.0_darwin_amd64.tar.gz"},
+		{os: "windows", arch: "amd64"},
+		{os: "linux", arch: "mips"},
+	} {
+		t.Run(tt.os+"/"+tt.arch, func(t *testing.T) {
+			archive, err := release.archive(tt.os, tt.arch)
+			if tt.archive == "" {
+				if err == nil {
+					t.Fatalf("expected no archive, got %s", archive)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if archive != tt.archive {
+				t.Fatalf("expected %s, got %s", tt.archive, archive)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	archive := []byte("archive")
+	sum := sha256.Sum256(archive)
+	sums := []byte(fmt.Sprintf("%s  fastcom-exporter_linux_arm64.tar.gz\n%s *fastcom-exporter_linux_amd64.tar.gz\n",
+		hex.EncodeToString(make([]byte, sha256.Size)), hex.EncodeToString(sum[:])))
+
+	if err := verifyChecksum(sums, "fastcom-exporter_linux_amd64.tar.gz", archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum(sums, "fastcom-exporter_linux_arm64.tar.gz", archive); err == nil {
+		t.Fatal("expected a checksum mismatch")
+	}
+	if err := verifyChecksum(sums, "fastcom-exporter_darwin_amd64.tar.gz", archive); err == nil {
+		t.Fatal("expected a missing checksum")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	sums := []byte("checksums")
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(sums)
+	ecSig, err := ecKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSig := ed25519.Sign(edKey, sums)
+
+	for _, tt := range []struct {
+		name  string
+		key   crypto.PublicKey
+		sig   []byte
+		valid bool
+	}{
+		{name: "cosign ecdsa", key: &ecKey.PublicKey, sig: []byte(base64.StdEncoding.EncodeToString(ecSig) + "\n"), valid: true},
+		{name: "raw ed25519", key: edPub, sig: edSig, valid: true},
+		{name: "base64 ed25519", key: edPub, sig: []byte(base64.StdEncoding.EncodeToString(edSig)), valid: true},
+		{name: "wrong key", key: edPub, sig: []byte(base64.StdEncoding.EncodeToString(ecSig))},
+		{name: "tampered", key: edPub, sig: append(edSig[:len(edSig)-1:len(edSig)-1], edSig[len(edSig)-1]^1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(pemKey(t, tt.key), sums, tt.sig)
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected an invalid signature")
+			}
+		})
+	}
+
+	if err := verifySignature([]byte("not a key"), sums, edSig); err == nil {
+		t.Fatal("expected an invalid public key")
+	}
+}
+
+func pemKey(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
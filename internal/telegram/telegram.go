@@ -0,0 +1,187 @@
+// Package telegram implements a Telegram bot that runs measurements on
+// demand and pushes results to chats.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/sinks"
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	apiURL = "https://api.telegram.org/bot"
+	// pollTimeout is how long each getUpdates long poll waits for messages.
+	pollTimeout = 50 * time.Second
+	// retryDelay is how long to wait after a failed poll.
+	retryDelay = 10 * time.Second
+)
+
+// MeasureFunc runs a measurement on demand.
+type MeasureFunc func() (sinks.Result, error)
+
+// Bot answers the /speed command by measuring, and writes results to its
+// chats when used as a sink.
+// Messages from other chats are ignored.
+type Bot struct {
+	token   string
+	chats   map[int64]bool
+	measure MeasureFunc
+	client  *http.Client
+}
+
+// New returns a bot with the given token, only talking to the given chats.
+func New(token string, chats []int64, measure MeasureFunc) *Bot {
+	allowed := make(map[int64]bool, len(chats))
+	for _, chat := range chats {
+		allowed[chat] = true
+	}
+	return &Bot{
+		token:   token,
+		chats:   allowed,
+		measure: measure,
+		client:  &http.Client{Timeout: pollTimeout + 10*time.Second},
+	}
+}
+
+type update struct {
+	ID      int64 `json:"update_id"`
+	Message *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Run polls for commands until the context is canceled.
+func (b *Bot) Run(ctx context.Context) {
+	var offset int64
+	for {
+		updates, err := b.updates(ctx, offset)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get telegram updates")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.ID + 1
+			if u.Message == nil || !b.chats[u.Message.Chat.ID] {
+				continue
+			}
+			b.handle(ctx, u.Message.Chat.ID, u.Message.Text)
+		}
+	}
+}
+
+func (b *Bot) handle(ctx context.Context, chat int64, text string) {
+	// commands can be addressed to the bot, as in /speed@botname
+	command := strings.SplitN(strings.Fields(text + " ")[0], "@", 2)[0]
+	if command != "/speed" {
+		return
+	}
+	if err := b.send(ctx, chat, "Measuring, this takes about a minute..."); err != nil {
+		log.Error().Err(err).Msg("failed to send telegram message")
+	}
+	msg := "Measurement failed"
+	result, err := b.measure()
+	if err != nil {
+		msg += ": " + err.Error()
+	} else {
+		msg = format(result)
+	}
+	if err := b.send(ctx, chat, msg); err != nil {
+		log.Error().Err(err).Msg("failed to send telegram message")
+	}
+}
+
+// Write sends the result to all chats.
+func (b *Bot) Write(ctx context.Context, result sinks.Result) error {
+	for chat := range b.chats {
+		if err := b.send(ctx, chat, format(result)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func format(result sinks.Result) string {
+	msg := "Download: " + units.BytesPerSecond(result.DownloadSpeed).Human()
+	if result.UploadSpeed > 0 {
+		msg += "\nUpload: " + units.BytesPerSecond(result.UploadSpeed).Human()
+	}
+	return msg
+}
+
+func (b *Bot) updates(ctx context.Context, offset int64) ([]update, error) {
+	var updates []update
+	err := b.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(pollTimeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+func (b *Bot) send(ctx context.Context, chat int64, text string) error {
+	return b.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": chat,
+		"text":    text,
+	}, nil)
+}
+
+func (b *Bot) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	bts, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+b.token+"/"+method, bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// the error would contain the token in the url
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s: %s", method, r.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
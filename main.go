@@ -1,32 +1,171 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	// time zones of --peak.timezone, missing from minimal images like alpine
+	_ "time/tzdata"
 
 	"github.com/alecthomas/kingpin"
-	"github.com/caarlos0/fastcom-exporter/collector"
+	"github.com/alecthomas/units"
+	"github.com/caarlos0/fastcom-exporter/internal/activation"
+	"github.com/caarlos0/fastcom-exporter/internal/chaos"
+	"github.com/caarlos0/fastcom-exporter/internal/config"
+	"github.com/caarlos0/fastcom-exporter/internal/dirs"
+	"github.com/caarlos0/fastcom-exporter/internal/doh"
+	"github.com/caarlos0/fastcom-exporter/internal/geoip"
+	"github.com/caarlos0/fastcom-exporter/internal/netns"
+	"github.com/caarlos0/fastcom-exporter/internal/telegram"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/exporter"
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/caarlos0/fastcom-exporter/pkg/sinks"
+	speed "github.com/caarlos0/fastcom-exporter/pkg/units"
 	"github.com/patrickmn/go-cache"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 // nolint: gochecknoglobals
 var (
-	bind     = kingpin.Flag("bind", "addr to bind the server").Short('b').Default(":9877").String()
-	debug    = kingpin.Flag("debug", "show debug logs").Default("false").Bool()
-	format   = kingpin.Flag("logFormat", "log format to use").Default("console").Enum("json", "console")
-	interval = kingpin.Flag("refresh.interval", "time between refreshes with fast.com").Default("30m").Duration()
-	version  = "master"
+	bind           = kingpin.Flag("bind", "addr to bind the server, ignored when socket activated by systemd").Short('b').Default(":9877").String()
+	externalURL    = kingpin.Flag("web.external-url", "URL the exporter is reachable at, e.g. behind a reverse proxy at https://example.com/fastcom/, used in links and service discovery").String()
+	routePrefix    = kingpin.Flag("web.route-prefix", "path prefix of all endpoints, defaults to the path of --web.external-url").String()
+	apiBind        = kingpin.Flag("api.bind", "addr to serve the /api/v1 endpoints on instead of --bind, e.g. localhost:9878 to only expose them locally").String()
+	allowRoot      = kingpin.Flag("allow-root", "allow running as root, which no feature needs but --netns").Bool()
+	runAs          = kingpin.Flag("run-as", "user switched to once listening, when started as root, e.g. to bind a privileged port (not supported on Windows)").String()
+	idleExit       = kingpin.Flag("idle-exit", "exit after this long without requests, e.g. when socket activated by systemd, 0 to never exit").Default("0s").Duration()
+	debug          = kingpin.Flag("debug", "show debug logs").Default("false").Bool()
+	format         = kingpin.Flag("logFormat", "log format to use").Default("console").Enum("json", "console")
+	interval       = kingpin.Flag("refresh.interval", "time between refreshes with fast.com").Default("30m").Duration()
+	jitter         = kingpin.Flag("refresh.jitter", "maximum random time added to each refresh interval").Default("0s").Duration()
+	maxBackoff     = kingpin.Flag("refresh.max-backoff", "maximum refresh interval in background mode, doubled after each consecutive failure, 0 disables the backoff").Default("6h").Duration()
+	delay          = kingpin.Flag("refresh.startup-delay", "maximum random delay before the first measurement in background mode").Default("0s").Duration()
+	mode           = kingpin.Flag("mode", "measure on scrape (caching results) or in the background").Default("scrape").Enum("scrape", "background")
+	cacheTTL       = kingpin.Flag("cache-ttl", "in scrape mode, reuse results younger than this instead of measuring again, defaults to --refresh.interval").Duration()
+	connections    = kingpin.Flag("measure.connections", "maximum concurrent requests per measurement").Default("8").Int()
+	strategy       = kingpin.Flag("measure.strategy", "how test servers are chosen: round-robin, lowest-latency (probed before measuring), nearest (only the lowest latency one) or random").Default(string(fast.RoundRobin)).Enum(strategies()...)
+	seed           = kingpin.Flag("measure.seed", "seed of the random strategy, making the sequence of test servers reproducible, 0 for a random one").Default("0").Int64()
+	estimator      = kingpin.Flag("measure.estimator", "how the speed is computed: average (of the whole measurement), stable-window (ignoring the ramp-up, like fast.com) or percentile (90th percentile of the speed in each interval)").Default("average").Enum("average", "stable-window", "percentile")
+	maxDuration    = kingpin.Flag("measure.max-duration", "maximum duration of each measurement").Default("30s").Duration()
+	adaptive       = kingpin.Flag("measure.adaptive", "probe the download speed for a second, then pick the connections and request sizes for it, like fast.com").Bool()
+	replaceSlow    = kingpin.Flag("measure.replace-slowest", "abort the slowest request when it is less than half as fast as the others, and start a new one to a different url").Bool()
+	engine         = kingpin.Flag("engine", "measurement engine: legacy or v2 (adaptive sizing and stable-window estimator), overriding --measure.adaptive and --measure.estimator").Hidden().Enum(engines()...)
+	compareEngines = kingpin.Flag("engine.compare", "measure the download again with the other engine right after each one, exporting the difference").Hidden().Bool()
+	chaosMode      = kingpin.Flag("chaos", "developer mode randomly injecting discovery failures, slow responses and connection resets into requests, to check how the exporter copes with them").Hidden().Bool()
+	chaosRate      = kingpin.Flag("chaos.rate", "ratio of the requests faults are injected into with --chaos").Hidden().Default("0.2").Float64()
+	encapsulation  = kingpin.Flag("measure.encapsulation", "link layer the line rate is estimated for, out of the measured goodput, to compare with the provisioned rate").Default("none").Enum(encapsulations()...)
+	maxBytes       = kingpin.Flag("measure.max-bytes", "stop each measurement once this many bytes were transferred, 0 for no limit").Default("0").Bytes()
+	maxSamples     = kingpin.Flag("measure.max-samples", "maximum throughput samples kept per measurement, taken further apart than every 250ms in longer ones, bounding the memory each result uses, 0 keeps them all").Default("0").Int()
+	bufferSize     = kingpin.Flag("measure.buffer-size", "size of the buffer used to read each response").Default("32KB").Bytes()
+	burst          = kingpin.Flag("measure.burst", "quick measurements with few connections for a few seconds, trading accuracy for less data and time").Bool()
+	userAgent      = kingpin.Flag("measure.user-agent", "User-Agent header sent in measurement requests").Default("caarlos0/fastcom-exporter/" + version).String()
+	headers        = kingpin.Flag("measure.header", "extra header sent in measurement requests, as 'Name: value', can be repeated").Strings()
+	captureHeader  = kingpin.Flag("measure.capture-header", "response header recorded in the result of each request, e.g. X-Cache, Via or Server, can be repeated").Strings()
+	checksum       = kingpin.Flag("measure.checksum", "hash downloads to detect tampering with the test traffic").Bool()
+	knownChecksums = kingpin.Flag("measure.known-checksum", "expected xxhash checksum of downloads, in hex, can be repeated").Strings()
+	continuous     = kingpin.Flag("continuous", "keep a connection trickling between measurements, exporting a rolling estimate of the achievable download speed").Bool()
+	contInterval   = kingpin.Flag("continuous.interval", "time between continuous measurement samples").Default("1m").Duration()
+	contWindow     = kingpin.Flag("continuous.window", "how long each continuous measurement sample reads at full speed").Default("1s").Duration()
+	latencyEvery   = kingpin.Flag("latency.interval", "time between idle latency probes, 0 disables them unless --latency-only").Default("0s").Duration()
+	latencyProbes  = kingpin.Flag("latency.probes", "number of requests in each idle latency probe").Default("10").Int()
+	latencyOnly    = kingpin.Flag("latency-only", "only probe the idle latency, every --latency.interval or every minute, skipping throughput measurements").Bool()
+	gateway        = kingpin.Flag("gateway", "probe the latency to the default gateway before each measurement and after each idle latency probe, telling lan issues from wan congestion (Linux only)").Bool()
+	exclude        = kingpin.Flag("exclude.process", "pause measurements while this process runs, e.g. zoom, teams or obs (repeatable)").Strings()
+	linkCheckURL   = kingpin.Flag("link-check.url", "URL sent a HEAD request before measuring, skipping the measurement if it gets no response, e.g. https://fast.com").String()
+	linkTimeout    = kingpin.Flag("link-check.timeout", "timeout of the link check request").Default("3s").Duration()
+	captiveURL     = kingpin.Flag("captive-portal.url", "URL probed to detect captive portals before measuring, e.g. http://connectivitycheck.gstatic.com/generate_204").String()
+	captiveWant    = kingpin.Flag("captive-portal.expect", "content expected from the captive portal URL, if empty expects a 204 No Content response").String()
+	traceroute     = kingpin.Flag("traceroute", "probe the number of hops and first hop latency to the test server after measuring").Bool()
+	tlsCAFiles     = kingpin.Flag("tls.ca-file", "extra CA bundle trusted by the discovery and measurement requests, e.g. of a corporate TLS interception proxy, can be repeated").ExistingFiles()
+	tlsInsecure    = kingpin.Flag("tls.insecure-skip-verify", "skip TLS verification of the discovery and measurement requests").Bool()
+	publicIP       = kingpin.Flag("public-ip", "export the public IP reported by fast.com, hashed or plain, counting its changes").Default("none").Enum("none", "hashed", "plain")
+	publicIPSalt   = kingpin.Flag("public-ip.salt", "secret hashed along with the public IP, required by --public-ip=hashed since unsalted hashes can be reversed by hashing all IPv4 addresses").Envar("FASTCOM_PUBLIC_IP_SALT").String()
+	dohURL         = kingpin.Flag("dns.doh-url", "DNS-over-HTTPS endpoint the discovery and measurement lookups go through instead of the system resolver, e.g. https://1.1.1.1/dns-query").String()
+	netnsName      = kingpin.Flag("netns", "name of the Linux network namespace, as in 'ip netns', to measure from").String()
+	tcpInfo        = kingpin.Flag("tcp-info", "export retransmissions and round trip times of the measurement connections (Linux only)").Bool()
+	thermal        = kingpin.Flag("thermal", "export the SoC temperature before and after measuring, flagging results taken while the CPU was thermally throttled (Linux only)").Bool()
+	wifi           = kingpin.Flag("wifi", "export the ssid, signal and rate of the wireless link measured over (Linux, with iw for the ssid and rate, and macOS)").Bool()
+	peering        = kingpin.Flag("peering", "export the bytes downloaded from each network of test servers, a /24 for IPv4 or /48 for IPv6, showing which CDN nodes carried the traffic").Bool()
+	geoipDB        = kingpin.Flag("geoip.db", "MaxMind GeoLite2 or GeoIP2 database locating the client and test servers, e.g. GeoLite2-ASN.mmdb or GeoLite2-City.mmdb, ignored if missing, can be repeated").Strings()
+	lowResource    = kingpin.Flag("low-resource", "cap concurrency and buffer sizes for routers and other small devices").Bool()
+	enable         = kingpin.Flag("collector.enable", "comma separated phases measured: download, upload and latency (idle latency probes), so unwanted ones don't cost time or data").Default("download").String()
+	upload         = kingpin.Flag("upload", "also measure the upload speed, same as enabling the upload phase").Bool()
+	uploadSize     = kingpin.Flag("upload.size", "maximum bytes uploaded per measurement, 0 for no limit").Default("0").Bytes()
+	uploadChunk    = kingpin.Flag("upload.chunk-size", "bytes uploaded per request").Default("25MB").Bytes()
+	uploadRandom   = kingpin.Flag("upload.random", "upload random bytes instead of zeros").Bool()
+	duplex         = kingpin.Flag("upload.duplex", "measure download and upload at the same time, along with the loaded latency").Bool()
+	webPprof       = kingpin.Flag("web.pprof", "serve Go profiling endpoints at /debug/pprof/, e.g. to check the CPU use of the exporter while measuring, requiring a token if api.tokens are configured").Bool()
+	goMetrics      = kingpin.Flag("metrics.go", "export Go runtime metrics").Default("true").Bool()
+	processMetrics = kingpin.Flag("metrics.process", "export process metrics").Default("true").Bool()
+	timestamps     = kingpin.Flag("metrics.timestamps", "set the measurement time as the timestamp of the measurement metrics, served as OpenMetrics (background mode only, beware Prometheus considers samples older than 5 minutes stale)").Bool()
+	eventsSize     = kingpin.Flag("events.size", "number of outage events kept, link downs and drastic drops, 0 disables them").Default("1000").Int()
+	eventsFile     = kingpin.Flag("events.file", "file the outage events are persisted to, as JSON lines").String()
+	restartsFile   = kingpin.Flag("restarts.file", "file the exporter records its starts and clean shutdowns to, exporting restart and unclean shutdown counters").String()
+	discoveryFile  = kingpin.Flag("discovery.cache-file", "file the last fast.com token and test URLs are persisted to, so restarts measure right away and discovery outages are survived, defaults to discovery.json in --cache.dir").String()
+	onComplete     = kingpin.Flag("on-complete-exec", "command run through the shell after each measurement, with the speeds in bits per second in DOWNLOAD_BPS and UPLOAD_BPS and the idle latency in LATENCY_MS, e.g. to tune SQM on OpenWrt").String()
+	output         = kingpin.Flag("output", "also write every new result to stdout: ndjson writes them as JSON lines, logs stay on stderr").Default("none").Enum("none", "ndjson")
+	historySize    = kingpin.Flag("history.size", "number of results kept in the history, 0 disables it along with anomaly detection").Default("1000").Int()
+	historyFile    = kingpin.Flag("history.file", "file the history is persisted to, as JSON lines, defaults to history.jsonl in --state.dir").String()
+	historySamples = kingpin.Flag("history.samples", "keep the throughput samples of each result in the history, which makes it much larger").Bool()
+	peakHours      = kingpin.Flag("peak.hours", "hours of the day the averages of the last 7 days of history are split into peak and off-peak ones by, as from-to in 24h, empty disables them").Default("19-23").String()
+	peakTimezone   = kingpin.Flag("peak.timezone", "time zone of --peak.hours, e.g. America/Sao_Paulo, defaults to the local one").String()
+	historyCompact = kingpin.Flag("history.compact", "rewrite --history.file with only the --history.size results kept once it holds this many more, 0 only compacts it on startup").Default("0").Int()
+	signingKey     = kingpin.Flag("history.signing-key", "PEM encoded Ed25519 private key each history entry is signed with, e.g. created by openssl genpkey -algorithm ed25519").ExistingFile()
+	oneShot        = kingpin.Flag("one-shot", "measure once, write the result in --one-shot.format and exit").Bool()
+	oneShotFormat  = kingpin.Flag("one-shot.format", "format of the one-shot result: json (to stdout), k8s-event (a Kubernetes Event) or k8s-configmap (a Kubernetes ConfigMap, via the in-cluster API)").Default("json").Enum("json", "k8s-event", "k8s-configmap")
+	configMapName  = kingpin.Flag("one-shot.configmap", "name of the ConfigMap written by --one-shot.format=k8s-configmap").Default("fastcom-exporter").String()
+	cfgFile        = kingpin.Flag("config.file", "path to the configuration file, defaults to config.yml in --config.dir if it exists").String()
+	configDir      = kingpin.Flag("config.dir", "directory the configuration file is looked up in, defaults to the user one, e.g. $XDG_CONFIG_HOME/fastcom-exporter or %APPDATA%\\fastcom-exporter").String()
+	stateDir       = kingpin.Flag("state.dir", "directory the history is persisted to, defaults to the user one if it can be created, e.g. $XDG_STATE_HOME/fastcom-exporter or %APPDATA%\\fastcom-exporter").String()
+	cacheDir       = kingpin.Flag("cache.dir", "directory the discovery cache is persisted to, defaults to the user one if it can be created, e.g. $XDG_CACHE_HOME/fastcom-exporter or %LOCALAPPDATA%\\fastcom-exporter").String()
+	check          = kingpin.Flag("check-config", "validate the configuration file and flags and exit").Bool()
+	serveCmd       = kingpin.Command("serve", "run the exporter").Default()
+	reportCmd      = kingpin.Command("report", "summarize the history persisted to --history.file")
+	reportPeriod   = reportCmd.Flag("period", "period summarized, ending now").Default("weekly").Enum("daily", "weekly", "monthly")
+	reportFormat   = reportCmd.Flag("format", "report format").Default("markdown").Enum("markdown", "html")
+	importCmd      = kingpin.Command("import", "import results from other tools into --history.file")
+	importFormat   = importCmd.Flag("format", "format of the imported file").Required().Enum("speedtest-cli-csv", "json")
+	importFile     = importCmd.Arg("file", "file to import").Required().ExistingFile()
+	verifyCmd      = kingpin.Command("verify", "verify the signatures of the history persisted to --history.file")
+	verifyKey      = verifyCmd.Flag("public-key", "PEM encoded Ed25519 public key the history was signed with").Required().ExistingFile()
+	pauseCmd       = kingpin.Command("pause", "pause the measurements of a running exporter")
+	pauseFor       = pauseCmd.Flag("for", "resume automatically after this long, 0 to pause until resumed").Default("0s").Duration()
+	pauseURL       = pauseCmd.Flag("url", "URL of the running exporter").Default("http://localhost:9877").String()
+	pauseToken     = pauseCmd.Flag("token", "bearer token of the running exporter API, if it requires one").Envar("FASTCOM_API_TOKEN").String()
+	resumeCmd      = kingpin.Command("resume", "resume the measurements of a running exporter")
+	resumeURL      = resumeCmd.Flag("url", "URL of the running exporter").Default("http://localhost:9877").String()
+	resumeToken    = resumeCmd.Flag("token", "bearer token of the running exporter API, if it requires one").Envar("FASTCOM_API_TOKEN").String()
+	selfUpdateCmd  = kingpin.Command("self-update", "replace the binary with a newer GitHub release, verifying its checksums and their signature")
+	updateCheck    = selfUpdateCmd.Flag("check", "only check whether there is a newer release").Bool()
+	updateKey      = selfUpdateCmd.Flag("public-key", "PEM encoded public key the release checksums signature is verified with, defaults to the release key the binary was built with").ExistingFile()
+	updateSkipSig  = selfUpdateCmd.Flag("insecure-skip-signature", "verify the release checksums only, without their signature").Bool()
+	updateForce    = selfUpdateCmd.Flag("force", "replace the binary even if the release is not newer, e.g. to downgrade or over builds from source").Bool()
+	updateArch     = selfUpdateCmd.Flag("arch", "architecture of the release downloaded, e.g. armv6 or armv7, arm picks the lowest available").Default(runtime.GOARCH).String()
+	version        = "master"
+	commit         = "none"
+	date           = "unknown"
+	builtBy        = "unknown"
+	// releaseKey is the base64 encoded PKIX public key releases are signed
+	// with, set with -ldflags "-X main.releaseKey=...".
+	releaseKey = ""
 )
 
 func main() {
-	kingpin.Version("fastcom-exporter version " + version)
+	kingpin.Version(fmt.Sprintf("fastcom-exporter version %s, commit %s, built at %s by %s", version, commit, date, builtBy))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	if *format == "console" {
@@ -36,26 +175,954 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 		log.Debug().Msg("enabled debug mode")
 	}
+
+	if *cfgFile == "" {
+		*cfgFile = defaultConfigFile()
+	}
+	cfg, err := config.Load(*cfgFile)
+	if err == nil {
+		err = validateFlags()
+	}
+	if *check {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "configuration is invalid:", err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
+
+	if cmd != serveCmd.FullCommand() {
+		if err := defaultFiles(); err != nil {
+			log.Fatal().Err(err).Msg("invalid state or cache directory")
+		}
+	}
+	switch cmd {
+	case reportCmd.FullCommand():
+		if err := runReport(cfg); err != nil {
+			log.Fatal().Err(err).Msg("failed to generate report")
+		}
+		return
+	case importCmd.FullCommand():
+		if err := runImport(); err != nil {
+			log.Fatal().Err(err).Msg("failed to import results")
+		}
+		return
+	case verifyCmd.FullCommand():
+		if err := runVerify(); err != nil {
+			log.Fatal().Err(err).Msg("failed to verify history")
+		}
+		return
+	case pauseCmd.FullCommand():
+		if err := control(*pauseURL+"/api/v1/pause?for="+pauseFor.String(), *pauseToken); err != nil {
+			log.Fatal().Err(err).Msg("failed to pause")
+		}
+		return
+	case resumeCmd.FullCommand():
+		if err := control(*resumeURL+"/api/v1/resume", *resumeToken); err != nil {
+			log.Fatal().Err(err).Msg("failed to resume")
+		}
+		return
+	case selfUpdateCmd.FullCommand():
+		if err := runSelfUpdate(); err != nil {
+			log.Fatal().Err(err).Msg("failed to update")
+		}
+		return
+	}
+
 	log.Info().Msgf("starting fastcom-exporter %s", version)
+	if err := checkPrivileges(); err != nil {
+		log.Fatal().Err(err).Msg("invalid privileges")
+	}
+	var listeners sockets
+	if !*oneShot {
+		listeners, err = listen()
+		if err != nil {
+			log.Fatal().Err(err).Msg("error starting server")
+		}
+	}
+	if *runAs != "" {
+		if err := dropPrivileges(*runAs); err != nil {
+			log.Fatal().Err(err).Msg("failed to drop privileges")
+		}
+		log.Info().Msgf("running as %s", *runAs)
+	}
+	if err := defaultFiles(); err != nil {
+		log.Fatal().Err(err).Msg("invalid state or cache directory")
+	}
+	started := time.Now()
+	var lifecycle *restarts
+	if *restartsFile != "" {
+		lifecycle, err = startRestarts(*restartsFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid restarts file")
+		}
+	}
+	exit := lifecycle.exit
+	journal := lifecycleJournal(cfg.Sinks)
+	if journal != nil {
+		if err := journal.Lifecycle(context.Background(), sinks.Started, version); err != nil {
+			log.Error().Err(err).Msg("failed to write to journald")
+		}
+		exit = func(code int) {
+			if err := journal.Lifecycle(context.Background(), sinks.Stopped, version); err != nil {
+				log.Error().Err(err).Msg("failed to write to journald")
+			}
+			lifecycle.exit(code)
+		}
+	}
+	if lifecycle != nil || journal != nil {
+		handleShutdown(exit)
+	}
+
+	if *lowResource {
+		applyLowResourceProfile()
+	}
+
+	opts := collector.Options{
+		Schedule: collector.Schedule{
+			Interval:     *interval,
+			StartupDelay: *delay,
+			Jitter:       *jitter,
+			MaxBackoff:   *maxBackoff,
+		},
+		Measure: fast.Options{
+			Connections:    *connections,
+			Strategy:       fast.Strategy(*strategy),
+			Seed:           *seed,
+			Estimator:      speedEstimator(*estimator),
+			MaxDuration:    *maxDuration,
+			MaxBytes:       int64(*maxBytes),
+			MaxSamples:     *maxSamples,
+			BufferSize:     int(*bufferSize),
+			UserAgent:      *userAgent,
+			Headers:        parseHeaders(*headers),
+			CaptureHeaders: *captureHeader,
+			Checksum:       *checksum || len(*knownChecksums) > 0,
+			KnownChecksums: *knownChecksums,
+			Traceroute:     *traceroute,
+			TCPInfo:        *tcpInfo,
+			Thermal:        *thermal,
+			WiFi:           *wifi,
+			Peering:        *peering,
+			Adaptive:       *adaptive,
+			ReplaceSlowest: *replaceSlow,
+		},
+		Sinks:      buildSinks(cfg.Sinks, cfg.Thresholds),
+		Duplex:     *duplex,
+		Timestamps: *timestamps,
+	}
+	if *onComplete != "" {
+		// first, so it runs right after the measurement
+		opts.Sinks = append([]sinks.Sink{sinks.NewExec(*onComplete)}, opts.Sinks...)
+	}
+	opts.Encapsulation = lineEncapsulation()
+	opts.Gateway = *gateway
+	opts.ExcludeProcesses = *exclude
+	opts.CacheTTL = *cacheTTL
+	if *engine != "" {
+		opts.Engine = fast.Engine(*engine)
+		opts.Measure = opts.Measure.WithEngine(opts.Engine)
+	}
+	opts.CompareEngines = *compareEngines
+	if db := openGeoIP(*geoipDB); db != nil {
+		opts.Measure.GeoIP = db
+		opts.Measure.ASN = db
+	}
+	if *publicIP != "none" {
+		opts.PublicIP = &collector.PublicIP{
+			Hash: *publicIP == "hashed",
+			Salt: *publicIPSalt,
+		}
+	}
+	if *burst {
+		opts.Measure = opts.Measure.Burst()
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *netnsName != "" {
+		var err error
+		transport, err = netns.Transport(*netnsName)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid network namespace")
+		}
+	}
+	if err := configureTLS(transport); err != nil {
+		log.Fatal().Err(err).Msg("invalid tls configuration")
+	}
+	if *dohURL != "" {
+		// the resolver goes through the same namespace and CAs
+		resolver := doh.New(*dohURL, &http.Client{Transport: transport.Clone()})
+		transport.DialContext = resolver.Dial(transport.DialContext)
+	}
+	if *netnsName != "" || tlsMode() != "verified" || *dohURL != "" {
+		opts.Measure.Client = &http.Client{Transport: transport}
+	}
+	if *tlsInsecure {
+		log.Warn().Msg("tls verification is disabled, measurements might be intercepted")
+	}
+	if *chaosMode {
+		log.Warn().Float64("rate", *chaosRate).Msg("chaos mode is enabled, injecting faults into requests")
+		opts.Measure.Client = &http.Client{Transport: chaos.New(transport, *chaosRate)}
+	}
+	if *discoveryFile != "" {
+		cache, err := fast.NewDiscoveryCache(*discoveryFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid discovery cache")
+		}
+		opts.Measure.DiscoveryCache = cache
+	}
+	if *linkCheckURL != "" {
+		opts.LinkCheck = &fast.LinkCheck{
+			URL:     *linkCheckURL,
+			Timeout: *linkTimeout,
+		}
+	}
+	if *captiveURL != "" {
+		opts.CaptivePortal = &fast.CaptivePortalCheck{
+			URL:    *captiveURL,
+			Expect: *captiveWant,
+		}
+	}
+	if *historySize > 0 {
+		store, err := history.New(*historySize, *historyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid history")
+		}
+		if *signingKey != "" {
+			key, err := loadSigningKey(*signingKey)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid history signing key")
+			}
+			store.SetSigningKey(key)
+		}
+		store.SetCompaction(*historyCompact)
+		opts.History = store
+		opts.PeakHours, _ = loadPeakHours()
+		opts.HistorySamples = *historySamples
+	}
+	for _, p := range cfg.Providers {
+		opts.Providers = append(opts.Providers, collector.Provider{
+			Name:    p.Name,
+			Targets: fast.TargetsOf(p.URLs...),
+		})
+	}
+	if *eventsSize > 0 {
+		events, err := history.NewEvents(*eventsSize, *eventsFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid events")
+		}
+		opts.Events = events
+	}
+	if *output == "ndjson" {
+		opts.Output = os.Stdout
+	}
+	uploadOpts := fast.UploadOptions{
+		Size:      int64(*uploadSize),
+		ChunkSize: int64(*uploadChunk),
+		Random:    *uploadRandom,
+	}
+	if uploadEnabled() {
+		opts.Upload = &uploadOpts
+	}
+	if *continuous {
+		opts.Continuous = &fast.ContinuousOptions{
+			Interval: *contInterval,
+			Window:   *contWindow,
+		}
+	}
+	if *latencyEvery > 0 || enabled("latency") || latencyOnlyMode() {
+		opts.Latency = &collector.LatencyProbe{
+			Interval: *latencyEvery,
+			Latency:  fast.LatencyOptions{Probes: *latencyProbes},
+		}
+		opts.LatencyOnly = latencyOnlyMode()
+	}
+	var fastCollector *collector.FastCollector
+	var bot *telegram.Bot
+	if t := cfg.Telegram; t != nil {
+		bot = telegram.New(t.Token, t.Chats, func() (sinks.Result, error) {
+			result, err := fastCollector.Trigger()
+			return result.Summary(), err
+		})
+		if cfg.Thresholds.DownloadMbps > 0 || cfg.Thresholds.UploadMbps > 0 {
+			opts.Sinks = append(opts.Sinks, sinks.OnlyBelow(bot, mbpsToBytes(cfg.Thresholds.DownloadMbps), mbpsToBytes(cfg.Thresholds.UploadMbps)))
+		}
+	}
+	fastCollector = collector.NewFastCollector(cache.New(*interval, *interval), opts)
+	if *oneShot {
+		if err := runOneShot(fastCollector, *oneShotFormat); err != nil {
+			log.Fatal().Err(err).Msg("one-shot measurement failed")
+		}
+		return
+	}
+	if *mode == "background" && !latencyOnlyMode() {
+		go fastCollector.Run(context.Background())
+	}
+	go fastCollector.RunContinuous(context.Background())
+	go fastCollector.RunLatency(context.Background())
+	profiles := []profile{{name: config.DefaultProfile, collector: fastCollector}}
+	for _, p := range cfg.Profiles {
+		c := collector.NewFastCollector(cache.New(p.Interval, p.Interval), profileOptions(opts, p, uploadOpts))
+		go c.Run(context.Background())
+		profiles = append(profiles, profile{name: p.Name, collector: c})
+	}
+	handlePauseSignals(profiles)
+	if bot != nil {
+		go bot.Run(context.Background())
+	}
+	registry := newRegistry(*goMetrics, *processMetrics, tlsMode(), resolverMode())
+	registry.MustRegister(lifecycle.collectors(started)...)
+	http.Handle("/metrics", instrument("metrics", metricsHandler(registry, profiles, cfg.Labels, *timestamps)))
+	http.Handle("/api/v1/status", instrument("status", statusHandler(fastCollector)))
+	http.Handle("/api/v1/results/latest", instrument("results", exporter.LatestResultHandler(fastCollector)))
+	http.Handle("/api/v1/measure", instrument("measure", requireToken(cfg.API.Tokens, exporter.MeasureHandler(fastCollector))))
+	http.Handle("/api/v1/pause", instrument("pause", requireToken(cfg.API.Tokens, pauseHandler(profiles))))
+	http.Handle("/api/v1/resume", instrument("resume", requireToken(cfg.API.Tokens, resumeHandler(profiles))))
+	http.Handle("/api/v1/history", instrument("history", historyHandler(opts.History)))
+	http.Handle("/api/v1/events", instrument("events", eventsHandler(opts.Events)))
+	http.Handle("/report", instrument("report", reportHandler(opts.History, *historyFile, cfg.Thresholds)))
+	external, _ := url.Parse(*externalURL)
+	if *externalURL == "" {
+		// links are relative to the route prefix then
+		external.Path = webRoutePrefix()
+	}
+	http.Handle("/sd", instrument("sd", sdHandler(cfg.Labels, external)))
+	http.Handle("/grafana/dashboard.json", instrument("grafana", dashboardHandler(newDashboard(opts, cfg.Labels))))
+	http.Handle("/rules.yaml", instrument("rules", rulesHandler(newRules(opts, cfg))))
+	http.Handle("/", instrument("index", indexHandler(fastCollector, strings.TrimSuffix(external.Path, "/"))))
 
-	prometheus.MustRegister(collector.NewFastCollector(cache.New(*interval, *interval)))
-	http.Handle("/metrics", promhttp.Handler())
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(
-			w, `
-			<html>
-			<head><title>Fast.com Exporter</title></head>
-			<body>
-				<h1>Fast.com Exporter</h1>
-				<p><a href="/metrics">Metrics</a></p>
-			</body>
-			</html>
-			`,
-		)
-	})
-	log.Info().Msgf("listening on %s", *bind)
-	if err := http.ListenAndServe(*bind, nil); err != nil {
+	if *webPprof {
+		log.Warn().Msg("profiling endpoints are enabled at " + pprofPrefix)
+	}
+	handler := withCORS(cfg.API.CORSOrigins, withPprof(*webPprof, cfg.API.Tokens, http.DefaultServeMux))
+	if *idleExit > 0 {
+		handler = exitWhenIdle(handler, *idleExit, exit)
+	}
+	if err := serve(listeners, handler); err != nil {
 		log.Fatal().Err(err).Msg("error starting server")
 	}
 }
+
+// profileOptions returns the collector options of the given profile, based
+// on the ones configured by flags.
+// Profiles only measure fast.com and export metrics: results are not written
+// to sinks, the output, the events nor the history.
+func profileOptions(opts collector.Options, p config.Profile, upload fast.UploadOptions) collector.Options {
+	opts.Schedule = collector.Schedule{
+		Interval:   p.Interval,
+		Jitter:     p.Jitter,
+		MaxBackoff: opts.Schedule.MaxBackoff,
+	}
+	if p.Connections > 0 {
+		opts.Measure.Connections = p.Connections
+	}
+	if p.MaxDuration > 0 {
+		opts.Measure.MaxDuration = p.MaxDuration
+	}
+	if p.Strategy != "" {
+		opts.Measure.Strategy = fast.Strategy(p.Strategy)
+	}
+	if p.Burst {
+		opts.Measure = opts.Measure.Burst()
+	}
+	opts.Upload = nil
+	if p.Upload {
+		opts.Upload = &upload
+	}
+	opts.Sinks = nil
+	opts.Output = nil
+	opts.Events = nil
+	opts.Providers = nil
+	opts.CompareEngines = false
+	opts.History = nil
+	opts.Continuous = nil
+	return opts
+}
+
+// resolverMode describes how the host names of the discovery and
+// measurement requests are resolved, as exported in the build info metric.
+func resolverMode() string {
+	if *dohURL != "" {
+		return "doh"
+	}
+	return "system"
+}
+
+// webRoutePrefix returns the path prefix of all endpoints, without trailing
+// slash.
+func webRoutePrefix() string {
+	prefix := *routePrefix
+	if prefix == "" {
+		if u, err := url.Parse(*externalURL); err == nil {
+			prefix = u.Path
+		}
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// defaultConfigFile returns config.yml in --config.dir, or in the user
+// configuration directory, if it exists.
+func defaultConfigFile() string {
+	dir := *configDir
+	if dir == "" {
+		dir = dirs.Config()
+	}
+	if dir == "" {
+		return ""
+	}
+	path := filepath.Join(dir, "config.yml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// defaultFiles sets the history and discovery cache files not given by
+// flags to their default paths, in --state.dir and --cache.dir.
+// With --run-as, it must be called once privileges are dropped, so the
+// directories are the ones of the user switched to.
+func defaultFiles() error {
+	var err error
+	if *historyFile == "" {
+		if *historyFile, err = defaultFile(*stateDir, dirs.State, "history.jsonl"); err != nil {
+			return err
+		}
+	}
+	if *discoveryFile == "" {
+		if *discoveryFile, err = defaultFile(*cacheDir, dirs.Cache, "discovery.json"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultFile returns the path of the named file in dir, or in the user
+// directory returned by fallback if dir is empty, creating it if needed.
+// It is empty if the user directory can not be created, e.g. in containers
+// without a home directory, or with --low-resource, which avoids writing to
+// the storage of small devices, so the file is not persisted.
+func defaultFile(dir string, fallback func() string, name string) (string, error) {
+	if dir != "" {
+		return dirs.File(dir, name)
+	}
+	if *lowResource {
+		return "", nil
+	}
+	dir = fallback()
+	if dir == "" {
+		return "", nil
+	}
+	path, err := dirs.File(dir, name)
+	if err != nil {
+		log.Debug().Err(err).Msgf("not persisting %s", name)
+		return "", nil
+	}
+	log.Debug().Msgf("persisting %s to %s", name, dir)
+	return path, nil
+}
+
+// sockets are the listeners the exporter serves on.
+type sockets struct {
+	web []net.Listener
+	// api is only set with --api.bind.
+	api net.Listener
+}
+
+// listen returns the sockets passed by systemd, if socket activated, or
+// binds the bind address otherwise, along with --api.bind if set, so they
+// are bound before privileges are dropped.
+func listen() (sockets, error) {
+	var s sockets
+	var err error
+	if s.web, err = activation.Listeners(); err != nil {
+		return s, err
+	}
+	for _, l := range s.web {
+		log.Info().Msgf("listening on socket activated %s", l.Addr())
+	}
+	if len(s.web) == 0 {
+		l, err := net.Listen("tcp", *bind)
+		if err != nil {
+			return s, err
+		}
+		log.Info().Msgf("listening on %s", *bind)
+		s.web = []net.Listener{l}
+	}
+	if *apiBind != "" {
+		if s.api, err = net.Listen("tcp", *apiBind); err != nil {
+			return s, err
+		}
+		log.Info().Msgf("listening for api requests on %s", *apiBind)
+	}
+	return s, nil
+}
+
+// serve serves handler on the sockets.
+// With --api.bind, the API is served on its own socket instead.
+func serve(s sockets, handler http.Handler) error {
+	errs := make(chan error, len(s.web)+1)
+	web, api := splitHandlers(handler, webRoutePrefix(), s.api != nil)
+	if api != nil {
+		go func() { errs <- http.Serve(s.api, api) }()
+	}
+	for _, l := range s.web {
+		go func(l net.Listener) {
+			errs <- http.Serve(l, web)
+		}(l)
+	}
+	return <-errs
+}
+
+// splitHandlers returns the handlers of the web and, if split is set, api
+// listeners, serving handler under the route prefix.
+// The prefix is stripped before the paths are split, so the /api/ paths are
+// matched without it.
+func splitHandlers(handler http.Handler, prefix string, split bool) (web, api http.Handler) {
+	web = handler
+	if split {
+		web = apiPaths(handler, false)
+		api = apiPaths(handler, true)
+	}
+	if prefix != "" {
+		web = withRoutePrefix(prefix, web)
+		if api != nil {
+			api = withRoutePrefix(prefix, api)
+		}
+	}
+	return web, api
+}
+
+func buildSinks(cfgs []config.Sink, thresholds config.Thresholds) []sinks.Sink {
+	var result []sinks.Sink
+	for _, cfg := range cfgs {
+		var s sinks.Sink
+		switch {
+		case cfg.Webhook != nil:
+			s = sinks.NewWebhook(cfg.Webhook.URL, cfg.Webhook.Headers)
+		case cfg.HTTP != nil:
+			var err error
+			s, err = sinks.NewTemplate(sinks.TemplateOptions{
+				URL:     cfg.HTTP.URL,
+				Method:  cfg.HTTP.Method,
+				Headers: cfg.HTTP.Headers,
+				Body:    cfg.HTTP.Body,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid http sink")
+			}
+		case cfg.Journald != nil:
+			s = sinks.NewJournald(cfg.Journald.Identifier)
+		case cfg.Email != nil:
+			s = sinks.NewEmail(sinks.EmailOptions{
+				Addr:     cfg.Email.Addr,
+				Username: cfg.Email.Username,
+				Password: cfg.Email.Password,
+				From:     cfg.Email.From,
+				To:       cfg.Email.To,
+				Every:    cfg.Email.Every,
+			})
+		}
+		if cfg.OnlyBreaches {
+			s = sinks.OnlyBelow(s, mbpsToBytes(thresholds.DownloadMbps), mbpsToBytes(thresholds.UploadMbps))
+		}
+		if cfg.MinChange > 0 {
+			s = sinks.OnlyChanges(s, cfg.MinChange)
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// lifecycleJournal returns the journal the exporter lifecycle events are
+// written to, the first journald sink, nil if there is none.
+func lifecycleJournal(cfgs []config.Sink) *sinks.Journald {
+	for _, cfg := range cfgs {
+		if cfg.Journald != nil {
+			return sinks.NewJournald(cfg.Journald.Identifier)
+		}
+	}
+	return nil
+}
+
+// mbpsToBytes converts Mbps to B/s.
+func mbpsToBytes(mbps float64) float64 {
+	return float64(speed.FromMbps(mbps))
+}
+
+// parseHeaders parses 'Name: value' headers, which are validated by
+// validateFlags.
+func parseHeaders(headers []string) http.Header {
+	result := http.Header{}
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		result.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return result
+}
+
+// applyLowResourceProfile caps the settings that use the most memory and CPU.
+func applyLowResourceProfile() {
+	const (
+		maxConnections = 2
+		maxBufferSize  = 4 * units.KiB
+		maxChunkSize   = 4 * units.MiB
+		maxHistorySize = 100
+		maxKeptSamples = 40
+	)
+	if *connections > maxConnections {
+		*connections = maxConnections
+	}
+	if *bufferSize > maxBufferSize {
+		*bufferSize = maxBufferSize
+	}
+	if *uploadChunk > maxChunkSize {
+		*uploadChunk = maxChunkSize
+	}
+	if *historySize > maxHistorySize {
+		*historySize = maxHistorySize
+	}
+	if *maxSamples == 0 || *maxSamples > maxKeptSamples {
+		*maxSamples = maxKeptSamples
+	}
+	if *historyCompact == 0 {
+		*historyCompact = *historySize
+	}
+	log.Info().
+		Int("connections", *connections).
+		Str("buffer_size", bufferSize.String()).
+		Str("upload_chunk_size", uploadChunk.String()).
+		Int("history_size", *historySize).
+		Int("max_samples", *maxSamples).
+		Int("history_compact", *historyCompact).
+		Msg("low resource mode enabled")
+}
+
+// loadPeakHours parses --peak.hours in --peak.timezone, nil if disabled.
+func loadPeakHours() (*history.PeakHours, error) {
+	if *peakHours == "" {
+		return nil, nil
+	}
+	location := time.Local
+	if *peakTimezone != "" {
+		var err error
+		if location, err = time.LoadLocation(*peakTimezone); err != nil {
+			return nil, fmt.Errorf("peak.timezone: %w", err)
+		}
+	}
+	hours, err := history.ParsePeakHours(*peakHours, location)
+	if err != nil {
+		return nil, fmt.Errorf("peak.hours: %w", err)
+	}
+	return &hours, nil
+}
+
+// nolint: gochecknoglobals
+var phases = []string{"download", "upload", "latency"}
+
+func isPhase(name string) bool {
+	for _, phase := range phases {
+		if phase == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled returns whether the phase is enabled with --collector.enable.
+func enabled(phase string) bool {
+	for _, name := range strings.Split(*enable, ",") {
+		if strings.TrimSpace(name) == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func uploadEnabled() bool {
+	return *upload || enabled("upload")
+}
+
+// latencyOnlyMode returns whether throughput measurements are skipped, with
+// --latency-only or without the download phase.
+func latencyOnlyMode() bool {
+	return *latencyOnly || !enabled("download")
+}
+
+func validateFlags() error {
+	if *interval <= 0 {
+		return fmt.Errorf("refresh.interval must be positive, got %s", *interval)
+	}
+	if *jitter < 0 {
+		return fmt.Errorf("refresh.jitter must not be negative, got %s", *jitter)
+	}
+	if *maxBackoff < 0 {
+		return fmt.Errorf("refresh.max-backoff must not be negative, got %s", *maxBackoff)
+	}
+	for _, phase := range strings.Split(*enable, ",") {
+		if phase = strings.TrimSpace(phase); !isPhase(phase) {
+			return fmt.Errorf("invalid collector.enable phase %q, expected download, upload or latency", phase)
+		}
+	}
+	if !enabled("download") && uploadEnabled() {
+		return errors.New("the upload phase requires the download phase")
+	}
+	if !enabled("download") && !enabled("latency") && !*latencyOnly {
+		return errors.New("collector.enable must enable download or latency")
+	}
+	if *duplex && !uploadEnabled() {
+		return errors.New("upload.duplex requires --upload")
+	}
+	if *output != "none" && *oneShot {
+		return errors.New("output can not be used with --one-shot, which writes its result already")
+	}
+	if *compareEngines && *duplex {
+		return errors.New("engine.compare can not be used with --upload.duplex")
+	}
+	if *chaosRate < 0 || *chaosRate > 1 {
+		return fmt.Errorf("chaos.rate must be between 0 and 1, got %v", *chaosRate)
+	}
+	if *chaosMode && *tcpInfo {
+		return errors.New("chaos can not be used with --tcp-info")
+	}
+	if *cacheTTL < 0 {
+		return fmt.Errorf("cache-ttl must not be negative, got %s", *cacheTTL)
+	}
+	if *cacheTTL > 0 && *mode == "background" {
+		return errors.New("cache-ttl only applies to --mode=scrape")
+	}
+	if *timestamps && *mode != "background" {
+		return errors.New("metrics.timestamps requires --mode=background")
+	}
+	if *connections <= 0 {
+		return fmt.Errorf("measure.connections must be positive, got %d", *connections)
+	}
+	if *maxDuration <= 0 {
+		return fmt.Errorf("measure.max-duration must be positive, got %s", *maxDuration)
+	}
+	if *maxBytes < 0 {
+		return fmt.Errorf("measure.max-bytes must not be negative, got %s", *maxBytes)
+	}
+	if *bufferSize <= 0 {
+		return fmt.Errorf("measure.buffer-size must be positive, got %s", *bufferSize)
+	}
+	if *continuous && *contWindow >= *contInterval {
+		return fmt.Errorf("continuous.window must be shorter than continuous.interval, got %s", *contWindow)
+	}
+	if *latencyEvery < 0 {
+		return fmt.Errorf("latency.interval must not be negative, got %s", *latencyEvery)
+	}
+	if *latencyProbes <= 0 {
+		return fmt.Errorf("latency.probes must be positive, got %d", *latencyProbes)
+	}
+	if *idleExit < 0 {
+		return fmt.Errorf("idle-exit must not be negative, got %s", *idleExit)
+	}
+	if *eventsSize < 0 {
+		return fmt.Errorf("events.size must not be negative, got %d", *eventsSize)
+	}
+	if *historySize < 0 {
+		return fmt.Errorf("history.size must not be negative, got %d", *historySize)
+	}
+	if *historyCompact < 0 {
+		return fmt.Errorf("history.compact must not be negative, got %d", *historyCompact)
+	}
+	if *maxSamples < 0 || *maxSamples == 1 {
+		return fmt.Errorf("measure.max-samples must be 0 or at least 2, got %d", *maxSamples)
+	}
+	if _, err := loadPeakHours(); err != nil {
+		return err
+	}
+	if *uploadChunk <= 0 {
+		return fmt.Errorf("upload.chunk-size must be positive, got %s", *uploadChunk)
+	}
+	for _, header := range *headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid header %q, expected 'Name: value'", header)
+		}
+	}
+	if *linkCheckURL != "" {
+		if err := config.ValidateURL(*linkCheckURL); err != nil {
+			return fmt.Errorf("link-check.url: %w", err)
+		}
+	}
+	if *linkTimeout <= 0 {
+		return fmt.Errorf("link-check.timeout must be positive, got %s", *linkTimeout)
+	}
+	if *captiveURL != "" {
+		if err := config.ValidateURL(*captiveURL); err != nil {
+			return fmt.Errorf("captive-portal.url: %w", err)
+		}
+	}
+	if *dohURL != "" {
+		if err := config.ValidateURL(*dohURL); err != nil {
+			return fmt.Errorf("dns.doh-url: %w", err)
+		}
+	}
+	if *publicIP == "hashed" && *publicIPSalt == "" {
+		return errors.New("public-ip=hashed requires --public-ip.salt, unsalted hashes can be reversed by hashing every IPv4 address")
+	}
+	if *traceroute && *netnsName != "" {
+		return errors.New("traceroute can not be used with --netns, it would probe the path from the namespace of the exporter instead")
+	}
+	if *gateway && *netnsName != "" {
+		return errors.New("gateway can not be used with --netns, it would probe the gateway of the namespace of the exporter instead")
+	}
+	if *netnsName != "" {
+		if _, err := netns.Dialer(*netnsName); err != nil {
+			return err
+		}
+	}
+	if *runAs != "" && *netnsName != "" {
+		return errors.New("run-as can not be used with --netns, which switches network namespaces as root for every measurement")
+	}
+	if *delay < 0 {
+		return fmt.Errorf("refresh.startup-delay must not be negative, got %s", *delay)
+	}
+	if _, _, err := net.SplitHostPort(*bind); err != nil {
+		return fmt.Errorf("invalid bind address %q: %w", *bind, err)
+	}
+	if *externalURL != "" {
+		if err := config.ValidateURL(*externalURL); err != nil {
+			return fmt.Errorf("web.external-url: %w", err)
+		}
+	}
+	if *apiBind != "" {
+		if _, _, err := net.SplitHostPort(*apiBind); err != nil {
+			return fmt.Errorf("invalid api.bind address %q: %w", *apiBind, err)
+		}
+	}
+	return nil
+}
+
+func strategies() []string {
+	result := make([]string, 0, len(fast.Strategies))
+	for _, s := range fast.Strategies {
+		result = append(result, string(s))
+	}
+	return result
+}
+
+// engines are the values of --engine.
+func engines() []string {
+	result := make([]string, 0, len(fast.Engines))
+	for _, e := range fast.Engines {
+		result = append(result, string(e))
+	}
+	return result
+}
+
+// encapsulations are the values of --measure.encapsulation.
+func encapsulations() []string {
+	result := []string{"none"}
+	for _, e := range fast.Encapsulations {
+		result = append(result, string(e))
+	}
+	return result
+}
+
+// openGeoIP opens the GeoIP databases that exist, nil if none does, so
+// measuring goes on without them.
+func openGeoIP(paths []string) *geoip.DB {
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			log.Warn().Err(err).Msg("geoip database not found, ignoring it")
+			continue
+		}
+		existing = append(existing, path)
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	db, err := geoip.Open(existing...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid geoip database")
+	}
+	log.Info().Strs("types", db.Types()).Msg("loaded geoip databases")
+	return db
+}
+
+// lineEncapsulation is the encapsulation set with --measure.encapsulation,
+// empty for none.
+func lineEncapsulation() fast.Encapsulation {
+	if *encapsulation == "none" {
+		return ""
+	}
+	return fast.Encapsulation(*encapsulation)
+}
+
+func speedEstimator(name string) fast.SpeedEstimator {
+	switch name {
+	case "stable-window":
+		return fast.StableWindowEstimator{}
+	case "percentile":
+		return fast.PercentileEstimator{}
+	default:
+		return nil
+	}
+}
+
+func runReport(cfg *config.Config) error {
+	if *historyFile == "" {
+		return errors.New("report requires --history.file")
+	}
+	entries, err := history.Load(*historyFile)
+	if err != nil {
+		return err
+	}
+	r, err := newReport(entries, *reportPeriod, time.Now(), cfg.Thresholds)
+	if err != nil {
+		return err
+	}
+	return writeReport(os.Stdout, r, *reportFormat)
+}
+
+// control POSTs to an endpoint of a running exporter.
+func control(url, token string) error {
+	req, err := http.NewRequest(http.MethodPost, url, nil) // nolint: noctx
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func runImport() error {
+	if *historyFile == "" {
+		return errors.New("import requires --history.file")
+	}
+	f, err := os.Open(*importFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := importers[*importFormat](f)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", *importFile, err)
+	}
+	added, err := history.Merge(*historyFile, entries)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("results", added).Msgf("imported %s", *importFile)
+	return nil
+}
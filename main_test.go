@@ -0,0 +1,137 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/caarlos0/fastcom-exporter/internal/config"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/caarlos0/fastcom-exporter/pkg/sinks"
+)
+
+func TestProfileOptions(t *testing.T) {
+	flags := collector.Options{
+		Schedule: collector.Schedule{Interval: time.Hour, Jitter: time.Minute},
+		Measure:  fast.Options{Connections: 8, MaxDuration: 30 * time.Second, Strategy: fast.Strategies[0]},
+		Upload:   &fast.UploadOptions{},
+		Sinks:    make([]sinks.Sink, 1),
+		History:  &history.Store{},
+	}
+	upload := fast.UploadOptions{}
+	for _, tt := range []struct {
+		name    string
+		profile config.Profile
+		check   func(t *testing.T, opts collector.Options)
+	}{
+		{
+			name:    "flags",
+			profile: config.Profile{Name: "light", Interval: 6 * time.Hour},
+			check: func(t *testing.T, opts collector.Options) {
+				if opts.Schedule.Interval != 6*time.Hour || opts.Schedule.Jitter != 0 {
+					t.Errorf("expected the profile schedule, got %+v", opts.Schedule)
+				}
+				if opts.Measure.Connections != 8 || opts.Measure.MaxDuration != 30*time.Second || opts.Measure.Strategy != fast.Strategies[0] {
+					t.Errorf("expected the flag measure options, got %+v", opts.Measure)
+				}
+				if opts.Upload != nil {
+					t.Errorf("expected no upload, got %+v", opts.Upload)
+				}
+			},
+		},
+		{
+			name: "overrides",
+			profile: config.Profile{
+				Name:        "heavy",
+				Interval:    time.Hour,
+				Jitter:      time.Second,
+				Connections: 16,
+				MaxDuration: time.Minute,
+				Strategy:    string(fast.Strategies[len(fast.Strategies)-1]),
+				Upload:      true,
+			},
+			check: func(t *testing.T, opts collector.Options) {
+				if opts.Schedule.Jitter != time.Second {
+					t.Errorf("expected the profile jitter, got %+v", opts.Schedule)
+				}
+				if opts.Measure.Connections != 16 || opts.Measure.MaxDuration != time.Minute || opts.Measure.Strategy != fast.Strategies[len(fast.Strategies)-1] {
+					t.Errorf("expected the profile measure options, got %+v", opts.Measure)
+				}
+				if opts.Upload == nil {
+					t.Errorf("expected the upload flags, got %+v", opts.Upload)
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := profileOptions(flags, tt.profile, upload)
+			// profiles only export metrics
+			if opts.Sinks != nil || opts.History != nil {
+				t.Errorf("expected no sinks nor history, got %+v", opts)
+			}
+			tt.check(t, opts)
+		})
+	}
+}
+
+func TestDefaultFile(t *testing.T) {
+	home := t.TempDir()
+	fallback := func() string { return filepath.Join(home, "state") }
+	for _, tt := range []struct {
+		name        string
+		dir         string
+		lowResource bool
+		want        string
+	}{
+		{name: "default", want: filepath.Join(home, "state", "history.jsonl")},
+		{name: "explicit", dir: filepath.Join(home, "explicit"), want: filepath.Join(home, "explicit", "history.jsonl")},
+		{name: "low resource", lowResource: true},
+		{name: "low resource explicit", dir: filepath.Join(home, "explicit"), lowResource: true, want: filepath.Join(home, "explicit", "history.jsonl")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			old := *lowResource
+			*lowResource = tt.lowResource
+			defer func() { *lowResource = old }()
+			got, err := defaultFile(tt.dir, fallback, "history.jsonl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		valid bool
+	}{
+		{args: nil, valid: true},
+		{args: []string{"--public-ip=plain"}, valid: true},
+		{args: []string{"--public-ip=hashed", "--public-ip.salt=secret"}, valid: true},
+		{args: []string{"--public-ip=hashed"}},
+		{args: []string{"--traceroute", "--netns=other"}},
+		{args: []string{"--gateway", "--netns=other"}},
+	} {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			// flags without defaults keep the values of previous parses
+			*publicIPSalt, *netnsName, *traceroute, *gateway = "", "", false, false
+			if _, err := kingpin.CommandLine.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := validateFlags()
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected the flags to be invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/internal/kube"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/rs/zerolog/log"
+)
+
+const oneShotTimeout = 30 * time.Second
+
+// runOneShot measures once and writes the result in the given format,
+// meant for periodic jobs, e.g. Kubernetes CronJobs.
+// The measurement error is returned after the result is written, if any.
+func runOneShot(c *collector.FastCollector, format string) error {
+	result, measureErr := c.TriggerFrom(collector.TriggerCLI)
+	var err error
+	switch format {
+	case "json":
+		if measureErr == nil {
+			err = json.NewEncoder(os.Stdout).Encode(result)
+		}
+	case "k8s-event", "k8s-configmap":
+		err = writeKube(result, measureErr, format)
+	}
+	if measureErr != nil {
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write the one-shot result")
+		}
+		return measureErr
+	}
+	return err
+}
+
+func writeKube(result collector.Result, measureErr error, format string) error {
+	client, err := kube.InCluster()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oneShotTimeout)
+	defer cancel()
+
+	if format == "k8s-configmap" {
+		if measureErr != nil {
+			// keep the last successful result
+			return nil
+		}
+		bts, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		return client.ApplyConfigMap(ctx, *configMapName, map[string]string{
+			"result.json": string(bts),
+		})
+	}
+
+	event := kube.Event{
+		Pod:     podName(),
+		Reason:  "SpeedMeasured",
+		Message: summarize(result),
+	}
+	if measureErr != nil {
+		event.Warning = true
+		event.Reason = "MeasurementFailed"
+		event.Message = measureErr.Error()
+	}
+	return client.CreateEvent(ctx, event)
+}
+
+// podName returns the name of the pod, as set by the downward API in
+// POD_NAME or, by default, the hostname.
+func podName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+func summarize(result collector.Result) string {
+	msg := "download " + result.Download.BytesPerSecond().Human()
+	if result.Upload != nil {
+		msg += ", upload " + result.Upload.BytesPerSecond().Human()
+	}
+	return msg + " (measurement " + result.ID + ")"
+}
@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/rs/zerolog/log"
+)
+
+// PublicIP configures the public IP metrics.
+type PublicIP struct {
+	// Hash exports a hash of the IP instead of the IP itself, so changes are
+	// visible without revealing it.
+	Hash bool
+	// Salt is hashed along with the IP, so the hashes of the few billion
+	// IPv4 addresses cannot be precomputed.
+	// It must be a secret when hashing, as anyone can reverse unsalted
+	// hashes.
+	Salt string
+}
+
+// label returns the IP as exported.
+func (p *PublicIP) label(ip string) string {
+	if !p.Hash {
+		return ip
+	}
+	sum := sha256.Sum256([]byte(p.Salt + ip))
+	return hex.EncodeToString(sum[:8])
+}
+
+// trackClient counts the changes of the public IP and location since the
+// previous measurement.
+func (c *FastCollector) trackClient(ctx context.Context, client *fast.Client) {
+	if c.opts.PublicIP == nil || client == nil {
+		return
+	}
+	c.statusMutex.Lock()
+	last := c.client
+	c.client = client
+	c.statusMutex.Unlock()
+	if last == nil {
+		return
+	}
+	if last.IP != client.IP {
+		c.ipChanges.Inc()
+		log.Ctx(ctx).Info().Str("ip", c.opts.PublicIP.label(client.IP)).Msg("public ip changed")
+	}
+	if last.City != client.City || last.Country != client.Country {
+		c.locationChanges.Inc()
+		log.Ctx(ctx).Info().Str("city", client.City).Str("country", client.Country).Msg("location changed")
+	}
+}
+
+// publicClient returns a copy of the client with its IP as exported, removed
+// without Options.PublicIP.
+func (c *FastCollector) publicClient(client *fast.Client) *fast.Client {
+	if client == nil {
+		return nil
+	}
+	public := *client
+	public.IP = ""
+	if c.opts.PublicIP != nil {
+		public.IP = c.opts.PublicIP.label(client.IP)
+	}
+	return &public
+}
+
+// scrubClients replaces the clients in the result by their public copies.
+func (c *FastCollector) scrubClients(result *Result) {
+	result.Download.Client = c.publicClient(result.Download.Client)
+	if result.Upload != nil {
+		upload := *result.Upload
+		upload.Client = c.publicClient(upload.Client)
+		result.Upload = &upload
+	}
+}
@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/rs/zerolog/log"
+)
+
+// RunContinuous keeps a connection trickling according to
+// Options.Continuous until the context is canceled, pausing while full
+// measurements run or measurements are paused.
+// It does nothing if Options.Continuous is nil.
+func (c *FastCollector) RunContinuous(ctx context.Context) {
+	if c.opts.Continuous == nil {
+		return
+	}
+	copts := *c.opts.Continuous
+	skip := copts.Skip
+	copts.Skip = func() bool {
+		if atomic.LoadInt32(&c.measuring) == 1 || c.Paused() {
+			return true
+		}
+		return skip != nil && skip()
+	}
+	log.Info().Msg("starting continuous measurement")
+	fast.Continuous(ctx, c.opts.Measure, copts, func(speed float64) {
+		log.Debug().Float64("bytes_second", speed).Msg("continuous download speed estimate")
+		c.statusMutex.Lock()
+		defer c.statusMutex.Unlock()
+		c.continuous = speed
+	})
+}
+
+// continuousEstimate returns the last continuous estimate, if any.
+func (c *FastCollector) continuousEstimate() (float64, bool) {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.continuous, c.continuous > 0
+}
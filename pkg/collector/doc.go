@@ -0,0 +1,5 @@
+// Package collector exposes fast.com measurements as Prometheus metrics.
+//
+// The exported API follows semantic versioning along with the exporter: it
+// only changes in backwards-incompatible ways on major releases.
+package collector
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxErrorLength is the maximum length of the error label.
+const maxErrorLength = 120
+
+// nolint: gochecknoglobals
+var (
+	urlRE    = regexp.MustCompile(`(https?://[^/\s"]+)[^\s"]*`)
+	spacesRE = regexp.MustCompile(`\s+`)
+)
+
+// normalizeError returns the error message as a label value, keeping only
+// the host of URLs, which carry tokens and change on every measurement, and
+// truncating it, so it does not leak secrets nor explode cardinality.
+func normalizeError(err error) string {
+	msg := urlRE.ReplaceAllString(err.Error(), "$1")
+	msg = strings.TrimSpace(spacesRE.ReplaceAllString(msg, " "))
+	if r := []rune(msg); len(r) > maxErrorLength {
+		msg = string(r[:maxErrorLength-3]) + "..."
+	}
+	return msg
+}
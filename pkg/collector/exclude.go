@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// excludeInterval is how long the processes found running are reused, since
+// whether measurements are paused is checked often.
+const excludeInterval = 10 * time.Second
+
+// exclusions pauses measurements while any of the given processes runs, e.g.
+// video call or streaming apps, which would be disrupted by them and skew
+// their results.
+type exclusions struct {
+	names   map[string]bool
+	mutex   sync.Mutex
+	checked time.Time
+	running string
+}
+
+// newExclusions returns the exclusions of the given process names, nil if
+// there are none.
+func newExclusions(names []string) *exclusions {
+	if len(names) == 0 {
+		return nil
+	}
+	e := &exclusions{names: map[string]bool{}}
+	for _, name := range names {
+		e.names[processName(name)] = true
+	}
+	return e
+}
+
+// check returns the name of an excluded process running, empty if none is,
+// or if e is nil.
+func (e *exclusions) check() string {
+	if e == nil {
+		return ""
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if time.Since(e.checked) < excludeInterval {
+		return e.running
+	}
+	e.checked = time.Now()
+
+	names, err := processes()
+	if err != nil {
+		log.Warn().Err(err).Msg("could not list processes")
+		return e.running
+	}
+	var running string
+	for _, name := range names {
+		if e.names[processName(name)] {
+			running = name
+			break
+		}
+	}
+	switch {
+	case running != "" && e.running == "":
+		log.Info().Str("process", running).Msg("measurements paused while an excluded process runs")
+	case running == "" && e.running != "":
+		log.Info().Str("process", e.running).Msg("measurements resumed, excluded process stopped")
+	}
+	e.running = running
+	return running
+}
+
+// processName normalizes the name of a process for comparison, so zoom
+// matches Zoom.exe.
+func processName(name string) string {
+	name = strings.ToLower(filepath.Base(strings.TrimSpace(name)))
+	return strings.TrimSuffix(name, ".exe")
+}
@@ -0,0 +1,20 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// processes returns the executables of the running processes, as listed by
+// ps.
+func processes() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ps", "-Ao", "comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
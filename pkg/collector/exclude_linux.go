@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processes returns the names of the running processes, from both their
+// comm, which the kernel truncates to 15 characters, and the executable in
+// their command line.
+func processes() ([]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			names = append(names, strings.TrimSpace(string(comm)))
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+			if i := bytes.IndexByte(cmdline, 0); i > 0 {
+				cmdline = cmdline[:i]
+			}
+			names = append(names, string(cmdline))
+		}
+	}
+	return names, nil
+}
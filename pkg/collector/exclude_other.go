@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package collector
+
+import "errors"
+
+func processes() ([]string, error) {
+	return nil, errors.New("listing processes is not supported on this platform")
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"context"
+	"encoding/csv"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// processes returns the image names of the running processes, as listed by
+// tasklist.
+func processes() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "tasklist", "/fo", "csv", "/nh").Output()
+	if err != nil {
+		return nil, err
+	}
+	records, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(records))
+	for _, record := range records {
+		if len(record) > 0 {
+			names = append(names, record[0])
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,1552 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/caarlos0/fastcom-exporter/pkg/sinks"
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// FastCollector collects fast.com metrics, caching the results.
+type FastCollector struct {
+	mutex  sync.Mutex
+	flight singleflight.Group
+	cache  *cache.Cache
+
+	opts Options
+
+	outputMutex sync.Mutex
+
+	statusMutex sync.RWMutex
+	background  bool
+	lastID      string
+	lastRun     time.Time
+	lastErr     error
+	failures    int
+	nextRun     time.Time
+	captive     bool
+	down        bool
+	paused      bool
+	pausedUntil time.Time
+	continuous  float64
+	latency     *fast.LatencyResult
+	gateway     *fast.GatewayResult
+	client      *fast.Client
+	measuring   int32
+	excluded    *exclusions
+
+	up             *prometheus.Desc
+	scrapeDuration *prometheus.Desc
+	downloadBytes  *prometheus.Desc
+	uploadBytes    *prometheus.Desc
+	cpuLimited     *prometheus.Desc
+	incomplete     *prometheus.Desc
+	tampered       *prometheus.Desc
+	anomaly        *prometheus.Desc
+	trend          *prometheus.Desc
+	periodSpeed    *prometheus.Desc
+	periodCount    *prometheus.Desc
+	peakSlowdown   *prometheus.Desc
+	requests       *prometheus.Desc
+	failedRequests *prometheus.Desc
+	captivePortal  *prometheus.Desc
+	linkDown       *prometheus.Desc
+	pathHops       *prometheus.Desc
+	tcpRetransmits *prometheus.Desc
+	tcpRTT         *prometheus.Desc
+	socTemperature *prometheus.Desc
+	throttled      *prometheus.Desc
+	wifiInfo       *prometheus.Desc
+	wifiSignal     *prometheus.Desc
+	wifiRate       *prometheus.Desc
+	peeringBytes   *prometheus.Desc
+	loadedLatency  *prometheus.Desc
+	providerSpeed  *prometheus.Desc
+	providerSpread *prometheus.Desc
+	engineSpeed    *prometheus.Desc
+	engineDelta    *prometheus.Desc
+	lineRate       *prometheus.Desc
+	clientInfo     *prometheus.Desc
+	firstHop       *prometheus.Desc
+	serverInfo     *prometheus.Desc
+	serverGeoIP    *prometheus.Desc
+	clientGeoIP    *prometheus.Desc
+	triggerInfo    *prometheus.Desc
+	pausedDesc     *prometheus.Desc
+	excludedDesc   *prometheus.Desc
+	failuresDesc   *prometheus.Desc
+	lastError      *prometheus.Desc
+	continuousRate *prometheus.Desc
+	idleLatency    *prometheus.Desc
+	gatewayLatency *prometheus.Desc
+	gatewayJitter  *prometheus.Desc
+	gatewayLoss    *prometheus.Desc
+	idleJitter     *prometheus.Desc
+	idleLoss       *prometheus.Desc
+
+	downloadHistogram prometheus.Histogram
+	downloadSummary   prometheus.Summary
+	measuredBytes     *prometheus.CounterVec
+	measuredSeconds   *prometheus.CounterVec
+	measurements      *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	requestSize       *prometheus.HistogramVec
+	ipChanges         prometheus.Counter
+	locationChanges   prometheus.Counter
+}
+
+// Options configures the collector.
+type Options struct {
+	// Schedule configures when measurements happen.
+	Schedule Schedule
+	// Measure configures the measurements.
+	Measure fast.Options
+	// CaptivePortal enables the captive portal check before measuring if not
+	// nil.
+	CaptivePortal *fast.CaptivePortalCheck
+	// LinkCheck enables the connectivity check before measuring if not nil,
+	// skipping measurements while the link is down.
+	LinkCheck *fast.LinkCheck
+	// Upload enables upload measurements if not nil.
+	Upload *fast.UploadOptions
+	// Duplex measures download and upload at the same time, along with the
+	// loaded latency. Requires Upload.
+	Duplex bool
+	// Sinks receive every new result.
+	Sinks []sinks.Sink
+	// History keeps every new result if not nil, detecting anomalies.
+	History *history.Store
+	// PeakHours splits the averages of the last 7 days of History into peak
+	// and off-peak ones if not nil, quantifying evening congestion.
+	PeakHours *history.PeakHours
+	// Providers are other speed test servers, whose download speed is
+	// measured right after the fast.com one, to compare them.
+	Providers []Provider
+	// Events logs link downs, with LinkCheck, and drastic drops, with
+	// History, if not nil.
+	Events *history.Events
+	// Output receives every new result as a JSON line if not nil, e.g.
+	// os.Stdout to pipe raw results into log shippers.
+	Output io.Writer
+	// HistorySamples keeps the throughput samples of each result in the
+	// history too, which makes it much larger.
+	HistorySamples bool
+	// Continuous keeps a connection trickling between measurements, exporting
+	// a rolling estimate of the achievable download speed, if not nil.
+	// It requires RunContinuous.
+	Continuous *fast.ContinuousOptions
+	// Latency probes the idle latency between measurements, if not nil.
+	// It requires RunLatency.
+	Latency *LatencyProbe
+	// LatencyOnly disables throughput measurements, only probing the idle
+	// latency.
+	LatencyOnly bool
+	// ExcludeProcesses pauses measurements while any of these processes
+	// runs on the host, e.g. zoom, teams or obs, matched by executable name,
+	// case insensitively and without .exe.
+	ExcludeProcesses []string
+	// Gateway probes the latency to the default gateway before each
+	// measurement and after each idle latency probe, only supported on
+	// Linux, in the network namespace of the process, whatever namespace
+	// Measure.Client dials from.
+	Gateway bool
+	// PublicIP exports the public IP and location reported by fast.com,
+	// counting their changes, if not nil.
+	// Otherwise, the IP is removed from the results.
+	PublicIP *PublicIP
+	// Encapsulation estimates the line rate of each result along with the
+	// measured goodput, if set.
+	Encapsulation fast.Encapsulation
+	// Engine is the engine Measure was set up with, Legacy if empty, only
+	// used to label comparisons.
+	Engine fast.Engine
+	// CompareEngines measures the download again with the other engine right
+	// after each download, exporting both speeds and their difference.
+	// It is not supported in duplex mode.
+	CompareEngines bool
+	// CacheTTL is how long results measured on scrape are reused before
+	// measuring again, defaults to Schedule.Interval plus its jitter.
+	CacheTTL time.Duration
+	// Timestamps sets the measurement time as the timestamp of the result
+	// metrics, meant for background mode, since Prometheus considers
+	// samples older than 5 minutes stale.
+	Timestamps bool
+}
+
+// Result is the result of a full measurement.
+type Result struct {
+	ID       string       `json:"id"`
+	Time     time.Time    `json:"time"`
+	Download fast.Result  `json:"download"`
+	Upload   *fast.Result `json:"upload,omitempty"`
+	// Trigger is how the measurement was triggered.
+	Trigger TriggerSource `json:"trigger,omitempty"`
+	// LoadedLatency is only measured in duplex mode.
+	LoadedLatency time.Duration `json:"loaded_latency,omitempty"`
+	// Anomaly is set if the download speed was anomalous compared to the
+	// history.
+	Anomaly *history.Anomaly `json:"anomaly,omitempty"`
+	// Providers are the download results of the other providers, by name.
+	Providers map[string]*fast.Result `json:"providers,omitempty"`
+	// LineRate is only estimated with an encapsulation.
+	LineRate *LineRate `json:"line_rate,omitempty"`
+	// Engines compares the download speed of both engines, only with
+	// Options.CompareEngines.
+	Engines *EngineComparison `json:"engines,omitempty"`
+	// Gateway is the latency to the default gateway before measuring, only
+	// probed with Options.Gateway.
+	Gateway *fast.GatewayResult `json:"gateway,omitempty"`
+}
+
+// EngineComparison is the download speed measured with each engine, one
+// right after the other, in B/s.
+type EngineComparison struct {
+	Speeds map[fast.Engine]float64 `json:"speeds"`
+	// Delta is the relative difference of the V2 speed over the Legacy one.
+	Delta float64 `json:"delta"`
+}
+
+// LineRate is the estimated line rate of a result, in B/s, the measured
+// speeds plus the protocol overhead.
+type LineRate struct {
+	Encapsulation fast.Encapsulation `json:"encapsulation"`
+	Download      float64            `json:"download_bytes_second"`
+	Upload        float64            `json:"upload_bytes_second,omitempty"`
+}
+
+func (r Result) uploadSpeed() float64 {
+	if r.Upload == nil {
+		return 0
+	}
+	return r.Upload.Speed
+}
+
+// FastProvider is the provider label of the fast.com measurements.
+const FastProvider = "fast.com"
+
+// Provider is a speed test server other than fast.com.
+type Provider struct {
+	Name    string
+	Targets *fast.Targets
+}
+
+// Summary returns the result as written to sinks.
+func (r Result) Summary() sinks.Result {
+	return sinks.Result{
+		ID:            r.ID,
+		Time:          r.Time,
+		DownloadSpeed: r.Download.Speed,
+		UploadSpeed:   r.uploadSpeed(),
+		Trigger:       string(r.Trigger),
+	}
+}
+
+func (r Result) cpuLimited() bool {
+	return r.Download.CPULimited || (r.Upload != nil && r.Upload.CPULimited)
+}
+
+func (r Result) tampered() bool {
+	return r.Download.Tampered || (r.Upload != nil && r.Upload.Tampered)
+}
+
+func (r Result) incomplete() bool {
+	return r.Download.Incomplete || (r.Upload != nil && r.Upload.Incomplete)
+}
+
+// servers returns the servers of the download and upload measurements,
+// without duplicates.
+func (r Result) servers() []fast.Server {
+	servers := append([]fast.Server(nil), r.Download.Servers...)
+	if r.Upload != nil {
+		servers = append(servers, r.Upload.Servers...)
+	}
+	seen := make(map[string]bool, len(servers))
+	result := servers[:0]
+	for _, server := range servers {
+		if seen[server.Host] {
+			continue
+		}
+		seen[server.Host] = true
+		result = append(result, server)
+	}
+	return result
+}
+
+// Status is a snapshot of the collector state.
+type Status struct {
+	LastID    string
+	LastRun   time.Time
+	LastError error
+	NextRun   time.Time
+	Paused    bool
+}
+
+const sinkTimeout = 30 * time.Second
+
+// speedBuckets are common internet plan speeds, from 1Mbps to 10Gbps, in B/s.
+// nolint: gochecknoglobals
+var speedBuckets = []float64{
+	125e3, 625e3, 1.25e6, 3.125e6, 6.25e6, 12.5e6, 31.25e6, 62.5e6, 125e6, 312.5e6, 625e6, 1.25e9,
+}
+
+// errNoResult happens when running in the background and the first
+// measurement did not finish yet.
+var errNoResult = errors.New("waiting for the first measurement")
+
+// NewFastCollector returns a fast.com collector.
+// Unless Run is called, measurements happen on scrape and are cached for the
+// schedule interval plus jitter.
+func NewFastCollector(cache *cache.Cache, opts Options) *FastCollector {
+	const namespace = "fastcom"
+	return &FastCollector{
+		cache:    cache,
+		opts:     opts,
+		excluded: newExclusions(opts.ExcludeProcesses),
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Exporter is up",
+			nil,
+			nil,
+		),
+		scrapeDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scrape_duration_seconds"),
+			"Returns how long the probe took to complete in seconds",
+			nil,
+			nil,
+		),
+		downloadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "download", "bytes_second"),
+			"Download speed in B/s",
+			nil,
+			nil,
+		),
+		uploadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "upload", "bytes_second"),
+			"Upload speed in B/s",
+			nil,
+			nil,
+		),
+		captivePortal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "captive_portal_detected"),
+			"Whether a captive portal was detected before the last measurement",
+			nil,
+			nil,
+		),
+		linkDown: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "link_down"),
+			"Whether the link was down before the last measurement, which was skipped",
+			nil,
+			nil,
+		),
+		loadedLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "loaded_latency_seconds"),
+			"Median latency while download and upload were saturated at the same time",
+			nil,
+			nil,
+		),
+		providerSpeed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "provider", "download_bytes_second"),
+			"Download speed measured against each provider in the last measurement, fast.com included, in B/s",
+			[]string{"provider"},
+			nil,
+		),
+		providerSpread: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "provider", "download_spread_ratio"),
+			"Difference between the fastest and slowest provider download speeds, relative to the fastest",
+			nil,
+			nil,
+		),
+		engineSpeed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "engine", "download_bytes_second"),
+			"Download speed in B/s measured with each engine, one right after the other",
+			[]string{"engine"},
+			nil,
+		),
+		engineDelta: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "engine", "delta_ratio"),
+			"Difference between the v2 and legacy engine download speeds, relative to the legacy one",
+			nil,
+			nil,
+		),
+		lineRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "line_rate_bytes_second"),
+			"Estimated line rate in B/s, the measured speed plus the protocol overhead of the encapsulation",
+			[]string{"direction", "encapsulation"},
+			nil,
+		),
+		clientInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "client_info"),
+			"Public IP, or its hash, and location of the client as seen by fast.com",
+			[]string{"ip", "city", "country", "isp"},
+			nil,
+		),
+		tcpRetransmits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "retransmit_ratio"),
+			"Ratio of TCP segments retransmitted by this host during the last measurement",
+			[]string{"direction"},
+			nil,
+		),
+		tcpRTT: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "rtt_seconds"),
+			"Average smoothed TCP round trip time of the last measurement connections",
+			[]string{"direction"},
+			nil,
+		),
+		socTemperature: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "soc", "temperature_celsius"),
+			"Temperature of the hottest thermal zone before and after the last measurement",
+			[]string{"direction", "phase"},
+			nil,
+		),
+		throttled: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "thermal_throttled"),
+			"Whether the CPU was thermally throttled during the last measurement, likely limiting the measured speed",
+			[]string{"direction"},
+			nil,
+		),
+		wifiInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wifi", "info"),
+			"Wireless link the last measurement was taken over",
+			[]string{"interface", "ssid"},
+			nil,
+		),
+		wifiSignal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wifi", "signal_dbm"),
+			"Received signal strength of the wireless link when the last measurement started",
+			nil,
+			nil,
+		),
+		peeringBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "peering", "bytes"),
+			"Bytes transferred in the last measurement by each network of test servers, a /24 or /48, or autonomous system if resolved",
+			[]string{"direction", "network", "organization"},
+			nil,
+		),
+		wifiRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "wifi", "rate_bytes_second"),
+			"Transmit rate of the wireless link when the last measurement started, in B/s",
+			nil,
+			nil,
+		),
+		pathHops: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "path", "hops"),
+			"Number of hops to the first test server",
+			[]string{"host"},
+			nil,
+		),
+		firstHop: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "path", "first_hop_latency_seconds"),
+			"Round trip time to the first hop in the path to the first test server",
+			[]string{"host"},
+			nil,
+		),
+		serverInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "info"),
+			"Test servers used by the last measurement and their location",
+			[]string{"host", "city", "country"},
+			nil,
+		),
+		triggerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "trigger_info"),
+			"How the last measurement was triggered: schedule, scrape, api or cli",
+			[]string{"trigger"},
+			nil,
+		),
+		serverGeoIP: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server", "geoip_info"),
+			"Addresses of the test servers used by the last measurement and their location and autonomous system in the GeoIP database",
+			[]string{"host", "ip", "country", "city", "asn", "organization"},
+			nil,
+		),
+		clientGeoIP: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "client", "geoip_info"),
+			"Location and autonomous system of the public IP of the client in the GeoIP database",
+			[]string{"country", "city", "asn", "organization"},
+			nil,
+		),
+		failuresDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "consecutive_failures"),
+			"Number of consecutive failed measurements",
+			nil,
+			nil,
+		),
+		lastError: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "last_error_info"),
+			"Error of the last measurement, if it failed",
+			[]string{"error"},
+			nil,
+		),
+		pausedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "paused"),
+			"Whether measurements are paused",
+			nil,
+			nil,
+		),
+		excludedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "excluded_process_running"),
+			"Whether measurements are paused because this excluded process is running",
+			[]string{"process"},
+			nil,
+		),
+		idleLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "idle", "latency_seconds"),
+			"Median idle latency to a test server in the last probe",
+			[]string{"host"},
+			nil,
+		),
+		idleJitter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "idle", "jitter_seconds"),
+			"Mean difference between consecutive idle latencies in the last probe",
+			[]string{"host"},
+			nil,
+		),
+		idleLoss: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "idle", "probe_loss_ratio"),
+			"Ratio of failed idle latency requests in the last probe",
+			[]string{"host"},
+			nil,
+		),
+		gatewayLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "gateway", "latency_seconds"),
+			"Median latency to the default gateway in the last probe",
+			[]string{"gateway"},
+			nil,
+		),
+		gatewayJitter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "gateway", "jitter_seconds"),
+			"Mean difference between consecutive latencies to the default gateway in the last probe",
+			[]string{"gateway"},
+			nil,
+		),
+		gatewayLoss: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "gateway", "probe_loss_ratio"),
+			"Ratio of failed default gateway probes in the last probe",
+			[]string{"gateway"},
+			nil,
+		),
+		continuousRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "continuous", "download_bytes_second"),
+			"Rolling estimate of the achievable download speed in B/s, updated between measurements",
+			nil,
+			nil,
+		),
+		cpuLimited: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "cpu_limited"),
+			"Whether the CPU was saturated during the last measurement, likely limiting the measured speed",
+			nil,
+			nil,
+		),
+		incomplete: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "incomplete"),
+			"Whether some requests failed during the last measurement, making it partial",
+			nil,
+			nil,
+		),
+		tampered: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tampering_detected"),
+			"Whether the downloaded content was changed in the path during the last measurement",
+			nil,
+			nil,
+		),
+		anomaly: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "anomaly_detected"),
+			"Whether the last download speed was anomalously low compared to the history",
+			nil,
+			nil,
+		),
+		trend: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "download", "trend_bytes_per_day"),
+			"Slope of the download speed over the last 7 days of history in B/s per day, negative if getting slower",
+			nil,
+			nil,
+		),
+		periodSpeed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "period", "average_bytes_second"),
+			"Average speed over the last 7 days of history in B/s, in and out of the peak hours, except on-demand measurements",
+			[]string{"direction", "period"},
+			nil,
+		),
+		periodCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "period", "measurements"),
+			"Number of measurements over the last 7 days of history in and out of the peak hours, except on-demand ones",
+			[]string{"period"},
+			nil,
+		),
+		peakSlowdown: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "peak", "slowdown_ratio"),
+			"How much slower the average download speed is in the peak hours than out of them, from 0 to 1, negative if faster",
+			nil,
+			nil,
+		),
+		requests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "requests"),
+			"Number of requests made during the last measurement",
+			[]string{"direction"},
+			nil,
+		),
+		failedRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "failed_requests"),
+			"Number of requests that failed during the last measurement",
+			[]string{"direction"},
+			nil,
+		),
+		downloadHistogram: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "download",
+			Name:      "measurements_bytes_second",
+			Help:      "Distribution of all download speed measurements in B/s, except on-demand ones",
+			Buckets:   speedBuckets,
+		}),
+		downloadSummary: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  "download",
+			Name:       "daily_bytes_second",
+			Help:       "Quantiles of the download speed measurements of the last 24 hours in B/s, except on-demand ones",
+			Objectives: map[float64]float64{0.05: 0.01, 0.5: 0.05, 0.95: 0.01},
+			MaxAge:     24 * time.Hour,
+			AgeBuckets: 24,
+		}),
+		measuredBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "measurement_bytes_total",
+			Help:      "Total bytes transferred by all measurements",
+		}, []string{"direction"}),
+		measuredSeconds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "measurement_seconds_total",
+			Help:      "Total time spent transferring by all measurements",
+		}, []string{"direction"}),
+		measurements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "measurements_total",
+			Help:      "Total successful measurements by how they were triggered",
+		}, []string{"trigger"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the measurement requests that completed, by test server",
+			Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+		}, []string{"direction", "host"}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_size_bytes",
+			Help:      "Bytes transferred by the measurement requests that completed",
+			Buckets:   prometheus.ExponentialBuckets(64<<10, 4, 6),
+		}, []string{"direction"}),
+		ipChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "public_ip_changes_total",
+			Help:      "Number of times the public IP changed between measurements",
+		}),
+		locationChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "client_location_changes_total",
+			Help:      "Number of times the location of the client changed between measurements, as seen by fast.com",
+		}),
+	}
+}
+
+// Describe all metrics
+func (c *FastCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.scrapeDuration
+	ch <- c.pausedDesc
+	if c.excluded != nil {
+		ch <- c.excludedDesc
+	}
+	ch <- c.failuresDesc
+	ch <- c.lastError
+	ch <- c.downloadBytes
+	if c.opts.Upload != nil {
+		ch <- c.uploadBytes
+	}
+	ch <- c.cpuLimited
+	ch <- c.incomplete
+	if c.opts.Measure.Checksum {
+		ch <- c.tampered
+	}
+	if c.opts.History != nil {
+		ch <- c.anomaly
+		ch <- c.trend
+		if c.opts.PeakHours != nil {
+			ch <- c.periodSpeed
+			ch <- c.periodCount
+			ch <- c.peakSlowdown
+		}
+	}
+	ch <- c.requests
+	ch <- c.failedRequests
+	ch <- c.serverInfo
+	ch <- c.triggerInfo
+	if c.opts.Measure.GeoIP != nil {
+		ch <- c.serverGeoIP
+		ch <- c.clientGeoIP
+	}
+	if c.opts.CaptivePortal != nil {
+		ch <- c.captivePortal
+	}
+	if c.opts.LinkCheck != nil {
+		ch <- c.linkDown
+	}
+	if c.opts.Continuous != nil {
+		ch <- c.continuousRate
+	}
+	if c.opts.Latency != nil {
+		ch <- c.idleLatency
+		ch <- c.idleJitter
+		ch <- c.idleLoss
+	}
+	if c.opts.Gateway {
+		ch <- c.gatewayLatency
+		ch <- c.gatewayJitter
+		ch <- c.gatewayLoss
+	}
+	if c.duplex() {
+		ch <- c.loadedLatency
+	}
+	if c.opts.Encapsulation != "" {
+		ch <- c.lineRate
+	}
+	if len(c.opts.Providers) > 0 {
+		ch <- c.providerSpeed
+		ch <- c.providerSpread
+	}
+	if c.opts.CompareEngines {
+		ch <- c.engineSpeed
+		ch <- c.engineDelta
+	}
+	if c.opts.Measure.TCPInfo {
+		ch <- c.tcpRetransmits
+		ch <- c.tcpRTT
+	}
+	if c.opts.Measure.Thermal {
+		ch <- c.socTemperature
+		ch <- c.throttled
+	}
+	if c.opts.Measure.WiFi {
+		ch <- c.wifiInfo
+		ch <- c.wifiSignal
+		ch <- c.wifiRate
+	}
+	if c.opts.Measure.Peering {
+		ch <- c.peeringBytes
+	}
+	if c.opts.Measure.Traceroute {
+		ch <- c.pathHops
+		ch <- c.firstHop
+	}
+	c.downloadHistogram.Describe(ch)
+	c.downloadSummary.Describe(ch)
+	c.measuredBytes.Describe(ch)
+	c.measuredSeconds.Describe(ch)
+	c.measurements.Describe(ch)
+	c.requestDuration.Describe(ch)
+	c.requestSize.Describe(ch)
+	if c.opts.PublicIP != nil {
+		ch <- c.clientInfo
+		c.ipChanges.Describe(ch)
+		c.locationChanges.Describe(ch)
+	}
+}
+
+// Collect all metrics
+func (c *FastCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectWith(context.Background(), ch, c.opts.Measure)
+}
+
+// collectWith collects all metrics, measuring with the given options if
+// needed, within the deadline of ctx, if any.
+func (c *FastCollector) collectWith(ctx context.Context, ch chan<- prometheus.Metric, opts fast.Options) {
+	start := time.Now()
+	success := 1
+	defer func() {
+		ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, time.Since(start).Seconds())
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, float64(success))
+		ch <- prometheus.MustNewConstMetric(c.pausedDesc, prometheus.GaugeValue, boolToFloat(c.Paused()))
+		if process := c.excluded.check(); process != "" {
+			ch <- prometheus.MustNewConstMetric(c.excludedDesc, prometheus.GaugeValue, 1, process)
+		}
+		failures, lastErr := c.failureStatus()
+		ch <- prometheus.MustNewConstMetric(c.failuresDesc, prometheus.GaugeValue, float64(failures))
+		if lastErr != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastError, prometheus.GaugeValue, 1, normalizeError(lastErr))
+		}
+		if c.opts.CaptivePortal != nil {
+			ch <- prometheus.MustNewConstMetric(c.captivePortal, prometheus.GaugeValue, boolToFloat(c.captiveDetected()))
+		}
+		if c.opts.LinkCheck != nil {
+			ch <- prometheus.MustNewConstMetric(c.linkDown, prometheus.GaugeValue, boolToFloat(c.isLinkDown()))
+		}
+		if c.opts.History != nil {
+			entries := c.opts.History.Entries()
+			if trend, ok := history.Trend(entries, time.Now().Add(-history.TrendWindow)); ok {
+				ch <- prometheus.MustNewConstMetric(c.trend, prometheus.GaugeValue, trend)
+			}
+			if c.opts.PeakHours != nil {
+				c.collectPeriods(ch, entries)
+			}
+		}
+		if latency := c.lastLatency(); latency != nil {
+			host := latency.Server.Host
+			ch <- prometheus.MustNewConstMetric(c.idleLatency, prometheus.GaugeValue, latency.Latency.Seconds(), host)
+			ch <- prometheus.MustNewConstMetric(c.idleJitter, prometheus.GaugeValue, latency.Jitter.Seconds(), host)
+			ch <- prometheus.MustNewConstMetric(c.idleLoss, prometheus.GaugeValue, latency.Loss, host)
+		}
+		if gateway := c.lastGateway(); gateway != nil {
+			ch <- prometheus.MustNewConstMetric(c.gatewayLatency, prometheus.GaugeValue, gateway.Latency.Seconds(), gateway.Gateway)
+			ch <- prometheus.MustNewConstMetric(c.gatewayJitter, prometheus.GaugeValue, gateway.Jitter.Seconds(), gateway.Gateway)
+			ch <- prometheus.MustNewConstMetric(c.gatewayLoss, prometheus.GaugeValue, gateway.Loss, gateway.Gateway)
+		}
+		if speed, ok := c.continuousEstimate(); ok {
+			ch <- prometheus.MustNewConstMetric(c.continuousRate, prometheus.GaugeValue, speed)
+		}
+		c.downloadHistogram.Collect(ch)
+		c.downloadSummary.Collect(ch)
+		c.measuredBytes.Collect(ch)
+		c.measuredSeconds.Collect(ch)
+		c.measurements.Collect(ch)
+		c.requestDuration.Collect(ch)
+		c.requestSize.Collect(ch)
+		if c.opts.PublicIP != nil {
+			c.ipChanges.Collect(ch)
+			c.locationChanges.Collect(ch)
+		}
+	}()
+
+	if c.opts.LatencyOnly {
+		return
+	}
+	result, err := c.cachedOrCollect(ctx, opts, TriggerScrape)
+	if errors.Is(err, errNoResult) || errors.Is(err, ErrPaused) {
+		log.Debug().Err(err).Msg("no fast.com results to report")
+		return
+	}
+	if err != nil {
+		success = 0
+		log.Error().Err(err).Msg("fast.com collector failed")
+	}
+
+	emit := func(m prometheus.Metric) {
+		if c.opts.Timestamps && !result.Time.IsZero() {
+			m = prometheus.NewMetricWithTimestamp(result.Time, m)
+		}
+		ch <- m
+	}
+	emit(prometheus.MustNewConstMetric(c.downloadBytes, prometheus.GaugeValue, result.Download.Speed))
+	if c.opts.Upload != nil {
+		emit(prometheus.MustNewConstMetric(c.uploadBytes, prometheus.GaugeValue, result.uploadSpeed()))
+	}
+	emit(prometheus.MustNewConstMetric(c.cpuLimited, prometheus.GaugeValue, boolToFloat(result.cpuLimited())))
+	emit(prometheus.MustNewConstMetric(c.incomplete, prometheus.GaugeValue, boolToFloat(result.incomplete())))
+	if c.opts.Measure.Checksum {
+		emit(prometheus.MustNewConstMetric(c.tampered, prometheus.GaugeValue, boolToFloat(result.tampered())))
+	}
+	if c.opts.History != nil {
+		emit(prometheus.MustNewConstMetric(c.anomaly, prometheus.GaugeValue, boolToFloat(result.Anomaly != nil)))
+	}
+	if c.duplex() {
+		emit(prometheus.MustNewConstMetric(c.loadedLatency, prometheus.GaugeValue, result.LoadedLatency.Seconds()))
+	}
+	if result.Trigger != "" {
+		emit(prometheus.MustNewConstMetric(c.triggerInfo, prometheus.GaugeValue, 1, string(result.Trigger)))
+	}
+	for _, server := range result.servers() {
+		emit(prometheus.MustNewConstMetric(c.serverInfo, prometheus.GaugeValue, 1, server.Host, server.City, server.Country))
+	}
+	if client := result.Download.Client; client != nil && c.opts.PublicIP != nil {
+		emit(prometheus.MustNewConstMetric(c.clientInfo, prometheus.GaugeValue, 1, client.IP, client.City, client.Country, client.ISP))
+	}
+	c.collectGeoIP(emit, result)
+	if rate := result.LineRate; rate != nil {
+		emit(prometheus.MustNewConstMetric(c.lineRate, prometheus.GaugeValue, rate.Download, "download", string(rate.Encapsulation)))
+		if result.Upload != nil {
+			emit(prometheus.MustNewConstMetric(c.lineRate, prometheus.GaugeValue, rate.Upload, "upload", string(rate.Encapsulation)))
+		}
+	}
+	c.collectProviders(emit, result)
+	if engines := result.Engines; engines != nil {
+		for engine, speed := range engines.Speeds {
+			emit(prometheus.MustNewConstMetric(c.engineSpeed, prometheus.GaugeValue, speed, string(engine)))
+		}
+		emit(prometheus.MustNewConstMetric(c.engineDelta, prometheus.GaugeValue, engines.Delta))
+	}
+	c.collectRequests(emit, "download", &result.Download)
+	c.collectRequests(emit, "upload", result.Upload)
+	c.collectTCP(emit, "download", &result.Download)
+	c.collectTCP(emit, "upload", result.Upload)
+	c.collectThermal(emit, "download", &result.Download)
+	c.collectThermal(emit, "upload", result.Upload)
+	c.collectPeering(emit, "download", &result.Download)
+	c.collectPeering(emit, "upload", result.Upload)
+	if wifi := result.Download.WiFi; wifi != nil {
+		emit(prometheus.MustNewConstMetric(c.wifiInfo, prometheus.GaugeValue, 1, wifi.Interface, wifi.SSID))
+		if wifi.Signal != 0 {
+			emit(prometheus.MustNewConstMetric(c.wifiSignal, prometheus.GaugeValue, wifi.Signal))
+		}
+		if wifi.Rate > 0 {
+			emit(prometheus.MustNewConstMetric(c.wifiRate, prometheus.GaugeValue, wifi.Rate))
+		}
+	}
+	if path := result.Download.Path; path != nil {
+		emit(prometheus.MustNewConstMetric(c.pathHops, prometheus.GaugeValue, float64(path.Hops), path.Host))
+		emit(prometheus.MustNewConstMetric(c.firstHop, prometheus.GaugeValue, path.FirstHopLatency.Seconds(), path.Host))
+	}
+}
+
+func (c *FastCollector) collectProviders(emit func(prometheus.Metric), result Result) {
+	if len(c.opts.Providers) == 0 {
+		return
+	}
+	emit(prometheus.MustNewConstMetric(c.providerSpeed, prometheus.GaugeValue, result.Download.Speed, FastProvider))
+	fastest, slowest := result.Download.Speed, result.Download.Speed
+	for name, r := range result.Providers {
+		emit(prometheus.MustNewConstMetric(c.providerSpeed, prometheus.GaugeValue, r.Speed, name))
+		fastest = math.Max(fastest, r.Speed)
+		slowest = math.Min(slowest, r.Speed)
+	}
+	if len(result.Providers) > 0 && fastest > 0 {
+		emit(prometheus.MustNewConstMetric(c.providerSpread, prometheus.GaugeValue, (fastest-slowest)/fastest))
+	}
+}
+
+func (c *FastCollector) collectRequests(emit func(prometheus.Metric), direction string, result *fast.Result) {
+	if result == nil {
+		return
+	}
+	emit(prometheus.MustNewConstMetric(c.requests, prometheus.GaugeValue, float64(result.Requests), direction))
+	emit(prometheus.MustNewConstMetric(c.failedRequests, prometheus.GaugeValue, float64(result.Failed), direction))
+}
+
+func (c *FastCollector) collectTCP(emit func(prometheus.Metric), direction string, result *fast.Result) {
+	if result == nil || result.TCP == nil {
+		return
+	}
+	emit(prometheus.MustNewConstMetric(c.tcpRetransmits, prometheus.GaugeValue, result.TCP.RetransmitRate, direction))
+	emit(prometheus.MustNewConstMetric(c.tcpRTT, prometheus.GaugeValue, result.TCP.RTT.Seconds(), direction))
+}
+
+func (c *FastCollector) collectThermal(emit func(prometheus.Metric), direction string, result *fast.Result) {
+	if result == nil || result.Thermal == nil {
+		return
+	}
+	emit(prometheus.MustNewConstMetric(c.socTemperature, prometheus.GaugeValue, result.Thermal.Before, direction, "before"))
+	emit(prometheus.MustNewConstMetric(c.socTemperature, prometheus.GaugeValue, result.Thermal.After, direction, "after"))
+	emit(prometheus.MustNewConstMetric(c.throttled, prometheus.GaugeValue, boolToFloat(result.Thermal.Throttled), direction))
+}
+
+func (c *FastCollector) collectGeoIP(emit func(prometheus.Metric), result Result) {
+	for _, server := range result.servers() {
+		if l := server.GeoIP; l != nil {
+			emit(prometheus.MustNewConstMetric(c.serverGeoIP, prometheus.GaugeValue, 1, server.Host, server.IP, l.Country, l.City, asnLabel(l.ASN), l.Organization))
+		}
+	}
+	if client := result.Download.Client; client != nil && client.GeoIP != nil {
+		l := client.GeoIP
+		emit(prometheus.MustNewConstMetric(c.clientGeoIP, prometheus.GaugeValue, 1, l.Country, l.City, asnLabel(l.ASN), l.Organization))
+	}
+}
+
+// asnLabel returns the label of an autonomous system number, empty if
+// unknown.
+func asnLabel(asn uint) string {
+	if asn == 0 {
+		return ""
+	}
+	return fmt.Sprintf("AS%d", asn)
+}
+
+func (c *FastCollector) collectPeering(emit func(prometheus.Metric), direction string, result *fast.Result) {
+	if result == nil {
+		return
+	}
+	for _, p := range result.Peering {
+		emit(prometheus.MustNewConstMetric(c.peeringBytes, prometheus.GaugeValue, float64(p.Bytes), direction, p.Network, p.Organization))
+	}
+}
+
+// collectPeriods exports the averages in and out of the peak hours, leaving
+// on-demand measurements out as they are not taken at random times.
+func (c *FastCollector) collectPeriods(ch chan<- prometheus.Metric, entries []history.Entry) {
+	scheduled := make([]history.Entry, 0, len(entries))
+	for _, e := range entries {
+		if !TriggerSource(e.Trigger).OnDemand() {
+			scheduled = append(scheduled, e)
+		}
+	}
+	peak, offPeak := history.ByPeriod(scheduled, time.Now().Add(-history.TrendWindow), *c.opts.PeakHours)
+	for period, average := range map[string]history.PeriodAverage{"peak": peak, "off_peak": offPeak} {
+		ch <- prometheus.MustNewConstMetric(c.periodCount, prometheus.GaugeValue, float64(average.Measurements), period)
+		if average.Measurements == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.periodSpeed, prometheus.GaugeValue, average.DownloadSpeed, "download", period)
+		if average.UploadSpeed > 0 {
+			ch <- prometheus.MustNewConstMetric(c.periodSpeed, prometheus.GaugeValue, average.UploadSpeed, "upload", period)
+		}
+	}
+	if peak.Measurements > 0 && offPeak.DownloadSpeed > 0 {
+		ch <- prometheus.MustNewConstMetric(c.peakSlowdown, prometheus.GaugeValue, 1-peak.DownloadSpeed/offPeak.DownloadSpeed)
+	}
+}
+
+// Status returns the current collector status.
+// In scrape mode, NextRun is the time the cached result expires, zero if
+// nothing is cached.
+func (c *FastCollector) Status() Status {
+	excluded := c.excluded.check() != ""
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+
+	status := Status{
+		LastID:    c.lastID,
+		LastRun:   c.lastRun,
+		LastError: c.lastErr,
+		Paused:    excluded || c.pausedByUser(),
+	}
+	if c.background {
+		status.NextRun = c.nextRun
+		return status
+	}
+	if _, expiration, ok := c.cache.GetWithExpiration("result"); ok {
+		status.NextRun = expiration
+	}
+	return status
+}
+
+func (c *FastCollector) cachedOrCollect(ctx context.Context, opts fast.Options, source TriggerSource) (Result, error) {
+	if cold, ok := c.cached(); ok {
+		return cold, nil
+	}
+	if c.Paused() {
+		return Result{}, ErrPaused
+	}
+	if c.isBackground() {
+		if err := c.Status().LastError; err != nil {
+			return Result{}, err
+		}
+		return Result{}, errNoResult
+	}
+
+	// concurrent scrapes share a single measurement, and its error
+	v, err, shared := c.flight.Do("result", func() (interface{}, error) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		// a trigger might have refreshed it while we waited for the lock
+		if cold, ok := c.cached(); ok {
+			return cold, nil
+		}
+
+		hot, err := c.collect(ctx, opts, source)
+		if err != nil {
+			return hot, err
+		}
+		log.Debug().Msg("returning results from api")
+		c.cache.Set("result", hot, c.cacheTTL())
+		return hot, nil
+	})
+	if shared {
+		log.Debug().Msg("shared measurement with concurrent scrapes")
+	}
+	return v.(Result), err
+}
+
+// LastResult returns the last successful result, if it is still cached.
+func (c *FastCollector) LastResult() (Result, bool) {
+	cold, ok := c.cache.Get("result")
+	if !ok {
+		return Result{}, false
+	}
+	return cold.(Result), true
+}
+
+func (c *FastCollector) cached() (Result, bool) {
+	cold, ok := c.LastResult()
+	if ok {
+		log.Debug().Msg("returning results from cache")
+	}
+	return cold, ok
+}
+
+// cacheTTL returns how long a result measured on scrape is cached.
+func (c *FastCollector) cacheTTL() time.Duration {
+	if c.opts.CacheTTL > 0 {
+		return c.opts.CacheTTL
+	}
+	return c.opts.Schedule.Interval + jitter(c.opts.Schedule.Jitter)
+}
+
+func (c *FastCollector) isBackground() bool {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.background
+}
+
+func (c *FastCollector) setStatus(id string, err error) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.lastID = id
+	c.lastRun = time.Now()
+	c.lastErr = err
+	if err != nil {
+		c.failures++
+	} else {
+		c.failures = 0
+	}
+}
+
+// failureStatus returns the number of consecutive failures and the last
+// error, nil if the last measurement succeeded.
+func (c *FastCollector) failureStatus() (int, error) {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.failures, c.lastErr
+}
+
+// collect measures with the given options, skipping the optional phases to
+// finish before the deadline of ctx, if any.
+func (c *FastCollector) collect(ctx context.Context, opts fast.Options, source TriggerSource) (Result, error) {
+	atomic.StoreInt32(&c.measuring, 1)
+	defer atomic.StoreInt32(&c.measuring, 0)
+
+	id := newMeasurementID()
+	logger := log.With().Str("measurement_id", id).Logger()
+	ctx = logger.WithContext(ctx)
+
+	var gateway *fast.GatewayResult
+	if !capped(ctx) {
+		gateway = c.probeGateway(ctx)
+	}
+	hot, err := c.measure(ctx, opts)
+	c.setStatus(id, err)
+	if err != nil {
+		return Result{}, fmt.Errorf("measurement %s: %w", id, err)
+	}
+	hot.Gateway = gateway
+	c.count("download", &hot.Download)
+	c.count("upload", hot.Upload)
+	c.measurements.WithLabelValues(string(source)).Inc()
+	if !source.OnDemand() {
+		c.downloadHistogram.Observe(hot.Download.Speed)
+		c.downloadSummary.Observe(hot.Download.Speed)
+	}
+	hot.ID = id
+	hot.Time = time.Now()
+	hot.Trigger = source
+	c.trackClient(ctx, hot.Download.Client)
+	c.scrubClients(&hot)
+	if e := c.opts.Encapsulation; e != "" {
+		hot.LineRate = &LineRate{
+			Encapsulation: e,
+			Download:      e.LineRate(hot.Download.Speed),
+			Upload:        e.LineRate(hot.uploadSpeed()),
+		}
+	}
+	entry := history.Entry{
+		ID:            id,
+		Time:          hot.Time,
+		Trigger:       string(source),
+		DownloadSpeed: hot.Download.Speed,
+		UploadSpeed:   hot.uploadSpeed(),
+		Method:        c.method(opts, hot),
+	}
+	entry.Server, entry.ServerIP = measuredServer(hot.Download)
+	if client := hot.Download.Client; client != nil {
+		entry.ClientIP = client.IP
+		entry.ISP = client.ISP
+	}
+	if c.opts.HistorySamples {
+		entry.DownloadSamples = hot.Download.Samples
+		if hot.Upload != nil {
+			entry.UploadSamples = hot.Upload.Samples
+		}
+	}
+	hot.Anomaly = c.record(ctx, entry)
+	if c.opts.History != nil {
+		c.event(ctx, history.DrasticDrop, hot.Time, dropDetail(hot))
+	}
+
+	c.output(ctx, hot)
+	summary := hot.Summary()
+	if latency := c.lastLatency(); latency != nil {
+		summary.Latency = latency.Latency
+	}
+	go c.write(ctx, summary)
+	return hot, nil
+}
+
+// measuredServer returns the host and address of the test server the first
+// connected request of the result was sent to.
+func measuredServer(result fast.Result) (host, ip string) {
+	for _, t := range result.Transfers {
+		if t.RemoteAddr == "" {
+			continue
+		}
+		if u, err := url.Parse(t.URL); err == nil {
+			host = u.Hostname()
+		}
+		ip = t.RemoteAddr
+		if h, _, err := net.SplitHostPort(t.RemoteAddr); err == nil {
+			ip = h
+		}
+		return host, ip
+	}
+	return "", ""
+}
+
+// method describes how the result was measured, e.g.
+// "fast.com, HTTP/1.1, 8 connections, round-robin servers, duplex".
+func (c *FastCollector) method(opts fast.Options, result Result) string {
+	parts := []string{FastProvider}
+	for _, t := range result.Download.Transfers {
+		if t.Proto != "" {
+			parts = append(parts, t.Proto)
+			break
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%d connections", opts.Connections))
+	if opts.Strategy != "" {
+		parts = append(parts, string(opts.Strategy)+" servers")
+	}
+	if c.duplex() {
+		parts = append(parts, "duplex")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// output writes the result to Options.Output, if set.
+func (c *FastCollector) output(ctx context.Context, result Result) {
+	if c.opts.Output == nil {
+		return
+	}
+	bts, err := json.Marshal(result)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to encode result")
+		return
+	}
+	c.outputMutex.Lock()
+	defer c.outputMutex.Unlock()
+	if _, err := c.opts.Output.Write(append(bts, '\n')); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to output result")
+	}
+}
+
+// count adds the bytes and duration of the result to the counters, and its
+// complete requests to the histograms.
+func (c *FastCollector) count(direction string, result *fast.Result) {
+	if result == nil {
+		return
+	}
+	c.measuredBytes.WithLabelValues(direction).Add(float64(result.Bytes))
+	c.measuredSeconds.WithLabelValues(direction).Add(result.Duration.Seconds())
+	for _, t := range result.Transfers {
+		if !t.Complete {
+			continue
+		}
+		host := ""
+		if u, err := url.Parse(t.URL); err == nil {
+			host = u.Hostname()
+		}
+		c.requestDuration.WithLabelValues(direction, host).Observe(t.Duration.Seconds())
+		c.requestSize.WithLabelValues(direction).Observe(float64(t.Bytes))
+	}
+}
+
+// Trigger measures right away, regardless of the cached result, which is
+// replaced on success, unless paused.
+// It waits for measurements already in progress.
+// Its results are tagged as triggered through the API.
+func (c *FastCollector) Trigger() (Result, error) {
+	return c.TriggerFrom(TriggerAPI)
+}
+
+// TriggerFrom is Trigger, tagging the result with the given source.
+func (c *FastCollector) TriggerFrom(source TriggerSource) (Result, error) {
+	if c.opts.LatencyOnly {
+		return Result{}, errLatencyOnly
+	}
+	if c.Paused() {
+		return Result{}, ErrPaused
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	hot, err := c.collect(context.Background(), c.opts.Measure, source)
+	if err != nil {
+		return hot, err
+	}
+	expiration := c.cacheTTL()
+	if c.isBackground() {
+		expiration = cache.NoExpiration
+	}
+	c.cache.Set("result", hot, expiration)
+	return hot, nil
+}
+
+// event begins an event of the given kind if detail is set, ending it
+// otherwise, if events are enabled.
+func (c *FastCollector) event(ctx context.Context, kind history.EventKind, t time.Time, detail string) {
+	if c.opts.Events == nil {
+		return
+	}
+	var err error
+	if detail != "" {
+		err = c.opts.Events.Begin(kind, t, detail)
+	} else {
+		err = c.opts.Events.End(kind, t)
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record event")
+	}
+}
+
+// dropDetail describes the anomaly of the result, if any.
+func dropDetail(result Result) string {
+	if result.Anomaly == nil {
+		return ""
+	}
+	return fmt.Sprintf(
+		"download %s, baseline %s",
+		result.Download.BytesPerSecond().Human(),
+		units.BytesPerSecond(result.Anomaly.Baseline).Human(),
+	)
+}
+
+// record adds the entry to the history, if enabled, returning its anomaly.
+func (c *FastCollector) record(ctx context.Context, entry history.Entry) *history.Anomaly {
+	if c.opts.History == nil {
+		return nil
+	}
+	entry, err := c.opts.History.Add(entry)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to record result")
+	}
+	if a := entry.Anomaly; a != nil {
+		log.Ctx(ctx).Warn().
+			Float64("z_score", a.ZScore).
+			Float64("baseline", a.Baseline).
+			Msg("download speed is anomalously low")
+	}
+	return entry.Anomaly
+}
+
+func (c *FastCollector) measure(ctx context.Context, opts fast.Options) (Result, error) {
+	log := log.Ctx(ctx)
+	if c.opts.LinkCheck != nil {
+		if err := c.checkLink(ctx, opts); err != nil {
+			return Result{}, err
+		}
+	}
+	if c.opts.CaptivePortal != nil {
+		if err := c.checkCaptivePortal(ctx, opts); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if c.duplex() {
+		hot, err := c.measureDuplex(ctx, fitIn(ctx, opts, 1))
+		if err == nil {
+			hot.Providers = c.measureProviders(ctx, opts)
+		}
+		return hot, err
+	}
+
+	phases := 1
+	if c.opts.Upload != nil {
+		phases = 2
+	}
+	log.Debug().Msg("collecting fast.com metrics")
+	download, err := fast.Measure(ctx, fitIn(ctx, opts, phases))
+	if err != nil {
+		return Result{}, err
+	}
+	logWarnings(ctx, download)
+
+	hot := Result{Download: *download}
+	hot.Providers = c.measureProviders(ctx, opts)
+	hot.Engines = c.compareEngines(ctx, opts, download)
+	if c.opts.Upload != nil {
+		log.Debug().Msg("measuring upload speed")
+		opts.Traceroute = false // already done for the download
+		upload, err := fast.MeasureUpload(ctx, fitIn(ctx, opts, 1), *c.opts.Upload)
+		if err != nil {
+			return Result{}, err
+		}
+		logWarnings(ctx, upload)
+		hot.Upload = upload
+	}
+	return hot, nil
+}
+
+func (c *FastCollector) measureDuplex(ctx context.Context, opts fast.Options) (Result, error) {
+	log.Ctx(ctx).Debug().Msg("collecting fast.com metrics in duplex mode")
+	duplex, err := fast.MeasureDuplex(ctx, opts, *c.opts.Upload)
+	if err != nil {
+		return Result{}, err
+	}
+	logWarnings(ctx, duplex.Download)
+	logWarnings(ctx, duplex.Upload)
+	return Result{
+		Download:      *duplex.Download,
+		Upload:        duplex.Upload,
+		LoadedLatency: duplex.LoadedLatency,
+	}, nil
+}
+
+// measureProviders measures the download speed of the other providers, one
+// after the other, leaving out the ones that fail, unless capped.
+func (c *FastCollector) measureProviders(ctx context.Context, opts fast.Options) map[string]*fast.Result {
+	if len(c.opts.Providers) == 0 || capped(ctx) {
+		return nil
+	}
+	// only meaningful for fast.com
+	opts.Traceroute = false
+	opts.Checksum = false
+	opts.KnownChecksums = nil
+	results := make(map[string]*fast.Result, len(c.opts.Providers))
+	for _, p := range c.opts.Providers {
+		log.Ctx(ctx).Debug().Str("provider", p.Name).Msg("measuring provider download speed")
+		result, err := p.Targets.Measure(ctx, opts)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("provider", p.Name).Msg("provider measurement failed")
+			continue
+		}
+		results[p.Name] = result
+	}
+	return results
+}
+
+// compareEngines measures the download again with the other engine, nil if
+// comparisons are disabled, capped or it failed.
+func (c *FastCollector) compareEngines(ctx context.Context, opts fast.Options, download *fast.Result) *EngineComparison {
+	if !c.opts.CompareEngines || capped(ctx) {
+		return nil
+	}
+	engine := c.opts.Engine
+	if engine == "" {
+		engine = fast.Legacy
+	}
+	other := engine.Other()
+	// only meaningful once
+	opts.Traceroute = false
+	log.Ctx(ctx).Debug().Str("engine", string(other)).Msg("measuring download speed with the other engine")
+	result, err := fast.Measure(ctx, opts.WithEngine(other))
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("engine", string(other)).Msg("engine comparison failed")
+		return nil
+	}
+	comparison := &EngineComparison{Speeds: map[fast.Engine]float64{
+		engine: download.Speed,
+		other:  result.Speed,
+	}}
+	if legacy := comparison.Speeds[fast.Legacy]; legacy > 0 {
+		comparison.Delta = (comparison.Speeds[fast.V2] - legacy) / legacy
+	}
+	return comparison
+}
+
+func (c *FastCollector) duplex() bool {
+	return c.opts.Duplex && c.opts.Upload != nil
+}
+
+func (c *FastCollector) checkCaptivePortal(ctx context.Context, opts fast.Options) error {
+	log.Ctx(ctx).Debug().Str("url", c.opts.CaptivePortal.URL).Msg("checking for captive portals")
+	detected, err := fast.DetectCaptivePortal(ctx, opts.Client, *c.opts.CaptivePortal)
+	if err != nil {
+		return fmt.Errorf("captive portal check failed: %w", err)
+	}
+
+	c.statusMutex.Lock()
+	c.captive = detected
+	c.statusMutex.Unlock()
+
+	if detected {
+		return fast.ErrCaptivePortal
+	}
+	return nil
+}
+
+func (c *FastCollector) checkLink(ctx context.Context, opts fast.Options) error {
+	log.Ctx(ctx).Debug().Str("url", c.opts.LinkCheck.URL).Msg("checking the link")
+	err := fast.CheckLink(ctx, opts.Client, *c.opts.LinkCheck)
+	down := errors.Is(err, fast.ErrLinkDown)
+	var detail string
+	if down {
+		detail = normalizeError(err)
+	}
+	c.event(ctx, history.LinkDown, time.Now(), detail)
+
+	c.statusMutex.Lock()
+	c.down = down
+	c.statusMutex.Unlock()
+
+	return err
+}
+
+func (c *FastCollector) isLinkDown() bool {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.down
+}
+
+func (c *FastCollector) captiveDetected() bool {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.captive
+}
+
+func (c *FastCollector) write(ctx context.Context, result sinks.Result) {
+	for _, s := range c.opts.Sinks {
+		ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+		if err := s.Write(ctx, result); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to write result to sink")
+		}
+		cancel()
+	}
+}
+
+func logWarnings(ctx context.Context, result *fast.Result) {
+	for _, warning := range result.Warnings {
+		log.Ctx(ctx).Warn().Msg(warning)
+	}
+	if result.Incomplete {
+		log.Ctx(ctx).Warn().Strs("errors", result.Errors).Msg("some requests failed, the result is partial")
+	}
+}
+
+// newMeasurementID returns a random ID used to correlate a measurement with
+// its logs and results.
+func newMeasurementID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
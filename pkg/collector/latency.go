@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/rs/zerolog/log"
+)
+
+// LatencyProbe configures idle latency probes between measurements.
+type LatencyProbe struct {
+	// Interval is the time between probes, defaults to 1m.
+	Interval time.Duration
+	// Latency configures each probe.
+	Latency fast.LatencyOptions
+}
+
+const defaultLatencyInterval = time.Minute
+
+// errLatencyOnly happens when triggering a measurement in latency-only
+// mode.
+var errLatencyOnly = errors.New("throughput measurements are disabled in latency-only mode")
+
+// RunLatency probes the idle latency according to Options.Latency until the
+// context is canceled, pausing while full measurements run or measurements
+// are paused.
+// It does nothing if Options.Latency is nil.
+func (c *FastCollector) RunLatency(ctx context.Context) {
+	if c.opts.Latency == nil {
+		return
+	}
+	interval := c.opts.Latency.Interval
+	if interval <= 0 {
+		interval = defaultLatencyInterval
+	}
+	log.Info().Msgf("probing latency every %s", interval)
+
+	var targets targetsCache
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if atomic.LoadInt32(&c.measuring) == 0 && !c.Paused() {
+			result, err := c.probeLatency(ctx, &targets)
+			if err != nil {
+				log.Warn().Err(err).Msg("latency probe failed")
+			} else {
+				log.Debug().Dur("latency", result.Latency).Dur("jitter", result.Jitter).Float64("loss", result.Loss).Msg("probed latency")
+			}
+			c.statusMutex.Lock()
+			c.latency = result
+			c.statusMutex.Unlock()
+			c.probeGateway(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeLatency probes the latency to the cached targets, which are reset on
+// failure.
+func (c *FastCollector) probeLatency(ctx context.Context, targets *targetsCache) (*fast.LatencyResult, error) {
+	t, err := targets.get(ctx, c.opts.Measure)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.MeasureLatency(ctx, c.opts.Measure, c.opts.Latency.Latency)
+	if err != nil {
+		targets.reset()
+	}
+	return result, err
+}
+
+// targetsCache reuses discovered targets until they expire.
+type targetsCache struct {
+	targets *fast.Targets
+}
+
+func (t *targetsCache) get(ctx context.Context, opts fast.Options) (*fast.Targets, error) {
+	if t.targets == nil || t.targets.Expired() {
+		targets, err := fast.Discover(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		t.targets = targets
+	}
+	return t.targets, nil
+}
+
+func (t *targetsCache) reset() {
+	t.targets = nil
+}
+
+// lastLatency returns the result of the last latency probe, nil if it
+// failed.
+func (c *FastCollector) lastLatency() *fast.LatencyResult {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.latency
+}
+
+// probeGateway probes the latency to the default gateway with the idle
+// latency probe options, if Options.Gateway is set, returning the result,
+// nil if it failed.
+func (c *FastCollector) probeGateway(ctx context.Context) *fast.GatewayResult {
+	if !c.opts.Gateway {
+		return nil
+	}
+	var latency fast.LatencyOptions
+	if c.opts.Latency != nil {
+		latency = c.opts.Latency.Latency
+	}
+	result, err := fast.MeasureGateway(ctx, latency)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("gateway probe failed")
+	} else {
+		log.Ctx(ctx).Debug().Str("gateway", result.Gateway).Dur("latency", result.Latency).Float64("loss", result.Loss).Msg("probed gateway latency")
+	}
+	c.statusMutex.Lock()
+	c.gateway = result
+	c.statusMutex.Unlock()
+	return result
+}
+
+// lastGateway returns the result of the last gateway probe, nil if it
+// failed.
+func (c *FastCollector) lastGateway() *fast.GatewayResult {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.gateway
+}
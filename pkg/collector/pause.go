@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrPaused happens when triggering a measurement while paused.
+var ErrPaused = errors.New("measurements are paused")
+
+// Pause suspends measurements, e.g. during backups or video calls, for the
+// given duration, or until Resume is called if zero.
+// While paused, scrapes return the last result, if still cached.
+func (c *FastCollector) Pause(d time.Duration) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.paused = true
+	c.pausedUntil = time.Time{}
+	if d > 0 {
+		c.pausedUntil = time.Now().Add(d)
+	}
+	log.Info().Msg("measurements paused")
+}
+
+// Resume resumes measurements suspended by Pause.
+func (c *FastCollector) Resume() {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if c.paused {
+		log.Info().Msg("measurements resumed")
+	}
+	c.paused = false
+	c.pausedUntil = time.Time{}
+}
+
+// Paused returns whether measurements are paused, by Pause or while an
+// excluded process runs.
+// The processes are listed outside of statusMutex, so a slow listing does not
+// block the collector.
+func (c *FastCollector) Paused() bool {
+	if c.excluded.check() != "" {
+		return true
+	}
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	return c.pausedByUser()
+}
+
+// pausedByUser returns whether measurements are paused by Pause, with
+// statusMutex held.
+func (c *FastCollector) pausedByUser() bool {
+	if !c.paused {
+		return false
+	}
+	return c.pausedUntil.IsZero() || time.Now().Before(c.pausedUntil)
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestPaused(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		pause    time.Duration
+		resume   bool
+		running  string
+		expected bool
+	}{
+		{name: "running"},
+		{name: "paused", pause: -1, expected: true},
+		{name: "paused for a while", pause: time.Hour, expected: true},
+		{name: "resumed", pause: time.Hour, resume: true},
+		{name: "excluded process", running: "zoom", expected: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewFastCollector(cache.New(time.Minute, time.Minute), Options{ExcludeProcesses: []string{"zoom"}})
+			// skip listing the processes, as they were just checked
+			c.excluded.checked = time.Now()
+			c.excluded.running = tt.running
+			if tt.pause != 0 {
+				c.Pause(tt.pause)
+			}
+			if tt.resume {
+				c.Resume()
+			}
+			if paused := c.Paused(); paused != tt.expected {
+				t.Fatalf("expected paused to be %v, got %v", tt.expected, paused)
+			}
+			if paused := c.Status().Paused; paused != tt.expected {
+				t.Fatalf("expected the status to be paused %v, got %v", tt.expected, paused)
+			}
+		})
+	}
+}
+
+func TestPausedWithoutStatusLock(t *testing.T) {
+	c := NewFastCollector(cache.New(time.Minute, time.Minute), Options{ExcludeProcesses: []string{"zoom"}})
+	c.excluded.checked = time.Now()
+	c.excluded.running = "zoom"
+
+	// a slow process listing holds the exclusions, not the status
+	c.excluded.mutex.Lock()
+	done := make(chan bool)
+	go func() { done <- c.Paused() }()
+	c.statusMutex.Lock()
+	_ = c.lastRun
+	c.statusMutex.Unlock()
+	c.excluded.mutex.Unlock()
+	if !<-done {
+		t.Fatal("expected measurements to be paused")
+	}
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+)
+
+// Schedule configures background measurements.
+type Schedule struct {
+	// Interval is the time between measurements.
+	Interval time.Duration
+	// StartupDelay is the maximum random delay before the first measurement.
+	StartupDelay time.Duration
+	// Jitter is the maximum random time added to each interval.
+	Jitter time.Duration
+	// MaxBackoff caps the interval, which doubles after each consecutive
+	// failed measurement past the first one until one succeeds, so a broken
+	// discovery does not hammer fast.com.
+	// Zero disables the backoff.
+	MaxBackoff time.Duration
+}
+
+// interval returns the time until the next measurement after the given
+// number of consecutive failures, without jitter.
+func (s Schedule) interval(failures int) time.Duration {
+	interval := s.Interval
+	if s.MaxBackoff <= interval {
+		return interval
+	}
+	for i := 1; i < failures && interval < s.MaxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > s.MaxBackoff {
+		return s.MaxBackoff
+	}
+	return interval
+}
+
+// nolint: gochecknoglobals
+var (
+	randMutex sync.Mutex
+	random    = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random duration in [0, max).
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return time.Duration(random.Int63n(int64(max)))
+}
+
+// Run measures in the background according to the collector schedule until
+// the context is canceled.
+// While it runs, Collect returns the last result instead of measuring on
+// scrape.
+func (c *FastCollector) Run(ctx context.Context) {
+	delay := jitter(c.opts.Schedule.StartupDelay)
+	c.setNextRun(delay)
+	log.Info().Msgf("first measurement in %s", delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if c.Paused() {
+			log.Debug().Msg("measurements paused, skipping")
+		} else {
+			c.refresh()
+		}
+
+		failures, _ := c.failureStatus()
+		if c.isLinkDown() {
+			// the check is cheap, measure again as soon as the link is back
+			failures = 0
+		}
+		interval := c.opts.Schedule.interval(failures)
+		if interval > c.opts.Schedule.Interval {
+			log.Warn().Int("failures", failures).Msgf("backing off to %s after repeated failures", interval)
+		}
+		next := interval + jitter(c.opts.Schedule.Jitter)
+		c.setNextRun(next)
+		log.Debug().Msgf("next measurement in %s", next)
+		timer.Reset(next)
+	}
+}
+
+func (c *FastCollector) refresh() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	hot, err := c.collect(context.Background(), c.opts.Measure, TriggerSchedule)
+	if err != nil {
+		log.Error().Err(err).Msg("fast.com measurement failed")
+		c.cache.Delete("result")
+		return
+	}
+	c.cache.Set("result", hot, cache.NoExpiration)
+}
+
+func (c *FastCollector) setNextRun(in time.Duration) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.background = true
+	c.nextRun = time.Now().Add(in)
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeBudget is the fraction of the scrape timeout measurements may take,
+// pre-checks and discovery included, the rest is left for the scrape itself.
+const scrapeBudget = 0.8
+
+// defaultMaxDuration is the measurement duration when none is configured,
+// the same as fast.com.
+const defaultMaxDuration = 30 * time.Second
+
+// Scrape configures the measurement made by a single scrape, if it needs
+// one.
+type Scrape struct {
+	// Timeout bounds the whole measurement so it fits in the scrape timeout,
+	// skipping its optional phases, like the gateway probe, traceroute,
+	// providers and engine comparisons, ignored if zero.
+	Timeout time.Duration
+	// Burst measures with fast.Options.Burst.
+	Burst bool
+}
+
+// ForScrape returns a collector that, when it needs to measure on scrape,
+// does so according to the given scrape configuration.
+func (c *FastCollector) ForScrape(s Scrape) prometheus.Collector {
+	opts := c.opts.Measure
+	if s.Burst {
+		opts = opts.Burst()
+	}
+	return &scrapeCollector{
+		FastCollector: c,
+		opts:          opts,
+		budget:        time.Duration(float64(s.Timeout) * scrapeBudget),
+	}
+}
+
+type scrapeCollector struct {
+	*FastCollector
+	opts   fast.Options
+	budget time.Duration
+}
+
+func (c *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	if c.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.budget)
+		defer cancel()
+	}
+	c.collectWith(ctx, ch, c.opts)
+}
+
+// capped tells whether the measurement must fit in a scrape timeout, in which
+// case its optional phases are skipped.
+func capped(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+}
+
+// fitIn returns the measurement options with their duration capped so that
+// the given number of measurement phases left fit in the time left until
+// the deadline of ctx, if any.
+func fitIn(ctx context.Context, opts fast.Options, phases int) fast.Options {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return opts
+	}
+	// only meaningful without a deadline
+	opts.Traceroute = false
+	budget := time.Until(deadline) / time.Duration(phases)
+	max := opts.MaxDuration
+	if max <= 0 {
+		max = defaultMaxDuration
+	}
+	if budget < max {
+		// a zero duration would mean the default one
+		opts.MaxDuration = budget
+		if opts.MaxDuration <= 0 {
+			opts.MaxDuration = time.Nanosecond
+		}
+	}
+	return opts
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeFastCom returns a client sending every request to a fake fast.com,
+// whose discovery takes discovery, and whose downloads and uploads never
+// finish on their own.
+func fakeFastCom(t *testing.T, discovery time.Duration) *http.Client {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			time.Sleep(discovery)
+			fmt.Fprint(w, `<script src="/app-abc.js"></script>`)
+		case strings.HasPrefix(r.URL.Path, "/app-"):
+			fmt.Fprint(w, `foo={token:"YXNkZmFzZGxmbnNkYWZoYXNkZmhrYWxm"}`)
+		case r.URL.Path == "/netflix/speedtest/v2":
+			fmt.Fprint(w, `{"client":{"ip":"192.0.2.1","location":{"city":"Sao Paulo","country":"BR"},"isp":"Foo"},"targets":[{"name":"a","url":"https://a.example/speedtest?c=br","location":{"city":"Sao Paulo","country":"BR"}}]}`)
+		case r.URL.Path == "/generate_204":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost:
+			_, _ = io.Copy(io.Discard, r.Body)
+		default:
+			buf := make([]byte, 1024)
+			for {
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	addr := srv.Listener.Addr().String()
+	dialer := &net.Dialer{}
+	return &http.Client{Transport: &http.Transport{
+		// nolint: gosec
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestScrapeFitsInTimeout(t *testing.T) {
+	client := fakeFastCom(t, 300*time.Millisecond)
+	const timeout = 2 * time.Second
+	for name, opts := range map[string]Options{
+		"download": {},
+		"upload":   {Upload: &fast.UploadOptions{}},
+		"duplex":   {Upload: &fast.UploadOptions{}, Duplex: true},
+		"optional phases": {
+			Upload:         &fast.UploadOptions{},
+			LinkCheck:      &fast.LinkCheck{URL: "https://fast.com"},
+			CaptivePortal:  &fast.CaptivePortalCheck{URL: "https://fast.com/generate_204"},
+			Gateway:        true,
+			CompareEngines: true,
+			Providers: []Provider{
+				{Name: "other", Targets: fast.TargetsOf("https://b.example/speedtest")},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts.Measure = fast.Options{Client: client, Traceroute: true}
+			c := NewFastCollector(cache.New(time.Minute, time.Minute), opts)
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(c.ForScrape(Scrape{Timeout: timeout}))
+
+			start := time.Now()
+			if _, err := registry.Gather(); err != nil {
+				t.Fatal(err)
+			}
+			if took := time.Since(start); took > timeout {
+				t.Fatalf("expected the scrape to take less than %s, took %s", timeout, took)
+			}
+			result, ok := c.LastResult()
+			if !ok {
+				t.Fatal("expected a result")
+			}
+			if result.Download.Bytes == 0 {
+				t.Error("expected the download to transfer some bytes")
+			}
+			if result.Download.Path != nil || result.Gateway != nil || result.Providers != nil || result.Engines != nil {
+				t.Error("expected the optional phases to be skipped")
+			}
+		})
+	}
+}
+
+func TestFitIn(t *testing.T) {
+	opts := fitIn(context.Background(), fast.Options{Traceroute: true}, 2)
+	if opts.MaxDuration != 0 || !opts.Traceroute {
+		t.Fatalf("expected options without a deadline to be kept, got %+v", opts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opts = fitIn(ctx, fast.Options{Traceroute: true}, 2)
+	if opts.MaxDuration <= 4*time.Second || opts.MaxDuration > 5*time.Second || opts.Traceroute {
+		t.Fatalf("expected half the time left without traceroute, got %+v", opts)
+	}
+	opts = fitIn(ctx, fast.Options{MaxDuration: time.Second}, 2)
+	if opts.MaxDuration != time.Second {
+		t.Fatalf("expected shorter durations to be kept, got %s", opts.MaxDuration)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if opts = fitIn(expired, fast.Options{}, 1); opts.MaxDuration <= 0 || opts.MaxDuration > time.Millisecond {
+		t.Fatalf("expected a tiny duration past the deadline, got %s", opts.MaxDuration)
+	}
+}
@@ -0,0 +1,23 @@
+package collector
+
+// TriggerSource is how a measurement was triggered.
+type TriggerSource string
+
+const (
+	// TriggerSchedule is a measurement of the background schedule.
+	TriggerSchedule TriggerSource = "schedule"
+	// TriggerScrape is a measurement on scrape.
+	TriggerScrape TriggerSource = "scrape"
+	// TriggerAPI is an on-demand measurement through the API or a chat
+	// command.
+	TriggerAPI TriggerSource = "api"
+	// TriggerCLI is an on-demand measurement of the one-shot mode.
+	TriggerCLI TriggerSource = "cli"
+)
+
+// OnDemand returns whether the measurement was asked for, e.g. when
+// debugging, instead of taken periodically, so it is left out of long term
+// statistics.
+func (t TriggerSource) OnDemand() bool {
+	return t == TriggerAPI || t == TriggerCLI
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/exporter"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	e := exporter.New(
+		exporter.WithAddress(":9877"),
+		exporter.WithBackground(),
+		exporter.WithOptions(collector.Options{
+			Schedule: collector.Schedule{Interval: time.Hour},
+		}),
+		exporter.WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				log.Info().Str("path", r.URL.Path).Msg("request")
+				next.ServeHTTP(w, r)
+			})
+		}),
+		exporter.WithHandler("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})),
+	)
+	if err := e.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("exporter failed")
+	}
+}
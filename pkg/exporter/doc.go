@@ -0,0 +1,6 @@
+// Package exporter wires the collector, its schedulers and HTTP server
+// together, so the whole exporter can be embedded in another Go service.
+//
+// The exported API follows semantic versioning along with the exporter: it
+// only changes in backwards-incompatible ways on major releases.
+package exporter
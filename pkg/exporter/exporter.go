@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAddress  = ":9877"
+	defaultInterval = 30 * time.Minute
+	// shutdownTimeout is how long requests in flight have to finish once
+	// the context of Run is canceled.
+	shutdownTimeout = 10 * time.Second
+)
+
+// Exporter is the collector along with the HTTP server exposing it.
+type Exporter struct {
+	address    string
+	opts       collector.Options
+	background bool
+	registry   *prometheus.Registry
+	middleware []func(http.Handler) http.Handler
+	routes     []route
+	collector  *collector.FastCollector
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithAddress sets the address the server listens on, defaults to :9877.
+func WithAddress(address string) Option {
+	return func(e *Exporter) {
+		e.address = address
+	}
+}
+
+// WithOptions sets the collector options.
+// The refresh interval defaults to 30m, like the exporter.
+func WithOptions(opts collector.Options) Option {
+	return func(e *Exporter) {
+		e.opts = opts
+	}
+}
+
+// WithBackground measures in the background according to the schedule,
+// instead of on scrape.
+func WithBackground() Option {
+	return func(e *Exporter) {
+		e.background = true
+	}
+}
+
+// WithRegistry registers the collector in registry, serving all of its
+// metrics, instead of a new registry with only the exporter ones.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(e *Exporter) {
+		e.registry = registry
+	}
+}
+
+// WithMiddleware wraps the handler of every route with middleware, the first
+// one being the outermost, e.g. to add authentication or logging.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(e *Exporter) {
+		e.middleware = append(e.middleware, middleware...)
+	}
+}
+
+// WithHandler serves handler on pattern too, as in http.ServeMux.
+func WithHandler(pattern string, handler http.Handler) Option {
+	return func(e *Exporter) {
+		e.routes = append(e.routes, route{pattern: pattern, handler: handler})
+	}
+}
+
+// New creates an exporter with the given options.
+func New(opts ...Option) *Exporter {
+	e := &Exporter{address: defaultAddress}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.opts.Schedule.Interval <= 0 {
+		e.opts.Schedule.Interval = defaultInterval
+	}
+	if e.registry == nil {
+		e.registry = prometheus.NewRegistry()
+	}
+	interval := e.opts.Schedule.Interval
+	e.collector = collector.NewFastCollector(cache.New(interval, interval), e.opts)
+	e.registry.MustRegister(e.collector)
+	return e
+}
+
+// Collector returns the collector, e.g. to trigger or pause measurements.
+func (e *Exporter) Collector() *collector.FastCollector {
+	return e.collector
+}
+
+// Handler returns the handler of all routes, with the middleware, to mount
+// in an existing server instead of calling Run:
+//
+//	/metrics                the metrics, measuring on scrape if needed
+//	/api/v1/results/latest  the last result, as JSON
+//	/api/v1/measure         POST to measure right away
+//
+// Along with the routes added by WithHandler.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{}))
+	mux.Handle("/api/v1/results/latest", LatestResultHandler(e.collector))
+	mux.Handle("/api/v1/measure", MeasureHandler(e.collector))
+	for _, r := range e.routes {
+		mux.Handle(r.pattern, r.handler)
+	}
+	var handler http.Handler = mux
+	for i := len(e.middleware) - 1; i >= 0; i-- {
+		handler = e.middleware[i](handler)
+	}
+	return handler
+}
+
+// RunBackground runs the schedulers of the collector until the context is
+// canceled: the background measurements, if WithBackground is set, and the
+// continuous measurement and latency probes, if configured.
+// It is called by Run, and only needed along with Handler otherwise.
+func (e *Exporter) RunBackground(ctx context.Context) {
+	if e.background && !e.opts.LatencyOnly {
+		go e.collector.Run(ctx)
+	}
+	go e.collector.RunContinuous(ctx)
+	go e.collector.RunLatency(ctx)
+}
+
+// Run runs the schedulers and serves Handler until the context is canceled,
+// when the server is shut down gracefully, returning nil.
+// The schedulers are stopped on return, also when the server fails.
+func (e *Exporter) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	e.RunBackground(ctx)
+	server := &http.Server{Addr: e.address, Handler: e.Handler()}
+	errs := make(chan error, 1)
+	go func() {
+		log.Info().Msgf("listening on %s", e.address)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
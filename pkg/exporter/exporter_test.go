@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return nil, errors.New("offline")
+}
+
+func TestRunStopsSchedulersOnError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	transport := &countingTransport{}
+	e := New(
+		WithAddress(ln.Addr().String()),
+		WithOptions(collector.Options{
+			Measure: fast.Options{Client: &http.Client{Transport: transport}},
+			Latency: &collector.LatencyProbe{Interval: 10 * time.Millisecond},
+		}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := e.Run(ctx); err == nil {
+		t.Fatal("expected an error listening on a busy address")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	requests := atomic.LoadInt32(&transport.requests)
+	time.Sleep(100 * time.Millisecond)
+	if after := atomic.LoadInt32(&transport.requests); after != requests {
+		t.Fatalf("expected the latency probes to stop, got %d more requests", after-requests)
+	}
+}
@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/rs/zerolog/log"
+)
+
+// LatestResultHandler serves the last result of c as JSON, 404 if there is
+// none yet.
+func LatestResultHandler(c *collector.FastCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, ok := c.LastResult()
+		if !ok {
+			http.Error(w, "no results yet", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Error().Err(err).Msg("failed to encode result")
+		}
+	}
+}
+
+// MeasureHandler triggers a measurement of c on POST, serving its result as
+// JSON, 409 if measurements are paused.
+func MeasureHandler(c *collector.FastCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		result, err := c.Trigger()
+		if errors.Is(err, collector.ErrPaused) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Error().Err(err).Msg("failed to encode result")
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+func main() {
+	result, err := fast.Measure(context.Background(), fast.Options{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.BytesPerSecond().Human(), result.Warnings)
+
+	result, err = fast.MeasureUpload(context.Background(), fast.Options{}, fast.UploadOptions{Size: 100 * 1024 * 1024})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.BytesPerSecond().Human(), "upload", result.Warnings)
+}
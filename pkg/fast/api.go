@@ -0,0 +1,482 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	baseURL   = "https://fast.com"
+	userAgent = "caarlos0/fastcom-exporter/v1"
+)
+
+var urlRE = regexp.MustCompile(`(?U)"url":"(.*)"`)
+
+// maxScripts is the maximum number of scripts of the fast.com page searched
+// for the token.
+const maxScripts = 5
+
+const (
+	// discoveryTimeout is how long each request to fast.com when discovering
+	// can take, so a hanging response does not stall the measurement.
+	discoveryTimeout = 15 * time.Second
+	// maxPageSize is the maximum size of the pages read when discovering.
+	maxPageSize = 8 << 20
+)
+
+// Measure discovers test servers and measures the download speed.
+func Measure(ctx context.Context, opts Options) (*Result, error) {
+	targets, err := Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return targets.Measure(ctx, opts)
+}
+
+// MeasureUpload discovers test servers and measures the upload speed.
+func MeasureUpload(ctx context.Context, opts Options, upload UploadOptions) (*Result, error) {
+	targets, err := Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return targets.MeasureUpload(ctx, opts, upload)
+}
+
+func downloadFunc(opts Options) requestFunc {
+	buffers := sync.Pool{
+		New: func() interface{} {
+			return make([]byte, opts.BufferSize)
+		},
+	}
+	return func(ctx context.Context, url string, counter *byteCounter) error {
+		buf := buffers.Get().([]byte)
+		defer buffers.Put(buf) // nolint: staticcheck
+		return doMeasure(ctx, opts, url, buf, counter)
+	}
+}
+
+func uploadFuncs(upload UploadOptions) func(Options) requestFunc {
+	return func(opts Options) requestFunc {
+		return uploadFunc(opts, upload)
+	}
+}
+
+func uploadFunc(opts Options, upload UploadOptions) requestFunc {
+	if upload.ChunkSize <= 0 {
+		upload.ChunkSize = defaultChunkSize
+	}
+	remaining := upload.Size
+	return func(ctx context.Context, url string, counter *byteCounter) error {
+		size := upload.ChunkSize
+		if upload.Size > 0 {
+			size = reserve(&remaining, upload.ChunkSize)
+			if size == 0 {
+				return errDone
+			}
+		}
+		return doUpload(ctx, opts, url, NewPayload(size, upload.Random), counter)
+	}
+}
+
+// errDone is returned by a request function when no more requests should be
+// made.
+var errDone = errors.New("measurement done")
+
+type requestFunc func(ctx context.Context, url string, counter *byteCounter) error
+
+func measure(ctx context.Context, direction Direction, servers []Server, opts Options, newFn func(Options) requestFunc) (result *Result, err error) {
+	opts.Hooks.start(direction, servers)
+	defer func() { opts.Hooks.complete(direction, result, err) }()
+
+	pick, err := newPicker(ctx, opts, servers)
+	if err != nil {
+		return nil, err
+	}
+	var tracker *tcpTracker
+	if opts.TCPInfo {
+		opts, tracker = trackTCP(opts)
+	}
+	fn := newFn(opts)
+
+	var wg sync.WaitGroup
+	var done int32
+	var requests, failed int64
+	var failures requestErrors
+	var all transfers
+
+	sem := semaphore.NewWeighted(int64(opts.Connections))
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, opts.MaxDuration)
+	defer cancel()
+	sumBytes := &byteCounter{max: opts.MaxBytes, stop: cancel}
+
+	var wifi *WiFi
+	if opts.WiFi {
+		wifi = sampleWiFi(ctx)
+	}
+	cpuStart := sampleCPU()
+	var thermalStart thermalSample
+	if opts.Thermal {
+		thermalStart = sampleThermal()
+	}
+	start := time.Now()
+	var sizing *sizer
+	if opts.Adaptive && direction == Download {
+		if sizing, err = startSizer(ctx, opts, sem, sumBytes, start); err != nil {
+			return nil, err
+		}
+	}
+	samples := startSampler(start, sumBytes, opts.Hooks.progress(direction))
+	var replacing *replacer
+	if opts.ReplaceSlowest {
+		replacing = startReplacer(ctx)
+	}
+
+outer:
+	for {
+		select {
+		case <-ctx.Done():
+			break outer
+		default:
+			err := sem.Acquire(ctx, 1)
+			if err != nil {
+				if !isDone(err) {
+					samples.stop()
+					return nil, err
+				}
+				break outer
+			}
+			if atomic.LoadInt32(&done) == 1 {
+				sem.Release(1)
+				break outer
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer sem.Release(1)
+				// a failed request only loses its own remaining bytes, what
+				// was transferred until then and by the other requests is
+				// still accounted for.
+				raw := replacing.next(pick)
+				transfer := Transfer{URL: raw}
+				if sizing != nil {
+					transfer.URL = sizing.url(raw)
+				}
+				counter := &byteCounter{parent: sumBytes}
+				requestStart := time.Now()
+				requestCtx, req := replacing.start(ctx, raw, counter)
+				err := fn(traceTransfer(requestCtx, &transfer), transfer.URL, counter)
+				transfer.Replaced = replacing.done(req)
+				switch {
+				case errors.Is(err, errDone):
+					// let in-flight requests finish
+					atomic.StoreInt32(&done, 1)
+					return
+				case err != nil && !isDone(err):
+					atomic.AddInt64(&failed, 1)
+					failures.add(err)
+					transfer.Error = err.Error()
+				case err == nil:
+					transfer.Complete = true
+				}
+				atomic.AddInt64(&requests, 1)
+				transfer.Bytes = counter.load()
+				transfer.Duration = time.Since(requestStart)
+				all.add(transfer)
+			}()
+		}
+	}
+
+	wg.Wait()
+	sampled := samples.stop()
+	if failures.first != nil && sumBytes.load() == 0 {
+		return nil, failures.first
+	}
+
+	duration := time.Since(start)
+	result = &Result{
+		Bytes:     sumBytes.load(),
+		Start:     start.Round(0),
+		End:       time.Now().Round(0),
+		Duration:  duration,
+		Servers:   pick.servers,
+		Requests:  atomic.LoadInt64(&requests),
+		Failed:    atomic.LoadInt64(&failed),
+		Transfers: all.list,
+		Replaced:  replacing.count(),
+		WiFi:      wifi,
+	}
+	if failures.first != nil {
+		result.Incomplete = true
+		result.Errors = failures.messages
+	}
+	result.Speed = float64(result.Bytes) / duration.Seconds()
+	if opts.Estimator != nil {
+		result.Speed = opts.Estimator.Estimate(sampled)
+	}
+	result.Samples = limitSamples(resample(sampled, resultSampleInterval), opts.MaxSamples)
+	checkCPU(result, cpuStart, sampleCPU())
+	if opts.Thermal {
+		checkThermal(result, thermalStart, sampleThermal())
+	}
+	checkTampering(result, opts)
+	if tracker != nil {
+		result.TCP = tracker.stats()
+	}
+	if sizing != nil {
+		result.Tier = sizing.result()
+	}
+	if opts.Peering {
+		result.Peering = peerings(result.Transfers, opts.ASN)
+	}
+	if opts.GeoIP != nil {
+		result.Servers = locateServers(opts.GeoIP, result.Servers, result.Transfers)
+	}
+
+	if opts.Traceroute {
+		path, err := traceMeasured(parent, result.Transfers)
+		if err != nil {
+			result.Warnings = append(result.Warnings, "traceroute failed: "+err.Error())
+		}
+		result.Path = path
+	}
+	return result, nil
+}
+
+// maxRequestErrors is the maximum number of distinct request errors kept in
+// a result.
+const maxRequestErrors = 10
+
+// requestErrors collects the distinct errors of failed requests.
+type requestErrors struct {
+	mutex    sync.Mutex
+	first    error
+	messages []string
+}
+
+func (e *requestErrors) add(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.first == nil {
+		e.first = err
+	}
+	msg := err.Error()
+	for _, m := range e.messages {
+		if m == msg {
+			return
+		}
+	}
+	if len(e.messages) < maxRequestErrors {
+		e.messages = append(e.messages, msg)
+	}
+}
+
+func isDone(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// reserve takes up to n bytes from the remaining budget, returning how many
+// were taken.
+func reserve(remaining *int64, n int64) int64 {
+	for {
+		left := atomic.LoadInt64(remaining)
+		if left <= 0 {
+			return 0
+		}
+		if n > left {
+			n = left
+		}
+		if atomic.CompareAndSwapInt64(remaining, left, left-n) {
+			return n
+		}
+	}
+}
+
+func doMeasure(ctx context.Context, opts Options, url string, buf []byte, counter *byteCounter) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	opts.setHeaders(req)
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	captureHeaders(ctx, opts.CaptureHeaders, resp.Header)
+	if !opts.Checksum {
+		return discard(resp.Body, buf, counter, nil)
+	}
+	if compressed(resp) {
+		return errCompressed
+	}
+	h := xxhash.New()
+	if err := discard(resp.Body, buf, counter, h); err != nil {
+		return err
+	}
+	setChecksum(ctx, h.Sum64())
+	return nil
+}
+
+// errCompressed happens when a download was compressed, with
+// Options.Checksum set.
+var errCompressed = errors.New("download was compressed by something in the path")
+
+// discard reads r until EOF using the given buffer, counting the bytes read
+// and hashing them if h is not nil.
+func discard(r io.Reader, buf []byte, counter *byteCounter, h *xxhash.Digest) error {
+	for {
+		n, err := r.Read(buf)
+		counter.add(n)
+		if h != nil {
+			_, _ = h.Write(buf[:n])
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func doUpload(ctx context.Context, opts Options, url string, payload *Payload, counter *byteCounter) error {
+	size := payload.Len()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &countingReader{r: payload, counter: counter})
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	opts.setHeaders(req)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	captureHeaders(ctx, opts.CaptureHeaders, resp.Header)
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// countingReader atomically adds every byte read to counter.
+type countingReader struct {
+	r       io.Reader
+	counter *byteCounter
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.counter.add(n)
+	return n, err
+}
+
+// byteCounter atomically counts the bytes transferred in a measurement,
+// calling stop once max bytes are reached, if max is positive.
+// Bytes are also added to the parent counter, if any.
+type byteCounter struct {
+	n      int64
+	max    int64
+	stop   func()
+	parent *byteCounter
+}
+
+func (c *byteCounter) add(n int) {
+	total := atomic.AddInt64(&c.n, int64(n))
+	if c.max > 0 && total >= c.max {
+		c.stop()
+	}
+	if c.parent != nil {
+		c.parent.add(n)
+	}
+}
+
+func (c *byteCounter) load() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+func getToken(ctx context.Context, client *http.Client) string {
+	log := logger(ctx)
+	fastBody, err := getPage(ctx, client, baseURL)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting fast page")
+	}
+
+	base, _ := url.Parse(baseURL)
+	scripts := scriptURLs(fastBody, base)
+	if len(scripts) == 0 {
+		log.Warn().Msg("no script found in fast page")
+		return ""
+	}
+	if len(scripts) > maxScripts {
+		scripts = scripts[:maxScripts]
+	}
+
+	for _, scriptURL := range scripts {
+		if ctx.Err() != nil {
+			log.Warn().Err(ctx.Err()).Msg("stopped looking for the token")
+			return ""
+		}
+		scriptBody, err := getPage(ctx, client, scriptURL)
+		if err != nil {
+			log.Error().Err(err).Msgf("error getting fast script %s", scriptURL)
+			continue
+		}
+		if token := findToken(scriptBody); token != "" {
+			log.Debug().Str("token", token).Str("script", scriptURL).Msg("found token")
+			return token
+		}
+	}
+	log.Warn().Msg("no token found")
+	return ""
+}
+
+// getPage gets url, giving up after discoveryTimeout or once ctx is done.
+func getPage(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return []byte{}, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	logger(ctx).Debug().
+		Str("url", url).
+		Int("bytes", len(body)).
+		Dur("duration", time.Since(start)).
+		Msg("got page")
+	return body, err
+}
+
+// logger returns the logger in the context, falling back to the global one.
+func logger(ctx context.Context) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
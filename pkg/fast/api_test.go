@@ -0,0 +1,64 @@
+package fast
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// zeros reads endless zeros, without the cost of a real transfer.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// BenchmarkCountingReader measures the cost of counting every byte read,
+// with a parent counter as in measurements.
+func BenchmarkCountingReader(b *testing.B) {
+	const size = 1 << 20
+	buf := make([]byte, defaultBufferSize)
+	parent := &byteCounter{}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := &countingReader{r: io.LimitReader(zeros{}, size), counter: &byteCounter{parent: parent}}
+		if _, err := io.CopyBuffer(io.Discard, r, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMeasure measures the cost of the measurement worker loop, with
+// requests reading from memory until MaxBytes are transferred.
+func BenchmarkMeasure(b *testing.B) {
+	const (
+		requestSize = 1 << 20
+		maxBytes    = 64 << 20
+	)
+	servers := []Server{{URL: "https://example.com/a"}, {URL: "https://example.com/b"}}
+	opts := Options{MaxDuration: time.Minute, MaxBytes: maxBytes}.withDefaults()
+	newFn := func(opts Options) requestFunc {
+		return func(ctx context.Context, url string, counter *byteCounter) error {
+			buf := make([]byte, defaultBufferSize)
+			r := &countingReader{r: io.LimitReader(zeros{}, requestSize), counter: counter}
+			for ctx.Err() == nil {
+				if _, err := r.Read(buf); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+			}
+			return ctx.Err()
+		}
+	}
+	b.SetBytes(maxBytes)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := measure(context.Background(), Download, servers, opts, newFn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrCaptivePortal happens when a captive portal is intercepting requests,
+// in which case measuring would report the portal speed instead of the
+// internet speed.
+var ErrCaptivePortal = errors.New("captive portal detected")
+
+// CaptivePortalCheck configures the captive portal check.
+type CaptivePortalCheck struct {
+	// URL to probe, e.g. http://connectivitycheck.gstatic.com/generate_204.
+	URL string
+	// Expect is the content the URL responds with.
+	// If empty, a 204 No Content response is expected.
+	Expect string
+}
+
+const captivePortalTimeout = 10 * time.Second
+
+// DetectCaptivePortal probes the check URL with the given client, or
+// http.DefaultClient if nil, returning true if it did not respond with the
+// expected content.
+func DetectCaptivePortal(ctx context.Context, client *http.Client, check CaptivePortalCheck) (bool, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	probe := *client
+	// captive portals usually redirect to their login page
+	probe.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	ctx, cancel := context.WithTimeout(ctx, captivePortalTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := probe.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if check.Expect == "" {
+		return resp.StatusCode != http.StatusNoContent, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return true, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return false, err
+	}
+	return !strings.Contains(string(body), check.Expect), nil
+}
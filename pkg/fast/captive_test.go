@@ -0,0 +1,55 @@
+package fast_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDetectCaptivePortal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/generate_204":
+			w.WriteHeader(http.StatusNoContent)
+		case "/portal":
+			http.Redirect(w, r, "/generate_204", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	transport := &countingTransport{}
+	client := &http.Client{Transport: transport}
+	for path, want := range map[string]bool{
+		"/generate_204": false,
+		"/portal":       true,
+	} {
+		t.Run(path, func(t *testing.T) {
+			got, err := fast.DetectCaptivePortal(context.Background(), client, fast.CaptivePortalCheck{URL: srv.URL + path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+	if transport.requests != 2 {
+		t.Fatalf("expected both probes through the client transport without following redirects, got %d requests", transport.requests)
+	}
+	if client.CheckRedirect != nil {
+		t.Fatal("expected the client not to be modified")
+	}
+}
@@ -0,0 +1,49 @@
+package fast
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// checkTampering flags the result as tampered if complete downloads of the
+// same size had different checksums, or checksums not in
+// Options.KnownChecksums, if any.
+// fast.com serves the same content for the same size, so differences mean
+// something in the path changed it.
+func checkTampering(result *Result, opts Options) {
+	if !opts.Checksum {
+		return
+	}
+	known := make(map[string]bool, len(opts.KnownChecksums))
+	for _, sum := range opts.KnownChecksums {
+		known[strings.ToLower(sum)] = true
+	}
+	bySize := map[int64]string{}
+	for _, t := range result.Transfers {
+		if t.Checksum == "" {
+			continue
+		}
+		if len(known) > 0 && !known[t.Checksum] {
+			tampered(result, fmt.Sprintf("unknown checksum %s for %d bytes from %s", t.Checksum, t.Bytes, t.URL))
+			return
+		}
+		if sum, ok := bySize[t.Bytes]; ok && sum != t.Checksum {
+			tampered(result, fmt.Sprintf("different checksums for %d bytes: %s and %s", t.Bytes, sum, t.Checksum))
+			return
+		}
+		bySize[t.Bytes] = t.Checksum
+	}
+}
+
+// compressed returns whether the response was compressed, which changes
+// the bytes transferred and so the measured speed.
+func compressed(resp *http.Response) bool {
+	enc := resp.Header.Get("Content-Encoding")
+	return resp.Uncompressed || (enc != "" && enc != "identity")
+}
+
+func tampered(result *Result, reason string) {
+	result.Tampered = true
+	result.Warnings = append(result.Warnings, "test traffic might have been tampered with: "+reason)
+}
@@ -0,0 +1,187 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ContinuousOptions configures a continuous measurement.
+type ContinuousOptions struct {
+	// Interval is the time between samples, defaults to 1m.
+	Interval time.Duration
+	// Window is how long each sample reads at full speed, defaults to 1s.
+	// The first half of it warms the connection up, draining what was
+	// buffered while it trickled, and is not accounted for.
+	Window time.Duration
+	// Smoothing is the weight of each new sample in the rolling estimate,
+	// from 0 to 1, defaults to 0.3.
+	Smoothing float64
+	// Skip is called before each sample if set, skipping it if it returns
+	// true, e.g. while a full measurement runs.
+	Skip func() bool
+}
+
+const (
+	defaultContinuousInterval = time.Minute
+	defaultContinuousWindow   = time.Second
+	defaultSmoothing          = 0.3
+)
+
+func (o ContinuousOptions) withDefaults() ContinuousOptions {
+	if o.Interval <= 0 {
+		o.Interval = defaultContinuousInterval
+	}
+	if o.Window <= 0 {
+		o.Window = defaultContinuousWindow
+	}
+	if o.Smoothing <= 0 || o.Smoothing > 1 {
+		o.Smoothing = defaultSmoothing
+	}
+	return o
+}
+
+// Continuous keeps a single download connection trickling, reading from it
+// at full speed for a short window every interval, and calls fn with the
+// rolling estimate of the achievable download speed in B/s after each
+// sample, until the context is canceled.
+// It uses much less data than full measurements, at the cost of accuracy,
+// giving finer-grained visibility between them.
+func Continuous(ctx context.Context, opts Options, copts ContinuousOptions, fn func(speed float64)) {
+	t := &trickle{
+		opts:  opts.withDefaults(),
+		copts: copts.withDefaults(),
+	}
+	t.buf = make([]byte, t.opts.BufferSize)
+	defer t.close()
+
+	ticker := time.NewTicker(t.copts.Interval)
+	defer ticker.Stop()
+	for {
+		if t.copts.Skip == nil || !t.copts.Skip() {
+			if t.sample(ctx) {
+				fn(t.estimate)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// trickle is the state of a continuous measurement.
+type trickle struct {
+	opts     Options
+	copts    ContinuousOptions
+	body     io.ReadCloser
+	buf      []byte
+	estimate float64
+}
+
+// sample reads a window from the connection, opening it if needed, and
+// updates the estimate, returning whether it did.
+func (t *trickle) sample(ctx context.Context) bool {
+	if t.body == nil {
+		body, err := openTrickle(ctx, t.opts)
+		if err != nil {
+			logger(ctx).Warn().Err(err).Msg("could not open the continuous measurement connection")
+			return false
+		}
+		t.body = body
+	}
+	speed, err := sampleTrickle(t.body, t.buf, t.copts.Window)
+	if err != nil {
+		// reconnect on the next sample
+		t.close()
+	}
+	if speed <= 0 {
+		return false
+	}
+	if t.estimate == 0 {
+		t.estimate = speed
+	} else {
+		t.estimate = t.copts.Smoothing*speed + (1-t.copts.Smoothing)*t.estimate
+	}
+	return true
+}
+
+func (t *trickle) close() {
+	if t.body != nil {
+		t.body.Close()
+		t.body = nil
+	}
+}
+
+// openTrickle starts a download from a test server, returning its body.
+func openTrickle(ctx context.Context, opts Options) (io.ReadCloser, error) {
+	targets, err := Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	pick, err := newPicker(ctx, opts, targets.Servers)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pick.next(), nil)
+	if err != nil {
+		return nil, err
+	}
+	opts.setHeaders(req)
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// stallTimeout is how long a sample waits for data before giving up on the
+// connection.
+const stallTimeout = 10 * time.Second
+
+// sampleTrickle reads body at full speed for window, returning the speed in
+// its second half.
+// It returns io.EOF once the body is over, and closes it if it stalls.
+func sampleTrickle(body io.ReadCloser, buf []byte, window time.Duration) (float64, error) {
+	stalled := time.AfterFunc(window+stallTimeout, func() { body.Close() })
+	defer stalled.Stop()
+	start := time.Now()
+	warmUp := start.Add(window / 2)
+	end := start.Add(window)
+	var measuredFrom time.Time
+	var n int64
+	for {
+		read, err := body.Read(buf)
+		now := time.Now()
+		if now.After(warmUp) {
+			if measuredFrom.IsZero() {
+				measuredFrom = now
+			} else {
+				n += int64(read)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return speedSince(measuredFrom, n), io.EOF
+			}
+			return 0, err
+		}
+		if now.After(end) {
+			return speedSince(measuredFrom, n), nil
+		}
+	}
+}
+
+func speedSince(from time.Time, n int64) float64 {
+	if from.IsZero() {
+		return 0
+	}
+	d := time.Since(from)
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
@@ -0,0 +1,54 @@
+package fast
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// cpuSaturation is the CPU usage above which we consider the measurement to
+// be limited by the CPU.
+const cpuSaturation = 0.9
+
+// cpuSample is a point in time sample of the CPU times.
+type cpuSample struct {
+	time    time.Time
+	process time.Duration // CPU time used by this process
+	busy    time.Duration // CPU time used by the whole system
+	total   time.Duration // CPU time available to the whole system
+	ok      bool
+}
+
+// cpuUsage returns the process and system CPU usage between two samples, as
+// fractions of the available CPU.
+func cpuUsage(start, end cpuSample) (process, system float64, ok bool) {
+	if !start.ok || !end.ok {
+		return 0, 0, false
+	}
+	wall := end.time.Sub(start.time)
+	if wall <= 0 {
+		return 0, 0, false
+	}
+	process = float64(end.process-start.process) / float64(wall) / float64(runtime.NumCPU())
+	if total := end.total - start.total; total > 0 {
+		system = float64(end.busy-start.busy) / float64(total)
+	}
+	return process, system, true
+}
+
+// checkCPU flags the result if the CPU was saturated between the given
+// samples.
+func checkCPU(result *Result, start, end cpuSample) {
+	process, system, ok := cpuUsage(start, end)
+	if !ok {
+		return
+	}
+	if process < cpuSaturation && system < cpuSaturation {
+		return
+	}
+	result.CPULimited = true
+	result.Warnings = append(result.Warnings, fmt.Sprintf(
+		"CPU was saturated during the measurement (process: %.0f%%, system: %.0f%%), the result might be limited by this device",
+		process*100, system*100,
+	))
+}
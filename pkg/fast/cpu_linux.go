@@ -0,0 +1,57 @@
+package fast
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// userHZ is the kernel clock tick used in /proc/stat.
+const userHZ = 100
+
+func sampleCPU() cpuSample {
+	sample := cpuSample{time: time.Now()}
+
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return sample
+	}
+	sample.process = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return sample
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return sample
+	}
+	// cpu user nice system idle iowait irq softirq steal guest guest_nice
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return sample
+	}
+	var total, idle int64
+	for i, field := range fields[1:] {
+		ticks, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return sample
+		}
+		if i >= 8 { // guest times are already accounted in user and nice
+			break
+		}
+		total += ticks
+		if i == 3 || i == 4 { // idle and iowait
+			idle += ticks
+		}
+	}
+	sample.total = time.Duration(total) * time.Second / userHZ
+	sample.busy = time.Duration(total-idle) * time.Second / userHZ
+	sample.ok = true
+	return sample
+}
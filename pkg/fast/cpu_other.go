@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package fast
+
+import "time"
+
+// sampleCPU is not supported outside of Linux, so CPU saturation is never
+// detected.
+func sampleCPU() cpuSample {
+	return cpuSample{time: time.Now()}
+}
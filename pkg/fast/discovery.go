@@ -0,0 +1,121 @@
+package fast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DiscoveryCache persists the last discovered token and targets to a file,
+// so a restarted process measures right away, and measurements go on while
+// fast.com discovery fails, as long as the targets did not expire.
+type DiscoveryCache struct {
+	mutex sync.Mutex
+	path  string
+	state discoveryState
+	// restored is set while the targets loaded from the file were not used
+	// yet.
+	restored bool
+}
+
+type discoveryState struct {
+	Token   string   `json:"token"`
+	Targets *Targets `json:"targets,omitempty"`
+}
+
+// NewDiscoveryCache creates a cache persisted to path, loading the existing
+// state from it, if any.
+func NewDiscoveryCache(path string) (*DiscoveryCache, error) {
+	c := &DiscoveryCache{path: path}
+	bts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load discovery cache: %w", err)
+	}
+	if err := json.Unmarshal(bts, &c.state); err != nil {
+		return nil, fmt.Errorf("could not load discovery cache: %w", err)
+	}
+	c.restored = c.state.Targets != nil
+	return c, nil
+}
+
+// restoredTargets returns the targets loaded from the file the first time it
+// is called, if they did not expire.
+func (c *DiscoveryCache) restoredTargets() *Targets {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.restored {
+		return nil
+	}
+	c.restored = false
+	return c.unexpired()
+}
+
+// targets returns the last discovered targets, if they did not expire.
+func (c *DiscoveryCache) targets() *Targets {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.unexpired()
+}
+
+func (c *DiscoveryCache) unexpired() *Targets {
+	t := c.state.Targets
+	if t == nil || t.Expired() {
+		return nil
+	}
+	return &Targets{
+		Servers: append([]Server(nil), t.Servers...),
+		Expires: t.Expires,
+		Client:  t.Client,
+	}
+}
+
+// token returns the last token found.
+func (c *DiscoveryCache) token() string {
+	if c == nil {
+		return ""
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state.Token
+}
+
+// save persists the token, if not empty, and the targets.
+func (c *DiscoveryCache) save(ctx context.Context, token string, targets *Targets) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if token != "" {
+		c.state.Token = token
+	}
+	c.state.Targets = targets
+	c.restored = false
+	if err := c.write(); err != nil {
+		logger(ctx).Warn().Err(err).Msg("could not persist discovery cache")
+	}
+}
+
+func (c *DiscoveryCache) write() error {
+	bts, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, bts, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
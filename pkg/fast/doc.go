@@ -0,0 +1,5 @@
+// Package fast measures download and upload speeds against fast.com.
+//
+// The exported API follows semantic versioning along with the exporter: it
+// only changes in backwards-incompatible ways on major releases.
+package fast
@@ -0,0 +1,77 @@
+package fast
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DuplexResult is the result of a full-duplex measurement.
+type DuplexResult struct {
+	Download *Result `json:"download"`
+	Upload   *Result `json:"upload"`
+	// LoadedLatency is the median latency while both directions were
+	// saturated, zero if it could not be measured.
+	LoadedLatency time.Duration `json:"loaded_latency"`
+}
+
+// MeasureDuplex measures the download and upload speeds at the same time,
+// along with the latency under load, revealing bufferbloat that sequential
+// measurements miss.
+func MeasureDuplex(ctx context.Context, opts Options, upload UploadOptions) (*DuplexResult, error) {
+	targets, err := Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return targets.MeasureDuplex(ctx, opts, upload)
+}
+
+// MeasureDuplex measures the download and upload speeds against the targets
+// at the same time, along with the latency under load, once no other
+// measurement is running.
+func (t *Targets) MeasureDuplex(ctx context.Context, opts Options, upload UploadOptions) (*DuplexResult, error) {
+	r, err := exclusive(ctx, opts, "duplex", t.Servers, func(ctx context.Context) (interface{}, error) {
+		return t.measureDuplex(ctx, opts, upload)
+	}, upload)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*DuplexResult), nil
+}
+
+func (t *Targets) measureDuplex(ctx context.Context, opts Options, upload UploadOptions) (*DuplexResult, error) {
+	opts = opts.withDefaults()
+	servers := t.Servers
+	uploadOpts := opts
+	uploadOpts.Traceroute = false // only once is enough
+
+	var result DuplexResult
+	var g errgroup.Group
+	latencyCtx, stopLatency := context.WithTimeout(ctx, opts.MaxDuration)
+	defer stopLatency()
+	latency := make(chan time.Duration, 1)
+	if len(servers) > 0 {
+		go func() { latency <- sampleLatency(latencyCtx, opts, servers[0].URL) }()
+	} else {
+		latency <- 0
+	}
+
+	g.Go(func() error {
+		r, err := t.withClient(measure(ctx, Download, servers, opts, downloadFunc))
+		result.Download = r
+		return err
+	})
+	g.Go(func() error {
+		r, err := t.withClient(measure(ctx, Upload, servers, uploadOpts, uploadFuncs(upload)))
+		result.Upload = r
+		return err
+	})
+	err := g.Wait()
+	stopLatency()
+	result.LoadedLatency = <-latency
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
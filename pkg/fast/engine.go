@@ -0,0 +1,42 @@
+package fast
+
+// Engine is a set of measurement defaults, so changes to how measurements
+// run can be compared against the previous behavior before becoming the
+// default.
+type Engine string
+
+const (
+	// Legacy measures the average speed of the whole measurement, always
+	// over all connections.
+	Legacy Engine = "legacy"
+	// V2 probes the speed to size the connections and requests, as in
+	// Options.Adaptive, and computes the speed after it stabilizes, as in
+	// StableWindowEstimator.
+	V2 Engine = "v2"
+)
+
+// Engines are all the available engines.
+// nolint: gochecknoglobals
+var Engines = []Engine{Legacy, V2}
+
+// WithEngine returns the options measuring with the given engine, replacing
+// their Adaptive and Estimator.
+func (o Options) WithEngine(e Engine) Options {
+	switch e {
+	case V2:
+		o.Adaptive = true
+		o.Estimator = StableWindowEstimator{}
+	default:
+		o.Adaptive = false
+		o.Estimator = AverageEstimator{}
+	}
+	return o
+}
+
+// Other returns the engine compared with e.
+func (e Engine) Other() Engine {
+	if e == V2 {
+		return Legacy
+	}
+	return V2
+}
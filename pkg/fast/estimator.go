@@ -0,0 +1,232 @@
+package fast
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Sample is the total amount of bytes transferred at some point of a
+// measurement.
+type Sample struct {
+	// Elapsed is the time since the measurement started.
+	Elapsed time.Duration `json:"elapsed"`
+	// Bytes is the amount of bytes transferred until then.
+	Bytes int64 `json:"bytes"`
+	// Speed is the speed since the previous sample, in B/s, only set in the
+	// samples of a Result and of progress hooks.
+	Speed float64 `json:"bytes_second,omitempty"`
+}
+
+// SpeedEstimator computes the speed of a measurement, in B/s, out of samples
+// of the bytes transferred over time.
+// Samples are ordered, the first one is taken when the measurement starts
+// and the last one when it ends, with all the bytes transferred.
+type SpeedEstimator interface {
+	Estimate(samples []Sample) float64
+}
+
+// AverageEstimator computes the average speed of the whole measurement,
+// the default.
+type AverageEstimator struct{}
+
+// Estimate implements SpeedEstimator.
+func (AverageEstimator) Estimate(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	return speedBetween(Sample{}, samples[len(samples)-1])
+}
+
+const (
+	defaultWindow     = 2 * time.Second
+	defaultTolerance  = 0.05
+	defaultPercentile = 90
+)
+
+// StableWindowEstimator ignores the ramp-up of a measurement, computing the
+// average speed after it stabilizes, like fast.com: the measurement is stable
+// once the speed in a window differs from the speed in the previous one by
+// less than Tolerance.
+// If it never stabilizes, the average speed of the whole measurement is used.
+type StableWindowEstimator struct {
+	// Window is the duration of each window, defaults to 2s.
+	Window time.Duration
+	// Tolerance is the maximum relative difference between the speeds of
+	// consecutive windows, defaults to 5%.
+	Tolerance float64
+}
+
+// Estimate implements SpeedEstimator.
+func (e StableWindowEstimator) Estimate(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	if e.Window <= 0 {
+		e.Window = defaultWindow
+	}
+	if e.Tolerance <= 0 {
+		e.Tolerance = defaultTolerance
+	}
+	last := samples[len(samples)-1]
+	windows := sampleEvery(samples, e.Window)
+	for i := 2; i < len(windows); i++ {
+		prev := speedBetween(windows[i-2], windows[i-1])
+		speed := speedBetween(windows[i-1], windows[i])
+		if prev > 0 && math.Abs(speed-prev)/prev < e.Tolerance {
+			return speedBetween(windows[i-1], last)
+		}
+	}
+	return speedBetween(Sample{}, last)
+}
+
+// PercentileEstimator computes the speed in each interval of a measurement,
+// using the given percentile of them, smoothing out both the ramp-up and
+// short peaks.
+type PercentileEstimator struct {
+	// Percentile used, from 0 to 100, defaults to 90.
+	Percentile float64
+	// Interval is the duration of each interval, defaults to the sampling
+	// interval.
+	Interval time.Duration
+}
+
+// Estimate implements SpeedEstimator.
+func (e PercentileEstimator) Estimate(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	if e.Percentile <= 0 || e.Percentile > 100 {
+		e.Percentile = defaultPercentile
+	}
+	intervals := sampleEvery(samples, e.Interval)
+	if len(intervals) < 2 {
+		return speedBetween(Sample{}, samples[len(samples)-1])
+	}
+	speeds := make([]float64, 0, len(intervals)-1)
+	for i := 1; i < len(intervals); i++ {
+		speeds = append(speeds, speedBetween(intervals[i-1], intervals[i]))
+	}
+	sort.Float64s(speeds)
+	idx := int(math.Ceil(e.Percentile/100*float64(len(speeds)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return speeds[idx]
+}
+
+// sampleEvery returns the samples at least d apart, always keeping the first
+// and the last ones.
+func sampleEvery(samples []Sample, d time.Duration) []Sample {
+	if len(samples) < 2 {
+		return append([]Sample(nil), samples...)
+	}
+	result := []Sample{samples[0]}
+	for _, s := range samples[1 : len(samples)-1] {
+		if s.Elapsed-result[len(result)-1].Elapsed >= d {
+			result = append(result, s)
+		}
+	}
+	return append(result, samples[len(samples)-1])
+}
+
+// resample returns the samples at least d apart, with their speed since the
+// previous one.
+func resample(samples []Sample, d time.Duration) []Sample {
+	if len(samples) == 0 {
+		return nil
+	}
+	result := sampleEvery(samples, d)
+	for i := 1; i < len(result); i++ {
+		result[i].Speed = speedBetween(result[i-1], result[i])
+	}
+	return result
+}
+
+// limitSamples resamples the samples down to at most max, keeping the first
+// and the last ones, if max is not zero.
+func limitSamples(samples []Sample, max int) []Sample {
+	if max < 2 || len(samples) <= max {
+		return samples
+	}
+	span := samples[len(samples)-1].Elapsed - samples[0].Elapsed
+	return resample(samples, span/time.Duration(max-1))
+}
+
+func speedBetween(from, to Sample) float64 {
+	d := to.Elapsed - from.Elapsed
+	if d <= 0 {
+		return 0
+	}
+	return float64(to.Bytes-from.Bytes) / d.Seconds()
+}
+
+const (
+	sampleInterval = 100 * time.Millisecond
+	// resultSampleInterval is the interval of the samples in a result.
+	resultSampleInterval = 250 * time.Millisecond
+)
+
+// sampler periodically records the bytes transferred in a measurement.
+type sampler struct {
+	start   time.Time
+	counter *byteCounter
+	samples []Sample
+	// progress is called with each sample, if set.
+	progress func(Sample)
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// startSampler records the bytes in counter every sampleInterval until
+// stopped.
+func startSampler(start time.Time, counter *byteCounter, progress func(Sample)) *sampler {
+	s := &sampler{
+		start:    start,
+		counter:  counter,
+		samples:  []Sample{{}},
+		progress: progress,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *sampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			s.add()
+		}
+	}
+}
+
+func (s *sampler) add() {
+	sample := Sample{
+		Elapsed: time.Since(s.start),
+		Bytes:   s.counter.load(),
+	}
+	if s.progress != nil {
+		p := sample
+		p.Speed = speedBetween(s.samples[len(s.samples)-1], sample)
+		s.progress(p)
+	}
+	s.samples = append(s.samples, sample)
+}
+
+// stop records the last sample and returns all of them, if s is not nil.
+func (s *sampler) stop() []Sample {
+	if s == nil {
+		return nil
+	}
+	close(s.quit)
+	<-s.done
+	s.add()
+	return s.samples
+}
@@ -0,0 +1,40 @@
+package fast_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+func TestEstimators(t *testing.T) {
+	estimators := map[string]fast.SpeedEstimator{
+		"average":       fast.AverageEstimator{},
+		"stable-window": fast.StableWindowEstimator{},
+		"percentile":    fast.PercentileEstimator{},
+	}
+	for _, tt := range []struct {
+		name    string
+		samples []fast.Sample
+		want    float64
+	}{
+		{name: "no samples"},
+		{name: "one sample at the start", samples: []fast.Sample{{Bytes: 10}}},
+		{name: "one sample", samples: []fast.Sample{{Elapsed: time.Second, Bytes: 10}}, want: 10},
+		{name: "steady", samples: []fast.Sample{
+			{},
+			{Elapsed: time.Second, Bytes: 100},
+			{Elapsed: 2 * time.Second, Bytes: 200},
+			{Elapsed: 3 * time.Second, Bytes: 300},
+			{Elapsed: 4 * time.Second, Bytes: 400},
+		}, want: 100},
+	} {
+		for name, estimator := range estimators {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				if got := estimator.Estimate(tt.samples); got != tt.want {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// GatewayResult is the latency to the default gateway, telling LAN issues
+// apart from WAN congestion.
+type GatewayResult struct {
+	// Gateway is the address of the default gateway.
+	Gateway string `json:"gateway"`
+	// Latency is the median latency of the probes.
+	Latency time.Duration `json:"latency"`
+	// Jitter is the mean difference between the latencies of consecutive
+	// probes.
+	Jitter time.Duration `json:"jitter"`
+	// Loss is the ratio of failed probes.
+	Loss float64 `json:"loss"`
+}
+
+const gatewayProbeTimeout = time.Second
+
+// gatewayPorts are tried in order until the gateway answers on one, DNS
+// and the admin UI being the usual ones open on routers.
+// nolint: gochecknoglobals
+var gatewayPorts = []string{"53", "80", "443"}
+
+// errGatewayUnsupported happens when the current platform cannot find the
+// default gateway.
+var errGatewayUnsupported = errors.New("finding the default gateway is not supported on this platform")
+
+// MeasureGateway measures the latency, jitter and probe loss to the default
+// gateway, timing TCP connections to it, which does not need any special
+// privileges: a refused connection takes a round trip too.
+// Both the route table and the connections are the ones of the network
+// namespace of the process.
+func MeasureGateway(ctx context.Context, latency LatencyOptions) (*GatewayResult, error) {
+	if latency.Probes <= 0 {
+		latency.Probes = defaultLatencyProbes
+	}
+	if latency.Interval <= 0 {
+		latency.Interval = defaultLatencyInterval
+	}
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	var port string
+	var firstErr error
+	var samples []time.Duration
+	var jitter time.Duration
+	for i := 0; i < latency.Probes; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(latency.Interval):
+			}
+		}
+		ports := gatewayPorts
+		if port != "" {
+			ports = []string{port}
+		}
+		var sample time.Duration
+		for _, p := range ports {
+			if sample, err = pingGateway(ctx, net.JoinHostPort(gateway.String(), p)); err == nil {
+				port = p
+				break
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if n := len(samples); n > 0 {
+			jitter += absDuration(sample - samples[n-1])
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("could not reach gateway %s: %w", gateway, firstErr)
+	}
+
+	result := &GatewayResult{
+		Gateway: gateway.String(),
+		Loss:    float64(latency.Probes-len(samples)) / float64(latency.Probes),
+	}
+	if len(samples) > 1 {
+		result.Jitter = jitter / time.Duration(len(samples)-1)
+	}
+	result.Latency = median(samples)
+	return result, nil
+}
+
+// pingGateway returns how long it took the gateway to accept or refuse a
+// connection to addr.
+func pingGateway(ctx context.Context, addr string) (time.Duration, error) {
+	dialer := net.Dialer{Timeout: gatewayProbeTimeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	rtt := time.Since(start)
+	if err == nil {
+		_ = conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,62 @@
+package fast
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// nativeEndian is the byte order of the host, the one /proc/net/route
+// writes addresses in.
+// nolint: gochecknoglobals
+var nativeEndian = hostByteOrder()
+
+func hostByteOrder() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// defaultGateway returns the gateway of the IPv4 default route.
+func defaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDefaultGateway(f, nativeEndian)
+}
+
+// parseDefaultGateway returns the gateway of the IPv4 default route in a
+// /proc/net/route table written in the given byte order.
+func parseDefaultGateway(r io.Reader, order binary.ByteOrder) (net.IP, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" || len(fields[2]) != 8 {
+			continue
+		}
+		gateway, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		ip := make(net.IP, net.IPv4len)
+		order.PutUint32(ip, uint32(gateway))
+		if !ip.IsUnspecified() {
+			return ip, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default gateway found")
+}
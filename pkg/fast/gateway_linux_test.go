@@ -0,0 +1,42 @@
+package fast
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestParseDefaultGateway(t *testing.T) {
+	for _, tt := range []struct {
+		file  string
+		order binary.ByteOrder
+		want  string
+	}{
+		// amd64 and arm
+		{file: "testdata/route_le", order: binary.LittleEndian, want: "192.168.1.1"},
+		// mips routers
+		{file: "testdata/route_be", order: binary.BigEndian, want: "192.168.1.1"},
+		{file: "testdata/route_no_default", order: binary.LittleEndian},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			ip, err := parseDefaultGateway(f, tt.order)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected no default gateway, got %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fast
+
+import "net"
+
+func defaultGateway() (net.IP, error) {
+	return nil, errGatewayUnsupported
+}
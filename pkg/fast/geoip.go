@@ -0,0 +1,72 @@
+package fast
+
+import (
+	"net"
+	"net/url"
+)
+
+// Locator resolves the location and autonomous system of IP addresses, e.g.
+// out of a MaxMind GeoLite2 database.
+type Locator interface {
+	// Locate returns what is known about ip, false if nothing is.
+	Locate(ip net.IP) (Location, bool)
+}
+
+// Location of an IP address as resolved by a Locator, fields unknown to it
+// are left empty.
+type Location struct {
+	// Country is the ISO 3166-1 code of the country.
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	// ASN and Organization are the autonomous system of the address.
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// locate returns the location of the given IP address, nil if it is unknown.
+func locate(locator Locator, addr string) *Location {
+	ip := net.ParseIP(addr)
+	if locator == nil || ip == nil {
+		return nil
+	}
+	location, ok := locator.Locate(ip)
+	if !ok {
+		return nil
+	}
+	return &location
+}
+
+// locateServers returns a copy of the servers with the address requests to
+// each were sent to and its location, as seen in the transfers.
+func locateServers(locator Locator, servers []Server, transfers []Transfer) []Server {
+	ips := map[string]string{}
+	for _, t := range transfers {
+		host, _, err := net.SplitHostPort(t.RemoteAddr)
+		if err != nil {
+			continue
+		}
+		if u, err := url.Parse(t.URL); err == nil {
+			ips[u.Hostname()] = host
+		}
+	}
+	result := make([]Server, 0, len(servers))
+	for _, server := range servers {
+		if ip, ok := ips[server.Host]; ok {
+			server.IP = ip
+			server.GeoIP = locate(locator, ip)
+		}
+		result = append(result, server)
+	}
+	return result
+}
+
+// locateClient returns a copy of the client with its location, if not
+// already located.
+func locateClient(locator Locator, client *Client) *Client {
+	if client == nil || client.GeoIP != nil {
+		return client
+	}
+	located := *client
+	located.GeoIP = locate(locator, client.IP)
+	return &located
+}
@@ -0,0 +1,125 @@
+package fast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// engine allows a single measurement at a time in the process, as concurrent
+// ones would saturate the same link and measure each other instead.
+// nolint: gochecknoglobals
+var (
+	engine = make(chan struct{}, 1)
+
+	sharedMutex sync.Mutex
+	sharedRuns  = map[string]*sharedRun{}
+)
+
+// sharedRun is a measurement whose result is shared by all the callers
+// waiting for it.
+type sharedRun struct {
+	done    chan struct{}
+	val     interface{}
+	err     error
+	callers int
+	cancel  context.CancelFunc
+}
+
+// exclusive runs fn once no other measurement is running, queueing until
+// then or until the context is canceled.
+// With Options.Share, callers of the same kind of measurement, against the
+// same servers and with the same options, queued or running at the same time
+// get the result of a single run instead, which is only canceled once all of
+// them are.
+func exclusive(ctx context.Context, opts Options, kind string, servers []Server, fn func(ctx context.Context) (interface{}, error), extra ...interface{}) (interface{}, error) {
+	run := func(ctx context.Context) (interface{}, error) {
+		select {
+		case engine <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-engine }()
+		return fn(ctx)
+	}
+	if !opts.Share {
+		return run(ctx)
+	}
+
+	key := shareKey(kind, servers, opts, extra...)
+	sharedMutex.Lock()
+	r, ok := sharedRuns[key]
+	if !ok {
+		runCtx, cancel := context.WithCancel(detach(ctx))
+		r = &sharedRun{done: make(chan struct{}), cancel: cancel}
+		sharedRuns[key] = r
+		go func() {
+			r.val, r.err = run(runCtx)
+			sharedMutex.Lock()
+			if sharedRuns[key] == r {
+				delete(sharedRuns, key)
+			}
+			sharedMutex.Unlock()
+			cancel()
+			close(r.done)
+		}()
+	}
+	r.callers++
+	sharedMutex.Unlock()
+
+	select {
+	case <-r.done:
+		return r.val, r.err
+	case <-ctx.Done():
+		sharedMutex.Lock()
+		r.callers--
+		if r.callers == 0 {
+			// nobody is waiting for it anymore, later callers start anew
+			r.cancel()
+			if sharedRuns[key] == r {
+				delete(sharedRuns, key)
+			}
+		}
+		sharedMutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// shareKey identifies the measurements that can share a result: the same
+// kind, against the same servers, regardless of the tokens in their URLs,
+// with the same options.
+func shareKey(kind string, servers []Server, opts Options, extra ...interface{}) string {
+	h := xxhash.New()
+	for _, s := range servers {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			_, _ = fmt.Fprintln(h, s.URL)
+			continue
+		}
+		_, _ = fmt.Fprintln(h, u.Scheme, u.Host, u.Path)
+	}
+	_, _ = fmt.Fprintf(h, "%#v\n", opts)
+	for _, e := range extra {
+		_, _ = fmt.Fprintf(h, "%#v\n", e)
+	}
+	return fmt.Sprintf("%s/%016x", kind, h.Sum64())
+}
+
+// detached keeps the values of a context, e.g. its logger, but not its
+// deadline nor cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detached{parent: ctx}
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
@@ -0,0 +1,131 @@
+package fast
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blocked returns a measurement function counting its runs, which returns
+// once release is closed, or fails once its context is canceled.
+func blocked(runs *int32, release <-chan struct{}) func(context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(runs, 1)
+		select {
+		case <-release:
+			return atomic.LoadInt32(runs), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type call struct {
+	val interface{}
+	err error
+}
+
+func start(ctx context.Context, opts Options, servers []Server, fn func(context.Context) (interface{}, error)) <-chan call {
+	ch := make(chan call, 1)
+	go func() {
+		val, err := exclusive(ctx, opts, "download", servers, fn)
+		ch <- call{val, err}
+	}()
+	return ch
+}
+
+// waitRuns waits until fn ran n times.
+func waitRuns(t *testing.T, runs *int32, n int32) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(runs) < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d runs, got %d", n, atomic.LoadInt32(runs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExclusiveShare(t *testing.T) {
+	opts := Options{Share: true}
+	servers := []Server{{URL: "https://a.example/speedtest?t=1"}}
+
+	t.Run("same servers and options", func(t *testing.T) {
+		var runs int32
+		release := make(chan struct{})
+		fn := blocked(&runs, release)
+		first := start(context.Background(), opts, servers, fn)
+		waitRuns(t, &runs, 1)
+		// the same servers with another token
+		second := start(context.Background(), opts, []Server{{URL: "https://a.example/speedtest?t=2"}}, fn)
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		a, b := <-first, <-second
+		if a.err != nil || b.err != nil || a.val != int32(1) || b.val != int32(1) || runs != 1 {
+			t.Fatalf("expected a single shared run, got %+v, %+v after %d runs", a, b, runs)
+		}
+	})
+
+	t.Run("other servers", func(t *testing.T) {
+		var runs int32
+		release := make(chan struct{})
+		fn := blocked(&runs, release)
+		first := start(context.Background(), opts, servers, fn)
+		waitRuns(t, &runs, 1)
+		second := start(context.Background(), opts, []Server{{URL: "https://b.example/speedtest"}}, fn)
+		close(release)
+		if a, b := <-first, <-second; a.err != nil || b.err != nil || runs != 2 {
+			t.Fatalf("expected two runs, got %+v, %+v after %d runs", a, b, runs)
+		}
+	})
+
+	t.Run("other options", func(t *testing.T) {
+		var runs int32
+		release := make(chan struct{})
+		fn := blocked(&runs, release)
+		first := start(context.Background(), opts, servers, fn)
+		waitRuns(t, &runs, 1)
+		second := start(context.Background(), Options{Share: true, Connections: 2}, servers, fn)
+		close(release)
+		if a, b := <-first, <-second; a.err != nil || b.err != nil || runs != 2 {
+			t.Fatalf("expected two runs, got %+v, %+v after %d runs", a, b, runs)
+		}
+	})
+
+	t.Run("first caller canceled", func(t *testing.T) {
+		var runs int32
+		release := make(chan struct{})
+		fn := blocked(&runs, release)
+		ctx, cancel := context.WithCancel(context.Background())
+		first := start(ctx, opts, servers, fn)
+		waitRuns(t, &runs, 1)
+		second := start(context.Background(), opts, servers, fn)
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		if a := <-first; a.err != context.Canceled {
+			t.Fatalf("expected the canceled caller to fail, got %+v", a)
+		}
+		close(release)
+		if b := <-second; b.err != nil || runs != 1 {
+			t.Fatalf("expected the other caller to get the result, got %+v after %d runs", b, runs)
+		}
+	})
+
+	t.Run("all callers canceled", func(t *testing.T) {
+		var runs int32
+		fn := blocked(&runs, make(chan struct{}))
+		ctx, cancel := context.WithCancel(context.Background())
+		first := start(ctx, opts, servers, fn)
+		waitRuns(t, &runs, 1)
+		cancel()
+		if a := <-first; a.err != context.Canceled {
+			t.Fatalf("expected the canceled caller to fail, got %+v", a)
+		}
+		// the run is canceled, freeing the engine for the next one
+		release := make(chan struct{})
+		close(release)
+		if b := <-start(context.Background(), opts, servers, blocked(&runs, release)); b.err != nil || runs != 2 {
+			t.Fatalf("expected a new run, got %+v after %d runs", b, runs)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package fast
+
+import "time"
+
+// Direction is the direction of a measurement.
+type Direction string
+
+const (
+	// Download measurements read from the test servers.
+	Download Direction = "download"
+	// Upload measurements write to the test servers.
+	Upload Direction = "upload"
+)
+
+// Hooks are called on the lifecycle events of each measurement, e.g. to
+// stream its progress or trace it, if set.
+// They are called synchronously, and from several goroutines in duplex
+// measurements, so they must be quick and safe for concurrent use.
+type Hooks struct {
+	// OnStart is called when a measurement starts.
+	OnStart func(Start)
+	// OnProgress is called with each throughput sample while a measurement
+	// runs, 10 times a second.
+	OnProgress func(Progress)
+	// OnComplete is called when a measurement ends, successfully or not.
+	OnComplete func(Complete)
+}
+
+// Start is the start of a measurement.
+type Start struct {
+	Direction Direction
+	Time      time.Time
+	Servers   []Server
+}
+
+// Progress is a throughput sample of a running measurement, with its speed
+// since the previous one.
+type Progress struct {
+	Direction Direction
+	Sample    Sample
+}
+
+// Complete is the end of a measurement, with either its result or error.
+type Complete struct {
+	Direction Direction
+	Result    *Result
+	Err       error
+}
+
+func (h Hooks) start(direction Direction, servers []Server) {
+	if h.OnStart != nil {
+		h.OnStart(Start{Direction: direction, Time: time.Now(), Servers: servers})
+	}
+}
+
+// progress returns the function called with each sample, nil without an
+// OnProgress hook.
+func (h Hooks) progress(direction Direction) func(Sample) {
+	if h.OnProgress == nil {
+		return nil
+	}
+	return func(s Sample) {
+		h.OnProgress(Progress{Direction: direction, Sample: s})
+	}
+}
+
+func (h Hooks) complete(direction Direction, result *Result, err error) {
+	if h.OnComplete != nil {
+		h.OnComplete(Complete{Direction: direction, Result: result, Err: err})
+	}
+}
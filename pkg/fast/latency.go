@@ -0,0 +1,179 @@
+package fast
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"time"
+)
+
+const latencyInterval = 500 * time.Millisecond
+
+// latencyURL returns the URL of a 1 byte range of the given test URL, which
+// is what fast.com uses to measure latency.
+func latencyURL(rawURL string) (string, error) {
+	return rangeURL(rawURL, 1)
+}
+
+// pingOnce measures the time between sending a request on an established
+// connection and receiving the first response byte, so connection setup is
+// not accounted for.
+func pingOnce(ctx context.Context, opts Options, rawURL string) (time.Duration, error) {
+	var gotConn, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { gotConn = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	opts.setHeaders(req)
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return firstByte.Sub(gotConn), nil
+}
+
+// sampleLatency pings the given URL every latencyInterval until the context
+// is done, returning the median latency, or zero if no ping succeeded.
+func sampleLatency(ctx context.Context, opts Options, rawURL string) time.Duration {
+	target, err := latencyURL(rawURL)
+	if err != nil {
+		return 0
+	}
+
+	var samples []time.Duration
+	ticker := time.NewTicker(latencyInterval)
+	defer ticker.Stop()
+	for {
+		if latency, err := pingOnce(ctx, opts, target); err == nil {
+			samples = append(samples, latency)
+		}
+		select {
+		case <-ctx.Done():
+			return median(samples)
+		case <-ticker.C:
+		}
+	}
+}
+
+func median(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2]
+}
+
+// LatencyOptions configures idle latency measurements.
+type LatencyOptions struct {
+	// Probes is the number of latency probes, defaults to 10.
+	Probes int
+	// Interval is the time between probes, defaults to 100ms.
+	Interval time.Duration
+}
+
+// LatencyResult is the result of an idle latency measurement.
+type LatencyResult struct {
+	// Latency is the median latency of the probes.
+	Latency time.Duration `json:"latency"`
+	// Jitter is the mean difference between the latencies of consecutive
+	// probes.
+	Jitter time.Duration `json:"jitter"`
+	// Loss is the ratio of failed probes.
+	Loss float64 `json:"loss"`
+	// Server is the server probed.
+	Server Server `json:"server"`
+}
+
+const (
+	defaultLatencyProbes   = 10
+	defaultLatencyInterval = 100 * time.Millisecond
+)
+
+// MeasureLatency discovers test servers and measures the idle latency,
+// jitter and probe loss to one of them, chosen according to
+// Options.Strategy, transferring only a few bytes.
+func MeasureLatency(ctx context.Context, opts Options, latency LatencyOptions) (*LatencyResult, error) {
+	targets, err := Discover(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return targets.MeasureLatency(ctx, opts, latency)
+}
+
+// MeasureLatency measures the idle latency, jitter and probe loss to one of
+// the targets, chosen according to Options.Strategy.
+func (t *Targets) MeasureLatency(ctx context.Context, opts Options, latency LatencyOptions) (*LatencyResult, error) {
+	opts = opts.withDefaults()
+	if latency.Probes <= 0 {
+		latency.Probes = defaultLatencyProbes
+	}
+	if latency.Interval <= 0 {
+		latency.Interval = defaultLatencyInterval
+	}
+	pick, err := newPicker(ctx, opts, t.Servers)
+	if err != nil {
+		return nil, err
+	}
+	server := Server{URL: pick.next()}
+	for _, s := range pick.servers {
+		if s.URL == server.URL {
+			server = s
+		}
+	}
+	target, err := latencyURL(server.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []time.Duration
+	var jitter time.Duration
+	var firstErr error
+	for i := 0; i < latency.Probes; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(latency.Interval):
+			}
+		}
+		sample, err := pingOnce(ctx, opts, target)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if n := len(samples); n > 0 {
+			jitter += absDuration(sample - samples[n-1])
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return nil, firstErr
+	}
+
+	result := &LatencyResult{
+		Loss:   float64(latency.Probes-len(samples)) / float64(latency.Probes),
+		Server: server,
+	}
+	if len(samples) > 1 {
+		result.Jitter = jitter / time.Duration(len(samples)-1)
+	}
+	result.Latency = median(samples)
+	return result, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
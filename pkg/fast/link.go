@@ -0,0 +1,47 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrLinkDown happens when the link check fails, in which case measuring
+// would only time out and report zero speeds.
+var ErrLinkDown = errors.New("link is down")
+
+// LinkCheck configures the connectivity check.
+type LinkCheck struct {
+	// URL is sent a HEAD request, any response meaning the link is up,
+	// e.g. https://fast.com.
+	URL string
+	// Timeout of the request, defaults to 3s.
+	Timeout time.Duration
+}
+
+const defaultLinkTimeout = 3 * time.Second
+
+// CheckLink sends a HEAD request to the check URL with the given client,
+// or http.DefaultClient if nil, returning ErrLinkDown if it got no response.
+func CheckLink(ctx context.Context, client *http.Client, check LinkCheck) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultLinkTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, check.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLinkDown, err)
+	}
+	resp.Body.Close()
+	return nil
+}
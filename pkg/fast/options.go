@@ -0,0 +1,163 @@
+package fast
+
+import (
+	"net/http"
+	"time"
+)
+
+// Options configures a measurement.
+// The zero value uses the same settings as fast.com.
+type Options struct {
+	// Client is the HTTP client used for discovery and measurement requests,
+	// defaults to http.DefaultClient.
+	Client *http.Client
+	// Connections is the maximum number of concurrent requests.
+	Connections int
+	// Strategy defines how test URLs are chosen, defaults to RoundRobin.
+	Strategy Strategy
+	// Seed makes the sequence of URLs chosen by the Random strategy
+	// reproducible, e.g. to compare benchmarks, if not zero.
+	Seed int64
+	// MaxDuration is the maximum duration of a measurement, defaults to 30s
+	// like fast.com.
+	MaxDuration time.Duration
+	// MaxBytes stops the measurement once this many bytes were transferred,
+	// even if MaxDuration was not reached yet.
+	// Zero means only the duration is limited.
+	MaxBytes int64
+	// Estimator computes the speed out of the bytes transferred over time,
+	// defaults to the average speed of the whole measurement.
+	Estimator SpeedEstimator
+	// MaxSamples caps the samples kept in the Result, taking them further
+	// apart than every 250ms in longer measurements, so the memory results
+	// use is bounded. It must be zero, keeping them all, or at least 2.
+	MaxSamples int
+	// BufferSize is the size of the buffer used to read each response.
+	BufferSize int
+	// UserAgent is the User-Agent header sent in measurement requests.
+	UserAgent string
+	// Headers are extra headers sent in measurement requests.
+	Headers http.Header
+	// CaptureHeaders are the response headers recorded in each Transfer,
+	// e.g. X-Cache or Via, revealing middleboxes or transparent caches.
+	CaptureHeaders []string
+	// Checksum hashes downloads, flagging results whose downloads of the same
+	// size differ, or are not in KnownChecksums if set, and failing
+	// compressed downloads, detecting tampering with the test traffic.
+	Checksum bool
+	// KnownChecksums are the expected xxhash checksums of downloads, in hex.
+	KnownChecksums []string
+	// Traceroute probes the path to the first test server connected to
+	// after measuring, from the network namespace of the process, whatever
+	// namespace Client dials from.
+	Traceroute bool
+	// TCPInfo reads the kernel TCP information of the measurement
+	// connections, only supported on Linux.
+	// It requires Client to use an *http.Transport.
+	TCPInfo bool
+	// Thermal reads the SoC temperature before and after measuring from
+	// sysfs, flagging results taken while the CPU was thermally throttling,
+	// only supported on Linux.
+	Thermal bool
+	// WiFi reads the SSID, signal and rate of the wireless link of the
+	// default route interface when measuring, only supported on Linux, where
+	// the SSID and rate need iw, and macOS.
+	WiFi bool
+	// Peering aggregates the bytes transferred by the network of the test
+	// servers, a /24 for IPv4 or /48 for IPv6, or by their autonomous system
+	// if ASN resolves it.
+	Peering bool
+	// ASN resolves the autonomous system of the test servers for Peering,
+	// if set.
+	ASN ASNResolver
+	// GeoIP locates the client and the test servers in results, if set.
+	GeoIP Locator
+	// Share gives callers measuring at the same time, e.g. on double scrapes,
+	// the result of a single measurement instead of queueing them one after
+	// the other, which is what happens by default, as long as they measure
+	// against the same servers with the same options.
+	// Shared results must not be modified, and the measurement is only
+	// canceled once the contexts of all the callers sharing it are.
+	Share bool
+	// DiscoveryCache persists the discovered token and targets, if set.
+	DiscoveryCache *DiscoveryCache
+	// Hooks are called on the lifecycle events of each measurement.
+	Hooks Hooks
+	// Adaptive probes the download speed with a single connection for a
+	// second, then picks the connections and request sizes of its Tier, like
+	// fast.com, instead of always using all connections.
+	// Connections still limits them.
+	Adaptive bool
+	// ReplaceSlowest aborts the slowest request every second if it is less
+	// than half as fast as the average of the others, starting a new one to
+	// a different URL instead, so an underperforming server does not keep
+	// the link from saturating.
+	ReplaceSlowest bool
+}
+
+// UploadOptions configures upload measurements.
+type UploadOptions struct {
+	// Size is the maximum amount of bytes uploaded in a measurement.
+	// Zero means only the measurement time is limited.
+	Size int64
+	// ChunkSize is the amount of bytes sent in each request.
+	ChunkSize int64
+	// Random uploads pseudo random bytes instead of zeros.
+	Random bool
+}
+
+const (
+	burstDuration    = 3 * time.Second
+	burstConnections = 2
+)
+
+// Burst returns the options for a quick check, with a short duration and
+// few connections, trading accuracy for less data and time, e.g. on metered
+// links.
+func (o Options) Burst() Options {
+	if o.MaxDuration <= 0 || o.MaxDuration > burstDuration {
+		o.MaxDuration = burstDuration
+	}
+	if o.Connections <= 0 || o.Connections > burstConnections {
+		o.Connections = burstConnections
+	}
+	return o
+}
+
+const (
+	maxConcurrentRequests = 8                // from fast.com
+	maxTime               = time.Second * 30 // from fast.com
+	defaultChunkSize      = 25 * 1024 * 1024 // from fast.com
+	defaultBufferSize     = 32 * 1024
+)
+
+func (o Options) setHeaders(req *http.Request) {
+	for name, values := range o.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("User-Agent", o.UserAgent)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.Connections <= 0 {
+		o.Connections = maxConcurrentRequests
+	}
+	if o.MaxDuration <= 0 {
+		o.MaxDuration = maxTime
+	}
+	if o.Strategy == "" {
+		o.Strategy = RoundRobin
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultBufferSize
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = userAgent
+	}
+	return o
+}
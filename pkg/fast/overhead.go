@@ -0,0 +1,50 @@
+package fast
+
+// Encapsulation is the link layer of the connection, used to estimate the
+// line rate out of the measured speed, which is the application-layer
+// goodput, to compare it with the provisioned or sync rate.
+type Encapsulation string
+
+const (
+	// Ethernet is plain Ethernet, with its preamble and inter-frame gap.
+	Ethernet Encapsulation = "ethernet"
+	// PPPoE is PPP over Ethernet, common on DSL and fiber.
+	PPPoE Encapsulation = "pppoe"
+	// DOCSIS is cable, whose rates are provisioned on the Ethernet frames.
+	DOCSIS Encapsulation = "docsis"
+)
+
+// Encapsulations are all the available encapsulations.
+// nolint: gochecknoglobals
+var Encapsulations = []Encapsulation{Ethernet, PPPoE, DOCSIS}
+
+// tcpOverhead is the size of the IPv4 and TCP headers, with timestamps.
+const tcpOverhead = 20 + 20 + 12
+
+// framing is the MTU and the per-packet overhead on the line.
+type framing struct {
+	mtu      int
+	overhead int
+}
+
+// nolint: gochecknoglobals
+var framings = map[Encapsulation]framing{
+	// header, FCS, preamble and inter-frame gap
+	Ethernet: {mtu: 1500, overhead: 14 + 4 + 8 + 12},
+	// PPPoE and PPP headers inside the Ethernet frame
+	PPPoE: {mtu: 1492, overhead: 8 + 14 + 4 + 8 + 12},
+	// header and FCS only
+	DOCSIS: {mtu: 1500, overhead: 14 + 4},
+}
+
+// LineRate estimates the line rate in B/s of a measured speed, assuming full
+// size packets, adding the overhead of the TCP, IP and link layer headers.
+// It returns speed itself for unknown encapsulations.
+func (e Encapsulation) LineRate(speed float64) float64 {
+	f, ok := framings[e]
+	if !ok {
+		return speed
+	}
+	payload := f.mtu - tcpOverhead
+	return speed * float64(f.mtu+f.overhead) / float64(payload)
+}
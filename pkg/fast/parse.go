@@ -0,0 +1,151 @@
+package fast
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// scriptURLs returns the URLs of the scripts in the HTML page, resolved
+// against base, the fast.com app bundles (app-*.js) first.
+// It scans the script tags instead of matching the whole page, tolerating
+// attribute order, quoting and case changes in the markup.
+func scriptURLs(page []byte, base *url.URL) []string {
+	lower := lowerASCII(page)
+	var result []string
+	seen := map[string]bool{}
+	for i := 0; ; {
+		start := bytes.Index(lower[i:], []byte("<script"))
+		if start < 0 {
+			break
+		}
+		start += i
+		end := bytes.IndexByte(lower[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+		i = end
+		src := attribute(page[start+len("<script"):end], "src")
+		if src == "" {
+			continue
+		}
+		u, err := base.Parse(src)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		result = append(result, u.String())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return isAppBundle(result[i]) && !isAppBundle(result[j])
+	})
+	return result
+}
+
+func isAppBundle(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	name := path.Base(u.Path)
+	return strings.HasPrefix(name, "app-") && strings.HasSuffix(name, ".js")
+}
+
+// attribute returns the value of the named attribute in the attributes of a
+// tag, quoted with ' or " or unquoted.
+func attribute(attrs []byte, name string) string {
+	s := string(attrs)
+	lower := string(lowerASCII(attrs))
+	for i := 0; ; {
+		idx := strings.Index(lower[i:], name)
+		if idx < 0 {
+			return ""
+		}
+		idx += i
+		i = idx + len(name)
+		// it must be a whole attribute name
+		if idx > 0 && !isSpace(lower[idx-1]) {
+			continue
+		}
+		rest := strings.TrimLeft(s[i:], " \t\r\n")
+		if !strings.HasPrefix(rest, "=") {
+			continue
+		}
+		rest = strings.TrimLeft(rest[1:], " \t\r\n")
+		if rest == "" {
+			return ""
+		}
+		if q := rest[0]; q == '"' || q == '\'' {
+			if end := strings.IndexByte(rest[1:], q); end >= 0 {
+				return rest[1 : end+1]
+			}
+			return ""
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return r < 0x80 && isSpace(byte(r))
+		})
+		if end < 0 {
+			return rest
+		}
+		return rest[:end]
+	}
+}
+
+// lowerASCII returns a copy of b with its ASCII letters lowercased, unlike
+// bytes.ToLower keeping its length, so indexes in it match the ones in b.
+func lowerASCII(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// findToken returns the API token in the script, set as token:"...", with
+// any quoting, spacing, or an = instead of the colon, empty if not found.
+func findToken(script []byte) string {
+	s := string(script)
+	for i := 0; ; {
+		idx := strings.Index(s[i:], "token")
+		if idx < 0 {
+			return ""
+		}
+		idx += i
+		i = idx + len("token")
+		rest := strings.TrimLeft(s[i:], `"' `)
+		if rest == "" || (rest[0] != ':' && rest[0] != '=') {
+			continue
+		}
+		rest = strings.TrimLeft(rest[1:], " \t\r\n")
+		if rest == "" || (rest[0] != '"' && rest[0] != '\'' && rest[0] != '`') {
+			continue
+		}
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end <= 0 {
+			continue
+		}
+		if token := rest[1 : end+1]; isToken(token) {
+			return token
+		}
+	}
+}
+
+// isToken returns whether s looks like an API token, alphanumeric.
+func isToken(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
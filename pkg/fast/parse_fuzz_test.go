@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package fast
+
+import (
+	"net/url"
+	"testing"
+)
+
+func FuzzScriptURLs(f *testing.F) {
+	f.Add(readFixture(f, "fastcom.html"))
+	f.Add(readFixture(f, "fastcom_variant.html"))
+	f.Add([]byte(`<script src="`))
+	base, _ := url.Parse(baseURL)
+	f.Fuzz(func(t *testing.T, page []byte) {
+		for _, u := range scriptURLs(page, base) {
+			if _, err := url.Parse(u); err != nil {
+				t.Fatalf("invalid url %q: %v", u, err)
+			}
+		}
+	})
+}
+
+func FuzzFindToken(f *testing.F) {
+	f.Add(readFixture(f, "app.js"))
+	f.Add(readFixture(f, "app_spaced.js"))
+	f.Add(readFixture(f, "app_without_token.js"))
+	f.Add([]byte(`token:"`))
+	f.Fuzz(func(t *testing.T, script []byte) {
+		if token := findToken(script); !isToken(token) {
+			t.Fatalf("invalid token %q", token)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package fast
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// the fixtures in testdata follow the markup of the fast.com page and the
+// layout of its app bundle, along with variants the scanners must tolerate.
+
+func readFixture(tb testing.TB, name string) []byte {
+	tb.Helper()
+	bts, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return bts
+}
+
+func TestScriptURLs(t *testing.T) {
+	base, _ := url.Parse(baseURL)
+	for _, tt := range []struct {
+		fixture string
+		want    []string
+	}{
+		{
+			fixture: "fastcom.html",
+			want: []string{
+				"https://fast.com/app-ed402d.js",
+				"https://fast.com/polyfills-3b1b1e.js",
+			},
+		},
+		{
+			fixture: "fastcom_variant.html",
+			want: []string{
+				"https://assets.fast.com/app-9c1f2a.js",
+				"https://fast.com/app-ed402d.js",
+				"https://fast.com/vendor-a1b2c3.js",
+			},
+		},
+	} {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got := scriptURLs(readFixture(t, tt.fixture), base)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFindToken(t *testing.T) {
+	for fixture, want := range map[string]string{
+		"app.js":               "YXNkZmFzZGxmbnNkYWZoYXNkZmhrYWxm",
+		"app_spaced.js":        "YXNkZmFzZGxmbnNkYWZoYXNkZmhrYWxm",
+		"app_without_token.js": "",
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			if got := findToken(readFixture(t, fixture)); got != want {
+				t.Fatalf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	for attrs, want := range map[string]string{
+		` src="/a.js"`:            "/a.js",
+		` SRC='/a.js' defer`:      "/a.js",
+		` async src=/a.js`:        "/a.js",
+		` src = "/a.js"`:          "/a.js",
+		` data-src="/b.js"`:       "",
+		` data-src="/b.js" src=a`: "a",
+		` src="/a.js`:             "",
+		` src=`:                   "",
+		` src`:                    "",
+	} {
+		if got := attribute([]byte(attrs), "src"); got != want {
+			t.Errorf("%q: expected %q, got %q", attrs, want, got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package fast
+
+import (
+	"io"
+	"math/rand"
+)
+
+// Payload is an upload payload of a fixed size, generated on the fly so it
+// never needs to be held in memory.
+type Payload struct {
+	remaining int64
+	random    *rand.Rand
+}
+
+// NewPayload returns a payload of the given size.
+// If random is true, it produces pseudo random bytes instead of zeros, which
+// prevents compression along the way from inflating the results.
+func NewPayload(size int64, random bool) *Payload {
+	p := &Payload{remaining: size}
+	if random {
+		p.random = rand.New(rand.NewSource(size)) // nolint: gosec
+	}
+	return p
+}
+
+// Read implements io.Reader.
+func (p *Payload) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	if p.random != nil {
+		_, _ = p.random.Read(b)
+	} else {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+	p.remaining -= int64(len(b))
+	return len(b), nil
+}
+
+// Len returns the number of bytes left to read.
+func (p *Payload) Len() int64 {
+	return p.remaining
+}
@@ -0,0 +1,92 @@
+package fast
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// ASNResolver resolves the autonomous system of IP addresses, e.g. out of a
+// GeoIP database.
+type ASNResolver interface {
+	// LookupASN returns the number and organization of the autonomous
+	// system of ip, false if it is not known.
+	LookupASN(ip net.IP) (asn uint, org string, ok bool)
+}
+
+// Peering is the traffic of a measurement carried by a network of test
+// servers, showing which CDN nodes served it, e.g. when the ISP routes some
+// of them badly.
+type Peering struct {
+	// Network is the /24 of IPv4 servers or the /48 of IPv6 ones, or their
+	// autonomous system, e.g. AS2906, if Options.ASN resolved it.
+	Network string `json:"network"`
+	// ASN and Organization are the autonomous system of the network, only
+	// set if Options.ASN resolved it.
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	// IPs are the addresses of the servers in the network requests were
+	// sent to.
+	IPs      []string `json:"ips"`
+	Bytes    int64    `json:"bytes"`
+	Requests int64    `json:"requests"`
+}
+
+// peerings aggregates the transfers by the network of their remote
+// addresses, the one carrying the most bytes first.
+// Transfers that could not connect are left out.
+func peerings(transfers []Transfer, resolver ASNResolver) []Peering {
+	index := map[string]int{}
+	var result []Peering
+	for _, t := range transfers {
+		host, _, err := net.SplitHostPort(t.RemoteAddr)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		peering := Peering{Network: networkOf(ip)}
+		if resolver != nil {
+			if asn, org, ok := resolver.LookupASN(ip); ok {
+				peering.Network = fmt.Sprintf("AS%d", asn)
+				peering.ASN = asn
+				peering.Organization = org
+			}
+		}
+		i, ok := index[peering.Network]
+		if !ok {
+			i = len(result)
+			index[peering.Network] = i
+			result = append(result, peering)
+		}
+		p := &result[i]
+		p.Bytes += t.Bytes
+		p.Requests++
+		if !containsString(p.IPs, ip.String()) {
+			p.IPs = append(p.IPs, ip.String())
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Bytes > result[j].Bytes
+	})
+	return result
+}
+
+// networkOf returns the /24 of IPv4 addresses, or the /48 of IPv6 ones.
+func networkOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
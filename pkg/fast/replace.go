@@ -0,0 +1,156 @@
+package fast
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// replaceInterval is how often the in-flight requests are compared.
+	replaceInterval = time.Second
+	// replaceMinAge is how long a request runs before it can be compared,
+	// skipping its ramp-up.
+	replaceMinAge = 2 * time.Second
+	// replaceRatio is how much slower than the average of the others the
+	// slowest request has to be to be replaced.
+	replaceRatio = 0.5
+)
+
+// replacer aborts the slowest in-flight request when it is much slower than
+// the others, so its connection is replaced by one to a different URL.
+type replacer struct {
+	mutex    sync.Mutex
+	inflight map[*inflight]struct{}
+	// avoid is the URL of the last request replaced, skipped by the next one.
+	avoid    string
+	replaced int64
+}
+
+// inflight is a request of the measurement currently running.
+type inflight struct {
+	url      string
+	start    time.Time
+	counter  *byteCounter
+	cancel   context.CancelFunc
+	replaced int32
+}
+
+// startReplacer compares the in-flight requests every replaceInterval until
+// ctx is done.
+func startReplacer(ctx context.Context) *replacer {
+	r := &replacer{inflight: map[*inflight]struct{}{}}
+	go func() {
+		ticker := time.NewTicker(replaceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.check(ctx, time.Now())
+			}
+		}
+	}()
+	return r
+}
+
+// next returns the URL of the next request out of pick, other than the one
+// of the request just replaced, if there are others.
+func (r *replacer) next(pick *picker) string {
+	u := pick.next()
+	if r == nil {
+		return u
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.avoid == "" {
+		return u
+	}
+	for i := 1; u == r.avoid && i < len(pick.servers); i++ {
+		u = pick.next()
+	}
+	r.avoid = ""
+	return u
+}
+
+// start tracks a request to url, returning the context it has to use and
+// the request to pass to done once it ends.
+func (r *replacer) start(ctx context.Context, url string, counter *byteCounter) (context.Context, *inflight) {
+	if r == nil {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	req := &inflight{
+		url:     url,
+		start:   time.Now(),
+		counter: counter,
+		cancel:  cancel,
+	}
+	r.mutex.Lock()
+	r.inflight[req] = struct{}{}
+	r.mutex.Unlock()
+	return ctx, req
+}
+
+// done stops tracking req, returning whether it was replaced.
+func (r *replacer) done(req *inflight) bool {
+	if r == nil {
+		return false
+	}
+	r.mutex.Lock()
+	delete(r.inflight, req)
+	r.mutex.Unlock()
+	req.cancel()
+	return atomic.LoadInt32(&req.replaced) == 1
+}
+
+// check aborts the slowest request, if it is much slower than the average
+// of the others.
+func (r *replacer) check(ctx context.Context, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var slowest *inflight
+	var slowestSpeed, total float64
+	var n int
+	for req := range r.inflight {
+		age := now.Sub(req.start)
+		if age < replaceMinAge || atomic.LoadInt32(&req.replaced) == 1 {
+			continue
+		}
+		speed := float64(req.counter.load()) / age.Seconds()
+		total += speed
+		n++
+		if slowest == nil || speed < slowestSpeed {
+			slowest, slowestSpeed = req, speed
+		}
+	}
+	if n < 2 {
+		return
+	}
+	others := (total - slowestSpeed) / float64(n-1)
+	if slowestSpeed >= others*replaceRatio {
+		return
+	}
+	atomic.StoreInt32(&slowest.replaced, 1)
+	r.avoid = slowest.url
+	r.replaced++
+	slowest.cancel()
+	logger(ctx).Debug().
+		Str("url", slowest.url).
+		Float64("speed", slowestSpeed).
+		Float64("others", others).
+		Msg("replacing slowest request")
+}
+
+// count returns how many requests were replaced.
+func (r *replacer) count() int64 {
+	if r == nil {
+		return 0
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.replaced
+}
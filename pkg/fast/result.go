@@ -0,0 +1,77 @@
+package fast
+
+import (
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+)
+
+// Result is the result of a measurement.
+type Result struct {
+	// Speed is the measured speed in B/s.
+	Speed float64 `json:"bytes_second"`
+	// Bytes is the amount of bytes transferred.
+	Bytes int64 `json:"bytes"`
+	// Start and End are the wall clock times the measurement started and
+	// ended, for reference only: Duration is measured with the monotonic
+	// clock, so it is not affected by clock adjustments during the
+	// measurement.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Duration is how long the measurement took.
+	Duration time.Duration `json:"duration"`
+	// CPULimited is true if the CPU was likely saturated during the
+	// measurement, meaning the speed might be limited by the device running
+	// it and not by the connection.
+	CPULimited bool `json:"cpu_limited"`
+	// Requests is the number of requests made, and Failed how many of them
+	// failed.
+	Requests int64 `json:"requests"`
+	Failed   int64 `json:"failed_requests"`
+	// Replaced is how many requests were aborted for being much slower than
+	// the others, only with Options.ReplaceSlowest.
+	Replaced int64 `json:"replaced_requests,omitempty"`
+	// Samples are the bytes transferred over time, about every 250ms unless
+	// capped by Options.MaxSamples, with the speed in each interval, showing
+	// the ramp-up and stability of the measurement.
+	Samples []Sample `json:"samples,omitempty"`
+	// Transfers are the details of each request.
+	Transfers []Transfer `json:"transfers,omitempty"`
+	// Incomplete is true if some requests failed, in which case the result
+	// only accounts for the bytes transferred before they did and by the
+	// other requests.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Errors are the distinct errors of the failed requests.
+	Errors []string `json:"errors,omitempty"`
+	// Servers that could have been used in the measurement, depending on
+	// Options.Strategy.
+	Servers []Server `json:"servers,omitempty"`
+	// Client is the client as seen by fast.com, if it said.
+	Client *Client `json:"client,omitempty"`
+	// Tampered is true if the downloaded content was changed in the path,
+	// only checked if Options.Checksum is set.
+	Tampered bool `json:"tampered,omitempty"`
+	// Path to the first test server, only set if Options.Traceroute is set.
+	Path *Path `json:"path,omitempty"`
+	// TCP stats of the measurement connections, only set if Options.TCPInfo
+	// is set.
+	TCP *TCPStats `json:"tcp,omitempty"`
+	// Thermal state of the device, only set if Options.Thermal is set and
+	// the temperature could be read.
+	Thermal *Thermal `json:"thermal,omitempty"`
+	// WiFi link measured over, only set if Options.WiFi is set and the
+	// default route is wireless.
+	WiFi *WiFi `json:"wifi,omitempty"`
+	// Peering is the traffic carried by each network of test servers, only
+	// set if Options.Peering is set.
+	Peering []Peering `json:"peering,omitempty"`
+	// Tier picked for the measurement, only set if Options.Adaptive is set.
+	Tier *Tier `json:"tier,omitempty"`
+	// Warnings about the measurement.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// BytesPerSecond returns Speed, for conversions to other units.
+func (r *Result) BytesPerSecond() units.BytesPerSecond {
+	return units.BytesPerSecond(r.Speed)
+}
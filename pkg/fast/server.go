@@ -0,0 +1,118 @@
+package fast
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Server is a test server returned by fast.com.
+type Server struct {
+	URL     string `json:"url"`
+	Host    string `json:"host"`
+	City    string `json:"city,omitempty"`
+	Country string `json:"country,omitempty"`
+	// IP is the address requests to the server were sent to, and GeoIP its
+	// location, only set in results if Options.GeoIP is set.
+	IP    string    `json:"ip,omitempty"`
+	GeoIP *Location `json:"geoip,omitempty"`
+}
+
+// Client is the client as seen by fast.com.
+type Client struct {
+	// IP is the public IP address of the client.
+	IP      string `json:"ip,omitempty"`
+	City    string `json:"city,omitempty"`
+	Country string `json:"country,omitempty"`
+	ISP     string `json:"isp,omitempty"`
+	// GeoIP is the location of IP, only set if Options.GeoIP is set and
+	// knows it.
+	GeoIP *Location `json:"geoip,omitempty"`
+}
+
+type apiResponse struct {
+	Client struct {
+		IP       string `json:"ip"`
+		ISP      string `json:"isp"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"location"`
+	} `json:"client"`
+	Targets []struct {
+		URL      string `json:"url"`
+		Location struct {
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"location"`
+	} `json:"targets"`
+}
+
+// findServers returns the test servers, along with the client if fast.com
+// returned it.
+func findServers(ctx context.Context, client *http.Client, token string) ([]Server, *Client) {
+	log := logger(ctx)
+	url := fmt.Sprintf("https://api.fast.com/netflix/speedtest/v2?https=true&token=%s&urlCount=5", token)
+	log.Debug().Msgf("getting url list from %s", url)
+
+	jsonData, err := getPage(ctx, client, url)
+	if err != nil {
+		log.Error().Err(err).Msgf("error getting fast page %s", url)
+	}
+
+	var resp apiResponse
+	if err := json.Unmarshal(jsonData, &resp); err != nil {
+		log.Warn().Err(err).Msg("could not parse url list, looking for urls only")
+		return findURLs(ctx, jsonData), nil
+	}
+
+	var servers []Server
+	for _, target := range resp.Targets {
+		if target.URL == "" {
+			continue
+		}
+		servers = append(servers, Server{
+			URL:     target.URL,
+			Host:    hostOf(target.URL),
+			City:    target.Location.City,
+			Country: target.Location.Country,
+		})
+		log.Debug().
+			Str("url", target.URL).
+			Str("city", target.Location.City).
+			Str("country", target.Location.Country).
+			Msg("got url")
+	}
+	if resp.Client.IP == "" {
+		return servers, nil
+	}
+	return servers, &Client{
+		IP:      resp.Client.IP,
+		City:    resp.Client.Location.City,
+		Country: resp.Client.Location.Country,
+		ISP:     resp.Client.ISP,
+	}
+}
+
+func findURLs(ctx context.Context, jsonData []byte) []Server {
+	log := logger(ctx)
+	var servers []Server
+	for _, url := range urlRE.FindAllStringSubmatch(string(jsonData), -1) {
+		if len(url) == 0 {
+			continue
+		}
+		servers = append(servers, Server{URL: url[1], Host: hostOf(url[1])})
+		log.Debug().Str("url", url[1]).Msg("got url")
+	}
+	return servers
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
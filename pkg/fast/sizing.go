@@ -0,0 +1,120 @@
+package fast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+	"golang.org/x/sync/semaphore"
+)
+
+// Tier is the number of connections and the size of each request used in
+// adaptive measurements, up to some speed.
+type Tier struct {
+	// MaxSpeed is the highest speed of the tier in B/s, zero for the last one.
+	MaxSpeed    float64 `json:"max_bytes_second,omitempty"`
+	Connections int     `json:"connections"`
+	ChunkSize   int64   `json:"chunk_size"`
+}
+
+// Tiers are the tiers of adaptive measurements, by the speed probed in their
+// first second, with a single connection.
+// nolint: gochecknoglobals
+var Tiers = []Tier{
+	{MaxSpeed: float64(units.FromMbps(10)), Connections: 2, ChunkSize: 2 << 20},
+	{MaxSpeed: float64(units.FromMbps(100)), Connections: 4, ChunkSize: 10 << 20},
+	{MaxSpeed: float64(units.FromMbps(500)), Connections: 8, ChunkSize: defaultChunkSize},
+	{Connections: 16, ChunkSize: defaultChunkSize},
+}
+
+const (
+	// probeDuration is how long adaptive measurements probe the speed.
+	probeDuration = time.Second
+	// probeChunkSize is the size of the requests while probing.
+	probeChunkSize = 1 << 20
+)
+
+func tierFor(speed float64) Tier {
+	for _, t := range Tiers {
+		if t.MaxSpeed == 0 || speed < t.MaxSpeed {
+			return t
+		}
+	}
+	return Tiers[len(Tiers)-1]
+}
+
+// sizer probes the speed with a single connection, then picks the tier of
+// the measurement, opening up to its connections, limited by
+// Options.Connections.
+type sizer struct {
+	chunk int64
+	mutex sync.Mutex
+	tier  *Tier
+}
+
+// startSizer holds all but one connection of sem until the probe is done.
+func startSizer(ctx context.Context, opts Options, sem *semaphore.Weighted, counter *byteCounter, start time.Time) (*sizer, error) {
+	held := int64(opts.Connections - 1)
+	if held > 0 {
+		if err := sem.Acquire(ctx, held); err != nil {
+			return nil, err
+		}
+	}
+	s := &sizer{chunk: probeChunkSize}
+	go func() {
+		timer := time.NewTimer(probeDuration)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		tier := tierFor(float64(counter.load()) / time.Since(start).Seconds())
+		if tier.Connections > opts.Connections {
+			tier.Connections = opts.Connections
+		}
+		atomic.StoreInt64(&s.chunk, tier.ChunkSize)
+		s.mutex.Lock()
+		s.tier = &tier
+		s.mutex.Unlock()
+		if n := int64(tier.Connections - 1); n > 0 {
+			sem.Release(n)
+		}
+		logger(ctx).Debug().
+			Int("connections", tier.Connections).
+			Int64("chunk_size", tier.ChunkSize).
+			Msg("picked measurement tier")
+	}()
+	return s, nil
+}
+
+// url returns the range of rawURL to request next, of the current size.
+func (s *sizer) url(rawURL string) string {
+	u, err := rangeURL(rawURL, atomic.LoadInt64(&s.chunk))
+	if err != nil {
+		return rawURL
+	}
+	return u
+}
+
+// result returns the tier picked, nil if the measurement ended while probing.
+func (s *sizer) result() *Tier {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.tier
+}
+
+// rangeURL returns the URL of the first size bytes of the given test URL.
+func rangeURL(rawURL string, size int64) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + fmt.Sprintf("/range/0-%d", size-1)
+	return u.String(), nil
+}
@@ -0,0 +1,122 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy defines how test URLs are chosen for each request.
+type Strategy string
+
+const (
+	// RoundRobin uses every URL, in the order fast.com returned them.
+	RoundRobin Strategy = "round-robin"
+	// LowestLatency uses every URL, ordered by latency.
+	LowestLatency Strategy = "lowest-latency"
+	// Nearest only uses the URL with the lowest latency.
+	Nearest Strategy = "nearest"
+	// Random uses a random URL for each request, in a sequence that is
+	// reproducible with Options.Seed.
+	Random Strategy = "random"
+)
+
+// Strategies are all the available strategies.
+// nolint: gochecknoglobals
+var Strategies = []Strategy{RoundRobin, LowestLatency, Nearest, Random}
+
+// errNoURLs happens when fast.com did not return any test URL.
+var errNoURLs = errors.New("no test urls found")
+
+const latencyProbes = 3
+
+// picker chooses the server for each request out of servers.
+type picker struct {
+	servers []Server
+	next    func() string
+}
+
+func newPicker(ctx context.Context, opts Options, servers []Server) (*picker, error) {
+	if len(servers) == 0 {
+		return nil, errNoURLs
+	}
+	switch opts.Strategy {
+	case RoundRobin:
+		return roundRobin(servers), nil
+	case LowestLatency:
+		return roundRobin(byLatency(ctx, opts, servers)), nil
+	case Nearest:
+		return roundRobin(byLatency(ctx, opts, servers)[:1]), nil
+	case Random:
+		var mutex sync.Mutex
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		random := rand.New(rand.NewSource(seed)) // nolint: gosec
+		return &picker{
+			servers: servers,
+			next: func() string {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return servers[random.Intn(len(servers))].URL
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid strategy %q", opts.Strategy)
+	}
+}
+
+func roundRobin(servers []Server) *picker {
+	var idx uint32
+	return &picker{
+		servers: servers,
+		next: func() string {
+			i := atomic.AddUint32(&idx, 1) - 1
+			return servers[int(i)%len(servers)].URL
+		},
+	}
+}
+
+// byLatency returns the servers sorted by their best latency out of a few
+// probes. Servers that could not be probed go last.
+func byLatency(ctx context.Context, opts Options, servers []Server) []Server {
+	log := logger(ctx)
+	latencies := make(map[string]time.Duration, len(servers))
+	for _, server := range servers {
+		target, err := latencyURL(server.URL)
+		if err != nil {
+			continue
+		}
+		var best time.Duration
+		for i := 0; i < latencyProbes; i++ {
+			latency, err := pingOnce(ctx, opts, target)
+			if err != nil {
+				continue
+			}
+			if best == 0 || latency < best {
+				best = latency
+			}
+		}
+		if best > 0 {
+			latencies[server.URL] = best
+			log.Debug().Str("url", server.URL).Dur("latency", best).Msg("probed url latency")
+		}
+	}
+
+	sorted := append([]Server(nil), servers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		li, iok := latencies[sorted[i].URL]
+		lj, jok := latencies[sorted[j].URL]
+		if iok != jok {
+			return iok
+		}
+		return li < lj
+	})
+	return sorted
+}
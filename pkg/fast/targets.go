@@ -0,0 +1,140 @@
+package fast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// defaultTargetsTTL is how long targets are considered valid when their URLs
+// do not say when they expire.
+const defaultTargetsTTL = time.Hour
+
+// Targets are the test servers returned by fast.com, which can be reused by
+// several measurements until they expire.
+type Targets struct {
+	Servers []Server `json:"servers"`
+	// Expires is when the test URLs stop working.
+	Expires time.Time `json:"expires"`
+	// Client is the client as seen by fast.com when discovering, if it said.
+	Client *Client `json:"client,omitempty"`
+}
+
+// Discover asks fast.com for test servers.
+// With a DiscoveryCache, the targets restored from it are used the first
+// time, and the last ones discovered whenever discovery fails.
+func Discover(ctx context.Context, opts Options) (*Targets, error) {
+	opts = opts.withDefaults()
+	targets, err := discover(ctx, opts)
+	if err != nil || opts.GeoIP == nil {
+		return targets, err
+	}
+	located := *targets
+	located.Client = locateClient(opts.GeoIP, targets.Client)
+	return &located, nil
+}
+
+func discover(ctx context.Context, opts Options) (*Targets, error) {
+	if targets := opts.DiscoveryCache.restoredTargets(); targets != nil {
+		logger(ctx).Debug().Msg("using the targets restored from the discovery cache")
+		return targets, nil
+	}
+	token := getToken(ctx, opts.Client)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("could not discover: %w", err)
+	}
+	if token == "" {
+		token = opts.DiscoveryCache.token()
+	}
+	servers, client := findServers(ctx, opts.Client, token)
+	if len(servers) == 0 {
+		if targets := opts.DiscoveryCache.targets(); targets != nil {
+			logger(ctx).Warn().Msg("discovery failed, using the cached targets")
+			return targets, nil
+		}
+		return nil, errNoURLs
+	}
+	targets := &Targets{
+		Servers: servers,
+		Expires: expiration(servers, time.Now()),
+		Client:  client,
+	}
+	opts.DiscoveryCache.save(ctx, token, targets)
+	return targets, nil
+}
+
+// TargetsOf returns targets downloading from the given URLs instead of the
+// ones from fast.com, e.g. to compare with other speed test servers.
+// They never expire.
+func TargetsOf(urls ...string) *Targets {
+	servers := make([]Server, 0, len(urls))
+	for _, u := range urls {
+		servers = append(servers, Server{URL: u, Host: hostOf(u)})
+	}
+	return &Targets{Servers: servers}
+}
+
+// Expired returns whether the targets expired, in which case they should be
+// discovered again.
+// Targets without expiration never expire.
+func (t *Targets) Expired() bool {
+	return !t.Expires.IsZero() && !time.Now().Before(t.Expires)
+}
+
+// Measure measures the download speed against the targets, once no other
+// measurement is running.
+func (t *Targets) Measure(ctx context.Context, opts Options) (*Result, error) {
+	r, err := exclusive(ctx, opts, "download", t.Servers, func(ctx context.Context) (interface{}, error) {
+		return t.withClient(measure(ctx, Download, t.Servers, opts.withDefaults(), downloadFunc))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.(*Result), nil
+}
+
+// MeasureUpload measures the upload speed against the targets, once no other
+// measurement is running.
+func (t *Targets) MeasureUpload(ctx context.Context, opts Options, upload UploadOptions) (*Result, error) {
+	r, err := exclusive(ctx, opts, "upload", t.Servers, func(ctx context.Context) (interface{}, error) {
+		return t.withClient(measure(ctx, Upload, t.Servers, opts.withDefaults(), uploadFuncs(upload)))
+	}, upload)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*Result), nil
+}
+
+// withClient sets the client of the targets in the result, if any.
+func (t *Targets) withClient(result *Result, err error) (*Result, error) {
+	if result != nil {
+		result.Client = t.Client
+	}
+	return result, err
+}
+
+// expiration returns the earliest expiration of the test URLs, which
+// fast.com sets in their e query parameter as a unix timestamp, or the
+// default TTL since now if they don't.
+func expiration(servers []Server, now time.Time) time.Time {
+	var earliest time.Time
+	for _, s := range servers {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			continue
+		}
+		e, err := strconv.ParseInt(u.Query().Get("e"), 10, 64)
+		if err != nil || e <= 0 {
+			continue
+		}
+		if t := time.Unix(e, 0); earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	if earliest.IsZero() {
+		return now.Add(defaultTargetsTTL)
+	}
+	return earliest
+}
@@ -0,0 +1,148 @@
+package fast
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TCPStats aggregates the kernel TCP information of the measurement
+// connections.
+type TCPStats struct {
+	// Connections is the number of connections the stats were read from.
+	Connections int `json:"connections"`
+	// RetransmitRate is the ratio of segments this host had to retransmit.
+	// It is only meaningful for uploads, as on downloads the server is the
+	// one sending data.
+	RetransmitRate float64 `json:"retransmit_rate"`
+	// RTT is the average smoothed round trip time of the connections.
+	RTT time.Duration `json:"rtt"`
+}
+
+type tcpInfo struct {
+	retransmits uint32
+	mss         uint32
+	rtt         time.Duration
+}
+
+// tcpTracker records the TCP information of every connection it dials.
+type tcpTracker struct {
+	transport *http.Transport
+
+	mutex       sync.Mutex
+	conns       []*trackedConn
+	count       int
+	retransmits float64
+	segments    float64
+	rtt         time.Duration
+}
+
+// trackTCP returns a copy of the options whose client records the TCP
+// information of its connections, or nil if the client transport cannot be
+// wrapped.
+func trackTCP(opts Options) (Options, *tcpTracker) {
+	var base *http.Transport
+	switch t := opts.Client.Transport.(type) {
+	case nil:
+		base = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		base = t
+	default:
+		return opts, nil
+	}
+
+	tracker := &tcpTracker{transport: base.Clone()}
+	dial := base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	tracker.transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tcp, ok := conn.(*net.TCPConn)
+		if !ok {
+			return conn, nil
+		}
+		tracked := &trackedConn{Conn: conn, tcp: tcp, tracker: tracker}
+		tracker.mutex.Lock()
+		tracker.conns = append(tracker.conns, tracked)
+		tracker.mutex.Unlock()
+		return tracked, nil
+	}
+
+	client := *opts.Client
+	client.Transport = tracker.transport
+	opts.Client = &client
+	return opts, tracker
+}
+
+func (t *tcpTracker) record(info tcpInfo, written int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.count++
+	t.rtt += info.rtt
+	t.retransmits += float64(info.retransmits)
+	if info.mss > 0 {
+		t.segments += math.Max(1, math.Ceil(float64(written)/float64(info.mss)))
+	}
+}
+
+// stats records the connections still open, closes idle ones and returns the
+// aggregated stats.
+func (t *tcpTracker) stats() *TCPStats {
+	t.mutex.Lock()
+	conns := t.conns
+	t.mutex.Unlock()
+	for _, conn := range conns {
+		conn.record()
+	}
+	t.transport.CloseIdleConnections()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.count == 0 {
+		return nil
+	}
+	stats := &TCPStats{
+		Connections: t.count,
+		RTT:         t.rtt / time.Duration(t.count),
+	}
+	if t.segments > 0 {
+		stats.RetransmitRate = math.Min(1, t.retransmits/t.segments)
+	}
+	return stats
+}
+
+type trackedConn struct {
+	net.Conn
+	tcp     *net.TCPConn
+	tracker *tcpTracker
+	written int64
+	once    sync.Once
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.record()
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) record() {
+	c.once.Do(func() {
+		info, ok := readTCPInfo(c.tcp)
+		if ok {
+			c.tracker.record(info, atomic.LoadInt64(&c.written))
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package fast
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func readTCPInfo(conn *net.TCPConn) (tcpInfo, bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return tcpInfo{}, false
+	}
+	var info *unix.TCPInfo
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		info, serr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || serr != nil {
+		return tcpInfo{}, false
+	}
+	return tcpInfo{
+		retransmits: info.Total_retrans,
+		mss:         info.Snd_mss,
+		rtt:         time.Duration(info.Rtt) * time.Microsecond,
+	}, true
+}
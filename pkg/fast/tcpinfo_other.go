@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package fast
+
+import "net"
+
+// readTCPInfo is only supported on Linux.
+func readTCPInfo(*net.TCPConn) (tcpInfo, bool) {
+	return tcpInfo{}, false
+}
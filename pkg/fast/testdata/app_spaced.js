@@ -0,0 +1,5 @@
+const tokenCount = 3;
+const config = {
+  "token" : 'not a token',
+  token = `YXNkZmFzZGxmbnNkYWZoYXNkZmhrYWxm`,
+};
@@ -0,0 +1 @@
+var tokenizer=function(e){return e.split(" ")};var r={apiEndpoint:"api.fast.com/netflix/speedtest/v2",urlCount:5};
@@ -0,0 +1,44 @@
+package fast
+
+import "fmt"
+
+// Thermal is the SoC temperature before and after a measurement.
+type Thermal struct {
+	// Before and After are the temperatures of the hottest thermal zone,
+	// in °C.
+	Before float64 `json:"before_celsius"`
+	After  float64 `json:"after_celsius"`
+	// Throttled is true if the device was thermally throttling the CPU
+	// during the measurement, likely limiting the measured speed.
+	Throttled bool `json:"throttled"`
+}
+
+// thermalSample is a point in time reading of the thermal state.
+type thermalSample struct {
+	celsius float64
+	// throttling is whether the CPU is being throttled right now.
+	throttling bool
+	// throttles is how many times the CPU was throttled since boot, where the
+	// kernel counts them.
+	throttles int64
+	ok        bool
+}
+
+// checkThermal sets the thermal state of result between the given samples,
+// flagging it if the CPU was throttled.
+func checkThermal(result *Result, start, end thermalSample) {
+	if !start.ok || !end.ok {
+		return
+	}
+	result.Thermal = &Thermal{
+		Before:    start.celsius,
+		After:     end.celsius,
+		Throttled: start.throttling || end.throttling || end.throttles > start.throttles,
+	}
+	if result.Thermal.Throttled {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"CPU was thermally throttled during the measurement (%.1f°C), the result might be limited by this device",
+			end.celsius,
+		))
+	}
+}
@@ -0,0 +1,84 @@
+package fast
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	thermalZones = "/sys/class/thermal/thermal_zone*"
+	// rpiThrottled is the throttling state reported by the Raspberry Pi
+	// firmware, as in vcgencmd get_throttled.
+	rpiThrottled = "/sys/devices/platform/soc/soc:firmware/get_throttled"
+	// rpiThrottling are the bits of rpiThrottled set while the ARM frequency
+	// is capped, throttled or at the soft temperature limit.
+	rpiThrottling  = 0x2 | 0x4 | 0x8
+	throttleCounts = "/sys/devices/system/cpu/cpu*/thermal_throttle/*_throttle_count"
+)
+
+// sampleThermal reads the temperature of the hottest thermal zone, and
+// whether it is at or over a passive trip point, where the kernel throttles
+// the CPU to cool it down, along with the throttling reported by the
+// Raspberry Pi firmware or counted by x86 CPUs.
+func sampleThermal() thermalSample {
+	var sample thermalSample
+	zones, _ := filepath.Glob(thermalZones)
+	for _, zone := range zones {
+		temp, ok := readSysInt(filepath.Join(zone, "temp"))
+		if !ok {
+			continue
+		}
+		celsius := float64(temp) / 1000
+		if !sample.ok || celsius > sample.celsius {
+			sample.celsius = celsius
+		}
+		sample.ok = true
+		if atPassiveTrip(zone, temp) {
+			sample.throttling = true
+		}
+	}
+	if !sample.ok {
+		return sample
+	}
+	if bts, err := os.ReadFile(rpiThrottled); err == nil {
+		state, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(string(bts)), "0x"), 16, 64)
+		if err == nil && state&rpiThrottling != 0 {
+			sample.throttling = true
+		}
+	}
+	counts, _ := filepath.Glob(throttleCounts)
+	for _, path := range counts {
+		if n, ok := readSysInt(path); ok {
+			sample.throttles += n
+		}
+	}
+	return sample
+}
+
+// atPassiveTrip returns whether temp reached one of the passive trip points
+// of the zone.
+func atPassiveTrip(zone string, temp int64) bool {
+	types, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+	for _, path := range types {
+		bts, err := os.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(bts)) != "passive" {
+			continue
+		}
+		trip, ok := readSysInt(strings.TrimSuffix(path, "_type") + "_temp")
+		if ok && trip > 0 && temp >= trip {
+			return true
+		}
+	}
+	return false
+}
+
+func readSysInt(path string) (int64, bool) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(bts)), 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fast
+
+// sampleThermal is not supported outside of Linux, so the temperature is
+// never read.
+func sampleThermal() thermalSample {
+	return thermalSample{}
+}
@@ -0,0 +1,110 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Path describes the network path to a test server.
+type Path struct {
+	// Host is the test server probed.
+	Host string `json:"host"`
+	// Hops is the number of hops to reach the host.
+	Hops int `json:"hops"`
+	// FirstHopLatency is the round trip time to the first hop, zero if it
+	// could not be measured.
+	FirstHopLatency time.Duration `json:"first_hop_latency"`
+}
+
+const (
+	maxHops         = 30
+	hopProbeTimeout = 2 * time.Second
+	tracerouteTime  = 30 * time.Second
+)
+
+// errTracerouteUnsupported happens when the current platform cannot probe
+// hops.
+var errTracerouteUnsupported = errors.New("traceroute is not supported on this platform")
+
+// Traceroute probes the number of hops to the host of the given URL, doing TCP
+// connections with increasing TTLs, which does not need any special
+// privileges.
+// The host is resolved with net.DefaultResolver and dialed from the network
+// namespace of the process.
+func Traceroute(ctx context.Context, rawURL string) (*Path, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tracerouteTime)
+	defer cancel()
+
+	// resolve only once so all probes go to the same address
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", u.Hostname())
+	}
+	return traceroute(ctx, u.Hostname(), net.JoinHostPort(addrs[0].IP.String(), port))
+}
+
+// traceMeasured probes the path to the address the first connected request
+// of a measurement was sent to, so it is the very server the measurement
+// used, however its client resolved it.
+func traceMeasured(ctx context.Context, transfers []Transfer) (*Path, error) {
+	for _, t := range transfers {
+		if t.RemoteAddr == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(ctx, tracerouteTime)
+		path, err := traceroute(ctx, hostOf(t.URL), t.RemoteAddr)
+		cancel()
+		return path, err
+	}
+	return nil, errors.New("no request connected to a test server")
+}
+
+// traceroute probes the number of hops to addr, the address of host.
+func traceroute(ctx context.Context, host, addr string) (*Path, error) {
+	path := &Path{Host: host}
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		start := time.Now()
+		reached, err := probeHop(ctx, addr, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ttl == 1 && reached != hopTimeout {
+			path.FirstHopLatency = time.Since(start)
+		}
+		if reached == hopDestination {
+			path.Hops = ttl
+			return path, nil
+		}
+	}
+	return nil, fmt.Errorf("could not reach %s in %d hops", host, maxHops)
+}
+
+type hopResult int
+
+const (
+	// hopTimeout means the probe got no answer.
+	hopTimeout hopResult = iota
+	// hopExceeded means a router answered that the TTL was exceeded.
+	hopExceeded
+	// hopDestination means the probe reached the destination.
+	hopDestination
+)
@@ -0,0 +1,51 @@
+package fast
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// probeHop dials addr with the given TTL.
+// When the TTL is exceeded, Linux aborts the connection attempt as soon as the
+// router ICMP message arrives, so failed probes are fast.
+func probeHop(ctx context.Context, addr string, ttl int) (hopResult, error) {
+	dialer := net.Dialer{
+		Timeout: hopProbeTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				if strings.HasSuffix(network, "6") {
+					serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+					return
+				}
+				serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err == nil {
+		_ = conn.Close()
+		return hopDestination, nil
+	}
+	if ctx.Err() != nil {
+		return hopTimeout, ctx.Err()
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return hopDestination, nil
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return hopExceeded, nil
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return hopTimeout, nil
+	}
+	return hopTimeout, err
+}
@@ -0,0 +1,31 @@
+package fast
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestTraceMeasured(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	path, err := traceMeasured(context.Background(), []Transfer{
+		{URL: "https://a.example/speedtest"},
+		{URL: "https://b.example/speedtest", RemoteAddr: ln.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the measured address, not the one b.example resolves to
+	if path.Host != "b.example" || path.Hops != 1 {
+		t.Fatalf("expected b.example to be 1 hop away, got %+v", path)
+	}
+
+	if _, err := traceMeasured(context.Background(), []Transfer{{URL: "https://a.example/speedtest"}}); err == nil {
+		t.Fatal("expected an error without connected requests")
+	}
+}
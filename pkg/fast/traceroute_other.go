@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fast
+
+import "context"
+
+func probeHop(context.Context, string, int) (hopResult, error) {
+	return hopTimeout, errTracerouteUnsupported
+}
@@ -0,0 +1,94 @@
+package fast
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transfer is a single request of a measurement.
+type Transfer struct {
+	URL string `json:"url"`
+	// RemoteAddr is the address of the test server the request was sent to,
+	// empty if it could not connect.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Proto is the HTTP version used.
+	Proto    string        `json:"proto,omitempty"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	// Error is set if the request failed, requests interrupted by the end of
+	// the measurement did not fail.
+	Error string `json:"error,omitempty"`
+	// Complete is true if the whole response or request body was
+	// transferred, before the end of the measurement.
+	Complete bool `json:"complete,omitempty"`
+	// Replaced is true if the request was aborted for being much slower
+	// than the others, only with Options.ReplaceSlowest.
+	Replaced bool `json:"replaced,omitempty"`
+	// Headers are the response headers listed in Options.CaptureHeaders.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Checksum is the xxhash of complete downloads, in hex, only set if
+	// Options.Checksum is set.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type transferKey struct{}
+
+// transfers collects the transfers of a measurement.
+type transfers struct {
+	mutex sync.Mutex
+	list  []Transfer
+}
+
+func (t *transfers) add(transfer Transfer) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.list = append(t.list, transfer)
+}
+
+// traceTransfer returns a context that records the remote address and HTTP
+// version of the request's connection in transfer, and carries it so the
+// response headers can be captured.
+func traceTransfer(ctx context.Context, transfer *Transfer) context.Context {
+	ctx = context.WithValue(ctx, transferKey{}, transfer)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			transfer.RemoteAddr = info.Conn.RemoteAddr().String()
+			transfer.Proto = "HTTP/1.1"
+			if conn, ok := info.Conn.(*tls.Conn); ok && conn.ConnectionState().NegotiatedProtocol == "h2" {
+				transfer.Proto = "HTTP/2.0"
+			}
+		},
+	})
+}
+
+// captureHeaders records the given response headers in the transfer of the
+// context, if any.
+func captureHeaders(ctx context.Context, names []string, header http.Header) {
+	transfer, ok := ctx.Value(transferKey{}).(*Transfer)
+	if !ok || len(names) == 0 {
+		return
+	}
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if transfer.Headers == nil {
+			transfer.Headers = map[string]string{}
+		}
+		transfer.Headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+	}
+}
+
+// setChecksum records the checksum in the transfer of the context, if any.
+func setChecksum(ctx context.Context, sum uint64) {
+	if transfer, ok := ctx.Value(transferKey{}).(*Transfer); ok {
+		transfer.Checksum = fmt.Sprintf("%016x", sum)
+	}
+}
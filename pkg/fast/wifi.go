@@ -0,0 +1,12 @@
+package fast
+
+// WiFi is the wireless link of the interface of the default route when a
+// measurement started.
+type WiFi struct {
+	Interface string `json:"interface"`
+	SSID      string `json:"ssid,omitempty"`
+	// Signal is the received signal strength, in dBm.
+	Signal float64 `json:"signal_dbm,omitempty"`
+	// Rate is the transmit link rate, in B/s.
+	Rate float64 `json:"rate_bytes_second,omitempty"`
+}
@@ -0,0 +1,54 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package fast
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wifiTimeout bounds the commands run to read the wireless link.
+const wifiTimeout = 5 * time.Second
+
+// run runs a command to read the wireless link, returning its output.
+func run(ctx context.Context, name string, args ...string) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, wifiTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// fields calls fn with the key and value of each "key: value" line of out.
+func fields(out string, fn func(key, value string)) {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		fn(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+}
+
+// leadingFloat parses the number at the start of s, e.g. -56 in "-56 dBm".
+func leadingFloat(s string) (float64, bool) {
+	if f := strings.Fields(s); len(f) > 0 {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(f[0], "."), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// mbps converts a link rate in Mbit/s to B/s.
+func mbps(rate float64) float64 {
+	return rate * 1e6 / 8
+}
@@ -0,0 +1,127 @@
+package fast
+
+import (
+	"bufio"
+	"context"
+	"strings"
+)
+
+const airport = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+// sampleWiFi reads the wireless link of the interface of the default route,
+// nil if it is not wireless.
+// It uses airport, falling back to the slower system_profiler on macOS
+// versions without it.
+func sampleWiFi(ctx context.Context) *WiFi {
+	out, ok := run(ctx, "route", "-n", "get", "default")
+	if !ok {
+		return nil
+	}
+	var iface string
+	fields(out, func(key, value string) {
+		if key == "interface" {
+			iface = value
+		}
+	})
+	if iface == "" {
+		return nil
+	}
+	wifi := &WiFi{Interface: iface}
+	if out, ok := run(ctx, airport, "-I"); ok && !strings.Contains(out, "deprecated") {
+		// airport only reports the primary wireless interface
+		if parseAirport(wifi, out) && iface == primaryWiFi(ctx) {
+			return wifi
+		}
+		return nil
+	}
+	if out, ok := run(ctx, "system_profiler", "SPAirPortDataType"); ok && parseSystemProfiler(wifi, out) {
+		return wifi
+	}
+	return nil
+}
+
+// primaryWiFi returns the name of the first wireless interface.
+func primaryWiFi(ctx context.Context) string {
+	out, ok := run(ctx, "networksetup", "-listallhardwareports")
+	if !ok {
+		return ""
+	}
+	var wifi bool
+	var device string
+	fields(out, func(key, value string) {
+		switch {
+		case key == "Hardware Port":
+			wifi = value == "Wi-Fi" || value == "AirPort"
+		case key == "Device" && wifi && device == "":
+			device = value
+		}
+	})
+	return device
+}
+
+// parseAirport fills wifi with the output of airport -I, returning false if
+// it is not associated to a network.
+func parseAirport(wifi *WiFi, out string) bool {
+	fields(out, func(key, value string) {
+		switch key {
+		case "SSID":
+			wifi.SSID = value
+		case "agrCtlRSSI":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Signal = n
+			}
+		case "lastTxRate":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Rate = mbps(n)
+			}
+		}
+	})
+	return wifi.SSID != "" || wifi.Signal != 0
+}
+
+// parseSystemProfiler fills wifi with the current network of the interface
+// in the output of system_profiler SPAirPortDataType, returning false if it
+// is not associated to a network.
+func parseSystemProfiler(wifi *WiFi, out string) bool {
+	var inInterface, inCurrent, found bool
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == wifi.Interface+":":
+			inInterface = true
+			continue
+		case !inInterface:
+			continue
+		case line == "Current Network Information:":
+			inCurrent = true
+			continue
+		case !inCurrent:
+			continue
+		case !found && strings.HasSuffix(line, ":"):
+			// the first key without value is the network name
+			wifi.SSID = strings.TrimSuffix(line, ":")
+			found = true
+			continue
+		case strings.HasSuffix(line, ":"):
+			// the next section
+			return found
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch line[:i] {
+		case "Signal / Noise":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Signal = n
+			}
+		case "Transmit Rate":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Rate = mbps(n)
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,85 @@
+package fast
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sampleWiFi reads the wireless link of the interface of the default route,
+// nil if it is not wireless.
+// The signal comes from /proc/net/wireless, the SSID and rate from iw, if
+// installed.
+func sampleWiFi(ctx context.Context) *WiFi {
+	iface := defaultInterface()
+	if iface == "" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join("/sys/class/net", iface, "wireless")); err != nil {
+		return nil
+	}
+	wifi := &WiFi{Interface: iface}
+	if signal, ok := wirelessSignal(iface); ok {
+		wifi.Signal = signal
+	}
+	if out, ok := run(ctx, "iw", "dev", iface, "link"); ok {
+		parseIW(wifi, out)
+	}
+	return wifi
+}
+
+// defaultInterface returns the interface of the IPv4 default route.
+func defaultInterface() string {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[1] == "00000000" {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// wirelessSignal returns the signal level of iface in /proc/net/wireless.
+func wirelessSignal(iface string) (float64, bool) {
+	f, err := os.Open("/proc/net/wireless")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Interface: status link level noise ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 3 && fields[0] == iface+":" {
+			return leadingFloat(fields[3])
+		}
+	}
+	return 0, false
+}
+
+// parseIW fills wifi with the output of iw dev <interface> link.
+func parseIW(wifi *WiFi, out string) {
+	fields(out, func(key, value string) {
+		switch key {
+		case "SSID":
+			wifi.SSID = value
+		case "signal":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Signal = n
+			}
+		case "tx bitrate":
+			if n, ok := leadingFloat(value); ok {
+				wifi.Rate = mbps(n)
+			}
+		}
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package fast
+
+import "context"
+
+// sampleWiFi is only supported on Linux and macOS, so the wireless link is
+// never read.
+func sampleWiFi(ctx context.Context) *WiFi {
+	return nil
+}
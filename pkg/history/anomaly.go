@@ -0,0 +1,71 @@
+package history
+
+import "math"
+
+const (
+	// baselineWindow is the number of previous entries the baseline is
+	// computed from.
+	baselineWindow = 48
+	// minBaseline is the minimum number of previous entries needed to detect
+	// anomalies.
+	minBaseline = 10
+	// zThreshold is how many standard deviations below the baseline a speed
+	// must be to be considered anomalous.
+	zThreshold = 3
+	// minDeviation is the minimum standard deviation used, as a fraction of
+	// the baseline.
+	minDeviation = 0.05
+)
+
+// Anomaly describes an anomalous download speed.
+type Anomaly struct {
+	// ZScore is how many standard deviations the speed is from the baseline.
+	ZScore float64 `json:"z_score"`
+	// Baseline is the mean download speed of the previous entries in B/s.
+	Baseline float64 `json:"baseline_bytes_second"`
+}
+
+// detect returns an anomaly if the download speed of entry is more than
+// zThreshold standard deviations below the mean of the latest entries, nil
+// otherwise.
+// Entries that were anomalous themselves are left out of the baseline, so a
+// long outage doesn't become the new normal right away, only once it fills
+// the whole window.
+func detect(entries []Entry, entry Entry) *Anomaly {
+	if len(entries) > baselineWindow {
+		entries = entries[len(entries)-baselineWindow:]
+	}
+	var speeds []float64
+	for _, e := range entries {
+		if e.Anomaly == nil {
+			speeds = append(speeds, e.DownloadSpeed)
+		}
+	}
+	if len(speeds) < minBaseline {
+		return nil
+	}
+
+	var sum float64
+	for _, speed := range speeds {
+		sum += speed
+	}
+	mean := sum / float64(len(speeds))
+	var squares float64
+	for _, speed := range speeds {
+		squares += (speed - mean) * (speed - mean)
+	}
+	// very stable connections would make tiny drops look anomalous
+	stddev := math.Max(math.Sqrt(squares/float64(len(speeds))), mean*minDeviation)
+	if stddev == 0 {
+		return nil
+	}
+
+	z := (entry.DownloadSpeed - mean) / stddev
+	if z > -zThreshold {
+		return nil
+	}
+	return &Anomaly{
+		ZScore:   z,
+		Baseline: mean,
+	}
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func entriesOf(speeds ...float64) []Entry {
+	entries := make([]Entry, 0, len(speeds))
+	for _, speed := range speeds {
+		entries = append(entries, Entry{DownloadSpeed: speed})
+	}
+	return entries
+}
+
+func repeat(speed float64, n int) []float64 {
+	speeds := make([]float64, n)
+	for i := range speeds {
+		speeds[i] = speed
+	}
+	return speeds
+}
+
+func TestDetect(t *testing.T) {
+	alternating := make([]float64, 0, 20)
+	for i := 0; i < 10; i++ {
+		alternating = append(alternating, 90, 110)
+	}
+	anomalous := entriesOf(repeat(100, 10)...)
+	for i := 0; i < 5; i++ {
+		anomalous = append(anomalous, Entry{DownloadSpeed: 10, Anomaly: &Anomaly{}})
+	}
+
+	for _, tt := range []struct {
+		name     string
+		entries  []Entry
+		speed    float64
+		zScore   float64
+		baseline float64
+	}{
+		{name: "not enough entries", entries: entriesOf(repeat(100, 9)...), speed: 10},
+		{name: "normal", entries: entriesOf(alternating...), speed: 95},
+		{name: "faster", entries: entriesOf(alternating...), speed: 1000},
+		// mean 100, stddev 10
+		{name: "just above the threshold", entries: entriesOf(alternating...), speed: 71},
+		{name: "slow", entries: entriesOf(alternating...), speed: 50, zScore: -5, baseline: 100},
+		// stddev 0, floored to 5% of the mean
+		{name: "stable", entries: entriesOf(repeat(100, 10)...), speed: 80, zScore: -4, baseline: 100},
+		{name: "stable small drop", entries: entriesOf(repeat(100, 10)...), speed: 90},
+		{name: "zero baseline", entries: entriesOf(repeat(0, 10)...), speed: 0},
+		// older entries are out of the window
+		{name: "window", entries: entriesOf(append(repeat(10, 10), repeat(100, baselineWindow)...)...), speed: 80, zScore: -4, baseline: 100},
+		// previous anomalies are not part of the baseline
+		{name: "anomalies left out", entries: anomalous, speed: 10, zScore: -18, baseline: 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			anomaly := detect(tt.entries, Entry{DownloadSpeed: tt.speed})
+			if tt.zScore == 0 {
+				if anomaly != nil {
+					t.Fatalf("expected no anomaly, got %+v", anomaly)
+				}
+				return
+			}
+			if anomaly == nil {
+				t.Fatal("expected an anomaly")
+			}
+			if math.Abs(anomaly.ZScore-tt.zScore) > 1e-9 || anomaly.Baseline != tt.baseline {
+				t.Fatalf("expected z-score %v from %v, got %+v", tt.zScore, tt.baseline, anomaly)
+			}
+		})
+	}
+}
+
+func TestStoreDetects(t *testing.T) {
+	s, err := New(100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, speed := range append(repeat(100, 10), 50) {
+		entry, err := s.Add(Entry{Time: now.Add(time.Duration(i) * time.Minute), DownloadSpeed: speed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if anomalous := entry.Anomaly != nil; anomalous != (speed == 50) {
+			t.Fatalf("entry %d: expected anomalous to be %v, got %+v", i, speed == 50, entry.Anomaly)
+		}
+	}
+	if entries := s.Entries(); entries[len(entries)-1].Anomaly == nil {
+		t.Fatal("expected the stored entry to keep its anomaly")
+	}
+}
@@ -0,0 +1,199 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an outage event.
+type EventKind string
+
+const (
+	// LinkDown is when the link check failed before measuring.
+	LinkDown EventKind = "link_down"
+	// DrasticDrop is when the download speed was anomalously low.
+	DrasticDrop EventKind = "drastic_drop"
+)
+
+// Event is an outage, from the measurement it was first seen in until the
+// first one it was not anymore.
+type Event struct {
+	ID    string    `json:"id"`
+	Kind  EventKind `json:"kind"`
+	Start time.Time `json:"start"`
+	// End is nil while the event is ongoing.
+	End *time.Time `json:"end,omitempty"`
+	// Duration is how long the event lasted, or has lasted so far.
+	Duration time.Duration `json:"duration"`
+	// Detail describes the event, e.g. the error or speed measured when it
+	// started.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Events keeps the latest events in memory, appending them to a file as JSON
+// lines if one is given, once when they start and again when they end.
+type Events struct {
+	mutex  sync.RWMutex
+	size   int
+	path   string
+	events []Event
+}
+
+// NewEvents creates an event log keeping at most size events, loading the
+// existing ones from path if it is not empty.
+func NewEvents(size int, path string) (*Events, error) {
+	e := &Events{
+		size: size,
+		path: path,
+	}
+	if path == "" {
+		return e, nil
+	}
+	if err := e.load(); err != nil {
+		return nil, fmt.Errorf("could not load events: %w", err)
+	}
+	return e, nil
+}
+
+// Begin starts an event of the given kind, unless one is ongoing already.
+func (e *Events) Begin(kind EventKind, t time.Time, detail string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ongoing(kind) >= 0 {
+		return nil
+	}
+	event := Event{
+		ID:     fmt.Sprintf("%s-%d", kind, t.UnixNano()),
+		Kind:   kind,
+		Start:  t,
+		Detail: detail,
+	}
+	e.events = append(e.events, event)
+	if len(e.events) > e.size {
+		e.events = append([]Event(nil), e.events[len(e.events)-e.size:]...)
+	}
+	return e.append(event)
+}
+
+// End ends the ongoing event of the given kind, if any.
+func (e *Events) End(kind EventKind, t time.Time) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	i := e.ongoing(kind)
+	if i < 0 {
+		return nil
+	}
+	event := &e.events[i]
+	event.End = &t
+	event.Duration = t.Sub(event.Start)
+	return e.append(*event)
+}
+
+// List returns all events, oldest first, with the duration of the ongoing
+// ones so far.
+func (e *Events) List() []Event {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	events := append([]Event{}, e.events...)
+	for i := range events {
+		if events[i].End == nil {
+			events[i].Duration = time.Since(events[i].Start)
+		}
+	}
+	return events
+}
+
+// ongoing returns the index of the ongoing event of the kind, or -1.
+func (e *Events) ongoing(kind EventKind) int {
+	for i := len(e.events) - 1; i >= 0; i-- {
+		if e.events[i].Kind == kind {
+			if e.events[i].End == nil {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+func (e *Events) append(event Event) error {
+	if e.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not persist event: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(event); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not persist event: %w", err)
+	}
+	return f.Close()
+}
+
+// load reads the events persisted to path, where the last line of each event
+// has its latest state.
+func (e *Events) load() error {
+	f, err := os.Open(e.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var lines int
+	index := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("line %d: %w", lines, err)
+		}
+		if i, ok := index[event.ID]; ok {
+			e.events[i] = event
+			continue
+		}
+		index[event.ID] = len(e.events)
+		e.events = append(e.events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(e.events) > e.size {
+		e.events = append([]Event(nil), e.events[len(e.events)-e.size:]...)
+	}
+	if lines > len(e.events) {
+		// the file only grows when appending, so compact it here
+		return e.rewrite()
+	}
+	return nil
+}
+
+func (e *Events) rewrite() error {
+	tmp := e.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, event := range e.events {
+		if err := enc.Encode(event); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.path)
+}
@@ -0,0 +1,233 @@
+// Package history keeps the latest measurement results, optionally persisted
+// to a file.
+package history
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/fast"
+)
+
+// Entry is a measurement result in the history.
+type Entry struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	DownloadSpeed float64   `json:"download_bytes_second"`
+	UploadSpeed   float64   `json:"upload_bytes_second,omitempty"`
+	// Trigger is how the measurement was triggered, e.g. schedule or api.
+	Trigger string `json:"trigger,omitempty"`
+	// Server is the host of the test server measured against, and ServerIP
+	// the address it was reached at.
+	Server   string `json:"server,omitempty"`
+	ServerIP string `json:"server_ip,omitempty"`
+	// ClientIP is the public IP of the client as exported, hashed or empty
+	// depending on the public IP options, and ISP its provider, as reported
+	// by fast.com.
+	ClientIP string `json:"client_ip,omitempty"`
+	ISP      string `json:"isp,omitempty"`
+	// Method describes how the measurement was made, e.g. its protocol and
+	// number of connections.
+	Method string `json:"method,omitempty"`
+	// DownloadSamples and UploadSamples are the throughput samples of the
+	// measurements, if kept.
+	DownloadSamples []fast.Sample `json:"download_samples,omitempty"`
+	UploadSamples   []fast.Sample `json:"upload_samples,omitempty"`
+	// Anomaly is set if the download speed was anomalous compared to the
+	// previous entries.
+	Anomaly *Anomaly `json:"anomaly,omitempty"`
+	// Signature is the Ed25519 signature of the entry, if the store signs
+	// them.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Store keeps the latest entries in memory, appending them to a file as
+// JSON lines if one is given.
+type Store struct {
+	mutex   sync.RWMutex
+	size    int
+	path    string
+	entries []Entry
+	key     ed25519.PrivateKey
+	// lines is the number of entries in the file, compacted once it holds
+	// compact more than the ones kept, if compact is not zero.
+	lines   int
+	compact int
+}
+
+// New creates a store keeping at most size entries, loading the existing
+// entries from path if it is not empty.
+func New(size int, path string) (*Store, error) {
+	s := &Store{
+		size: size,
+		path: path,
+	}
+	if path == "" {
+		return s, nil
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("could not load history: %w", err)
+	}
+	return s, nil
+}
+
+// Add detects whether the entry is anomalous and adds it to the store,
+// returning it with its anomaly set.
+func (s *Store) Add(entry Entry) (Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.Anomaly = detect(s.entries, entry)
+	if s.key != nil {
+		if err := entry.sign(s.key); err != nil {
+			return entry, fmt.Errorf("could not sign history: %w", err)
+		}
+	}
+	s.entries = append(s.entries, entry)
+	if len(s.entries) > s.size {
+		s.entries = append([]Entry(nil), s.entries[len(s.entries)-s.size:]...)
+	}
+	if s.path == "" {
+		return entry, nil
+	}
+	if err := s.append(entry); err != nil {
+		return entry, fmt.Errorf("could not persist history: %w", err)
+	}
+	s.lines++
+	if s.compact > 0 && s.lines-len(s.entries) >= s.compact {
+		if err := s.rewrite(); err != nil {
+			return entry, fmt.Errorf("could not compact history: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// SetCompaction makes the store rewrite its file with only the entries kept
+// once it holds extra entries more, so it does not grow forever between
+// restarts.
+// Zero, the default, only compacts it when loading.
+func (s *Store) SetCompaction(extra int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.compact = extra
+}
+
+// Entries returns all entries, oldest first.
+func (s *Store) Entries() []Entry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Entry{}, s.entries...)
+}
+
+func (s *Store) load() error {
+	entries, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+	s.entries = entries
+	s.lines = len(entries)
+	if len(entries) > s.size {
+		s.entries = append([]Entry(nil), entries[len(entries)-s.size:]...)
+		// the file only grows when appending, so compact it here
+		return s.rewrite()
+	}
+	return nil
+}
+
+// Load reads all the entries persisted to path, oldest first, without
+// changing it.
+// A missing file has no entries.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *Store) append(entry Entry) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Store) rewrite() error {
+	if err := write(s.path, s.entries); err != nil {
+		return err
+	}
+	s.lines = len(s.entries)
+	return nil
+}
+
+// Merge adds the entries to the ones persisted to path, keeping them sorted by
+// time and skipping the ones with IDs already there.
+func Merge(path string, entries []Entry) (int, error) {
+	existing, err := Load(path)
+	if err != nil {
+		return 0, err
+	}
+	ids := make(map[string]bool, len(existing))
+	for _, entry := range existing {
+		ids[entry.ID] = true
+	}
+	var added int
+	for _, entry := range entries {
+		if ids[entry.ID] {
+			continue
+		}
+		ids[entry.ID] = true
+		existing = append(existing, entry)
+		added++
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		return existing[i].Time.Before(existing[j].Time)
+	})
+	return added, write(path, existing)
+}
+
+// write replaces the file at path with the given entries.
+func write(path string, entries []Entry) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
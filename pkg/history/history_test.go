@@ -0,0 +1,72 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := New(3, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if _, err := s.Add(Entry{ID: string(rune('a' + i)), Time: start.Add(time.Duration(i) * time.Hour), DownloadSpeed: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := idsOf(s.Entries()); ids != "cde" {
+		t.Fatalf("expected the latest 3 entries, got %q", ids)
+	}
+
+	// all entries are appended, and compacted on load
+	for _, tt := range []struct {
+		size  int
+		ids   string
+		lines int
+	}{
+		{size: 10, ids: "abcde", lines: 5},
+		{size: 2, ids: "de", lines: 2},
+	} {
+		s, err := New(tt.size, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := idsOf(s.Entries()); ids != tt.ids {
+			t.Fatalf("expected %q to be loaded with size %d, got %q", tt.ids, tt.size, ids)
+		}
+		bts, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(string(bts), "\n"); lines != tt.lines {
+			t.Fatalf("expected %d lines with size %d, got %d", tt.lines, tt.size, lines)
+		}
+	}
+}
+
+func TestStoreLoadErrors(t *testing.T) {
+	if s, err := New(10, filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || len(s.Entries()) != 0 {
+		t.Fatalf("expected a missing file to have no entries, got %v, %v", s, err)
+	}
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path, []byte("{\"id\":\"a\"}\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(10, path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error on line 2, got %v", err)
+	}
+}
+
+func idsOf(entries []Entry) string {
+	var ids string
+	for _, e := range entries {
+		ids += e.ID
+	}
+	return ids
+}
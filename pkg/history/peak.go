@@ -0,0 +1,87 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PeakHours are the hours of the day networks are busiest at, usually the
+// evening, when ISPs are most congested.
+type PeakHours struct {
+	// From is the first peak hour, To the first one after them, both from 0
+	// to 23, wrapping around midnight if To is not after From.
+	From, To int
+	// Location is the time zone of the hours, defaults to the local one.
+	Location *time.Location
+}
+
+// ParsePeakHours parses hours as from-to in 24h, e.g. 19-23 for 19:00 to
+// 23:00, in the given time zone.
+func ParsePeakHours(hours string, location *time.Location) (PeakHours, error) {
+	parts := strings.SplitN(hours, "-", 2)
+	if len(parts) != 2 {
+		return PeakHours{}, fmt.Errorf("invalid peak hours %q, expected from-to, e.g. 19-23", hours)
+	}
+	p := PeakHours{Location: location}
+	for i, dst := range []*int{&p.From, &p.To} {
+		h, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+		if err != nil || h < 0 || h > 23 {
+			return PeakHours{}, fmt.Errorf("invalid peak hour %q, expected 0 to 23", parts[i])
+		}
+		*dst = h
+	}
+	if p.From == p.To {
+		return PeakHours{}, fmt.Errorf("invalid peak hours %q, they are empty", hours)
+	}
+	return p, nil
+}
+
+// Contains returns whether t is in the peak hours.
+func (p PeakHours) Contains(t time.Time) bool {
+	location := p.Location
+	if location == nil {
+		location = time.Local
+	}
+	hour := t.In(location).Hour()
+	if p.From < p.To {
+		return hour >= p.From && hour < p.To
+	}
+	return hour >= p.From || hour < p.To
+}
+
+// PeriodAverage is the average speed of the entries of a period.
+type PeriodAverage struct {
+	Measurements  int
+	DownloadSpeed float64
+	// UploadSpeed is the average over the entries that measured it.
+	UploadSpeed float64
+	uploads     int
+}
+
+// add adds the entry to the running averages.
+func (a *PeriodAverage) add(e Entry) {
+	a.Measurements++
+	a.DownloadSpeed += (e.DownloadSpeed - a.DownloadSpeed) / float64(a.Measurements)
+	if e.UploadSpeed > 0 {
+		a.uploads++
+		a.UploadSpeed += (e.UploadSpeed - a.UploadSpeed) / float64(a.uploads)
+	}
+}
+
+// ByPeriod returns the averages of the entries since the given time in and
+// out of the peak hours.
+func ByPeriod(entries []Entry, since time.Time, hours PeakHours) (peak, offPeak PeriodAverage) {
+	for _, e := range entries {
+		if e.Time.Before(since) {
+			continue
+		}
+		if hours.Contains(e.Time) {
+			peak.add(e)
+		} else {
+			offPeak.add(e)
+		}
+	}
+	return peak, offPeak
+}
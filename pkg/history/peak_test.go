@@ -0,0 +1,85 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeakHours(t *testing.T) {
+	for _, tt := range []struct {
+		hours    string
+		from, to int
+		err      bool
+	}{
+		{hours: "19-23", from: 19, to: 23},
+		{hours: " 7 - 9 ", from: 7, to: 9},
+		{hours: "22-2", from: 22, to: 2},
+		{hours: "0-23", from: 0, to: 23},
+		{hours: "19", err: true},
+		{hours: "19-24", err: true},
+		{hours: "-1-3", err: true},
+		{hours: "a-b", err: true},
+		{hours: "19-19", err: true},
+	} {
+		t.Run(tt.hours, func(t *testing.T) {
+			hours, err := ParsePeakHours(tt.hours, time.UTC)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", hours)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hours.From != tt.from || hours.To != tt.to || hours.Location != time.UTC {
+				t.Fatalf("expected %d-%d UTC, got %+v", tt.from, tt.to, hours)
+			}
+		})
+	}
+}
+
+func TestPeakHoursContains(t *testing.T) {
+	saoPaulo := time.FixedZone("BRT", -3*60*60)
+	at := func(hour int) time.Time { return time.Date(2021, 6, 1, hour, 30, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		name  string
+		hours PeakHours
+		time  time.Time
+		peak  bool
+	}{
+		{name: "before", hours: PeakHours{From: 19, To: 23, Location: time.UTC}, time: at(18)},
+		{name: "from", hours: PeakHours{From: 19, To: 23, Location: time.UTC}, time: at(19), peak: true},
+		{name: "to", hours: PeakHours{From: 19, To: 23, Location: time.UTC}, time: at(23)},
+		{name: "around midnight", hours: PeakHours{From: 22, To: 2, Location: time.UTC}, time: at(1), peak: true},
+		{name: "after midnight", hours: PeakHours{From: 22, To: 2, Location: time.UTC}, time: at(2)},
+		// 22:30 UTC is 19:30 in Sao Paulo
+		{name: "time zone", hours: PeakHours{From: 19, To: 23, Location: saoPaulo}, time: at(22), peak: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if peak := tt.hours.Contains(tt.time); peak != tt.peak {
+				t.Fatalf("expected peak to be %v, got %v", tt.peak, peak)
+			}
+		})
+	}
+}
+
+func TestByPeriod(t *testing.T) {
+	since := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return since.Add(time.Duration(hour) * time.Hour) }
+	entries := []Entry{
+		{Time: since.Add(-time.Hour), DownloadSpeed: 1000},
+		{Time: at(10), DownloadSpeed: 100, UploadSpeed: 10},
+		{Time: at(11), DownloadSpeed: 80},
+		{Time: at(20), DownloadSpeed: 50, UploadSpeed: 4},
+		{Time: at(21), DownloadSpeed: 30, UploadSpeed: 6},
+	}
+	peak, offPeak := ByPeriod(entries, since, PeakHours{From: 19, To: 23, Location: time.UTC})
+	if peak.Measurements != 2 || peak.DownloadSpeed != 40 || peak.UploadSpeed != 5 {
+		t.Fatalf("expected 2 peak measurements averaging 40 and 5, got %+v", peak)
+	}
+	// the upload average only counts the entries that measured it
+	if offPeak.Measurements != 2 || offPeak.DownloadSpeed != 90 || offPeak.UploadSpeed != 10 {
+		t.Fatalf("expected 2 off-peak measurements averaging 90 and 10, got %+v", offPeak)
+	}
+}
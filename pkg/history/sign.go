@@ -0,0 +1,56 @@
+package history
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsigned is returned when verifying an entry without signature.
+var ErrUnsigned = errors.New("entry is not signed")
+
+// SetSigningKey makes the store sign the entries added from now on with key,
+// so they can be shown to be unaltered since they were measured.
+func (s *Store) SetSigningKey(key ed25519.PrivateKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.key = key
+}
+
+// sign sets the base64 encoded Ed25519 signature of the entry, covering all
+// of its other fields.
+func (e *Entry) sign(key ed25519.PrivateKey) error {
+	payload, err := e.payload()
+	if err != nil {
+		return err
+	}
+	e.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// Verify checks the signature of the entry with the public key.
+func (e Entry) Verify(key ed25519.PublicKey) error {
+	if e.Signature == "" {
+		return ErrUnsigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(e.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	payload, err := e.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, payload, sig) {
+		return errors.New("signature mismatch, the entry was altered or signed with another key")
+	}
+	return nil
+}
+
+// payload is the signed content of the entry, its JSON without signature.
+func (e Entry) payload() ([]byte, error) {
+	e.Signature = ""
+	return json.Marshal(e)
+}
@@ -0,0 +1,45 @@
+package history
+
+import "time"
+
+const (
+	// TrendWindow is how far back the trend is computed from.
+	TrendWindow = 7 * 24 * time.Hour
+	// minTrend is the minimum number of entries needed to compute a trend.
+	minTrend = 10
+)
+
+// Trend returns the slope of the linear regression of the download speeds
+// of the entries since the given time, in B/s per day, and whether there
+// were enough entries to compute it.
+// A negative trend means the connection is getting slower.
+func Trend(entries []Entry, since time.Time) (float64, bool) {
+	var xs, ys []float64
+	for _, e := range entries {
+		if e.Time.Before(since) {
+			continue
+		}
+		xs = append(xs, e.Time.Sub(since).Hours()/24)
+		ys = append(ys, e.DownloadSpeed)
+	}
+	if len(xs) < minTrend {
+		return 0, false
+	}
+
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return cov / variance, true
+}
@@ -0,0 +1,48 @@
+package history
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrend(t *testing.T) {
+	since := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	hourly := func(n int, speed func(day float64) float64) []Entry {
+		entries := make([]Entry, 0, n)
+		for i := 0; i < n; i++ {
+			at := since.Add(time.Duration(i) * time.Hour)
+			entries = append(entries, Entry{Time: at, DownloadSpeed: speed(at.Sub(since).Hours() / 24)})
+		}
+		return entries
+	}
+	old := Entry{Time: since.Add(-time.Hour), DownloadSpeed: 1e9}
+
+	for _, tt := range []struct {
+		name    string
+		entries []Entry
+		trend   float64
+		ok      bool
+	}{
+		{name: "not enough entries", entries: hourly(9, func(float64) float64 { return 100 })},
+		{name: "flat", entries: hourly(48, func(float64) float64 { return 100 }), ok: true},
+		{name: "slower", entries: hourly(48, func(day float64) float64 { return 100 - 10*day }), trend: -10, ok: true},
+		{name: "faster", entries: hourly(48, func(day float64) float64 { return 100 + 24*day }), trend: 24, ok: true},
+		// older entries are left out
+		{name: "since", entries: append([]Entry{old}, hourly(48, func(float64) float64 { return 100 })...), ok: true},
+		{name: "same time", entries: []Entry{
+			{Time: since}, {Time: since}, {Time: since}, {Time: since}, {Time: since},
+			{Time: since}, {Time: since}, {Time: since}, {Time: since}, {Time: since, DownloadSpeed: 100},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			trend, ok := Trend(tt.entries, since)
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %v, got %v", tt.ok, ok)
+			}
+			if math.Abs(trend-tt.trend) > 1e-6 {
+				t.Fatalf("expected a trend of %v B/s per day, got %v", tt.trend, trend)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+)
+
+// EmailOptions configures the email sink.
+type EmailOptions struct {
+	// Addr is the SMTP server address, as host:port.
+	// Port 465 uses implicit TLS, other ports STARTTLS if the server
+	// supports it.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Every, if set, sends a single summary of all results at most this often
+	// instead of an email per result.
+	Every time.Duration
+}
+
+// NewEmail returns a sink that emails results through SMTP.
+func NewEmail(opts EmailOptions) Sink {
+	return &emailSink{opts: opts}
+}
+
+type emailSink struct {
+	opts EmailOptions
+
+	mutex    sync.Mutex
+	pending  []Result
+	lastSent time.Time
+}
+
+func (s *emailSink) Write(ctx context.Context, result Result) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pending = append(s.pending, result)
+	if s.opts.Every > 0 {
+		if s.lastSent.IsZero() {
+			// the first summary covers a full period
+			s.lastSent = result.Time
+		}
+		if result.Time.Sub(s.lastSent) < s.opts.Every {
+			return nil
+		}
+	}
+	if err := s.send(ctx, s.pending); err != nil {
+		return err
+	}
+	s.pending = nil
+	s.lastSent = result.Time
+	return nil
+}
+
+func (s *emailSink) send(ctx context.Context, results []Result) error {
+	host, port, err := net.SplitHostPort(s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp address %q: %w", s.opts.Addr, err)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if port == "465" {
+		conn = tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if s.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.opts.From); err != nil {
+		return err
+	}
+	for _, to := range s.opts.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(results)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *emailSink) message(results []Result) []byte {
+	var subject string
+	if len(results) == 1 {
+		subject = "fast.com: " + formatResult(results[0])
+	} else {
+		var download, upload float64
+		for _, r := range results {
+			download += r.DownloadSpeed
+			upload += r.UploadSpeed
+		}
+		avg := Result{
+			DownloadSpeed: download / float64(len(results)),
+			UploadSpeed:   upload / float64(len(results)),
+		}
+		subject = fmt.Sprintf("fast.com: %d measurements, %s on average", len(results), formatResult(avg))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.opts.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, r := range results {
+		fmt.Fprintf(&buf, "%s\t%s\t%s\r\n", r.Time.Format(time.RFC3339), r.ID, formatResult(r))
+	}
+	return buf.Bytes()
+}
+
+func formatResult(r Result) string {
+	s := units.BytesPerSecond(r.DownloadSpeed).Human() + " down"
+	if r.UploadSpeed > 0 {
+		s += ", " + units.BytesPerSecond(r.UploadSpeed).Human() + " up"
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// NewExec returns a sink that runs the command through the shell, with the
+// download and upload speeds in bits per second in DOWNLOAD_BPS and
+// UPLOAD_BPS, and the idle latency in milliseconds in LATENCY_MS, e.g. to
+// tune the SQM bandwidth of a router.
+// Unknown values are unset, e.g. UPLOAD_BPS if the upload was not measured.
+func NewExec(command string) Sink {
+	return &execSink{command: command}
+}
+
+type execSink struct {
+	command string
+}
+
+func (s *execSink) Write(ctx context.Context, result Result) error {
+	cmd := shell(ctx, s.command)
+	cmd.Env = append(os.Environ(), resultEnv(result)...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %q failed: %w: %s", s.command, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func resultEnv(result Result) []string {
+	env := []string{
+		"DOWNLOAD_BPS=" + strconv.FormatInt(int64(result.DownloadSpeed*8), 10),
+	}
+	if result.UploadSpeed > 0 {
+		env = append(env, "UPLOAD_BPS="+strconv.FormatInt(int64(result.UploadSpeed*8), 10))
+	}
+	if result.Latency > 0 {
+		env = append(env, "LATENCY_MS="+strconv.FormatFloat(float64(result.Latency.Microseconds())/1000, 'f', -1, 64))
+	}
+	return env
+}
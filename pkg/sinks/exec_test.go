@@ -0,0 +1,28 @@
+package sinks
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultEnv(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		result Result
+		env    string
+	}{
+		{name: "download", result: Result{DownloadSpeed: 12.5e6}, env: "DOWNLOAD_BPS=100000000"},
+		{
+			name:   "all",
+			result: Result{DownloadSpeed: 12.5e6, UploadSpeed: 1.25e6, Latency: 12500 * time.Microsecond},
+			env:    "DOWNLOAD_BPS=100000000 UPLOAD_BPS=10000000 LATENCY_MS=12.5",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if env := strings.Join(resultEnv(tt.result), " "); env != tt.env {
+				t.Fatalf("expected %q, got %q", tt.env, env)
+			}
+		})
+	}
+}
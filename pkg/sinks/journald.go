@@ -0,0 +1,115 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// journalSocket is where journald receives native protocol entries.
+	journalSocket = "/run/systemd/journal/socket"
+	// DefaultIdentifier is the syslog identifier of the journal entries, as
+	// queried with journalctl -t.
+	DefaultIdentifier = "fastcom-exporter"
+	// priorityInfo is the syslog info priority.
+	priorityInfo = 6
+)
+
+// MESSAGE_ID of the journal entries, so they can be queried with
+// journalctl MESSAGE_ID=...
+const (
+	ResultMessageID    = "5f1d3c0e9a8b4c6e8a2f7b1d0c3e4a59"
+	LifecycleMessageID = "0b7e2a9c4d1f4e83b6a5c8d2e7f1a304"
+)
+
+// Lifecycle is an event of the exporter lifecycle.
+type Lifecycle string
+
+const (
+	// Started is when the exporter starts.
+	Started Lifecycle = "started"
+	// Stopped is when the exporter shuts down cleanly.
+	Stopped Lifecycle = "stopped"
+)
+
+// Journald writes results and lifecycle events as structured journald
+// entries, through its native protocol, only available on Linux with
+// systemd.
+type Journald struct {
+	identifier string
+	socket     string
+}
+
+// NewJournald returns a sink writing journal entries with the given syslog
+// identifier, DefaultIdentifier if empty.
+func NewJournald(identifier string) *Journald {
+	if identifier == "" {
+		identifier = DefaultIdentifier
+	}
+	return &Journald{identifier: identifier, socket: journalSocket}
+}
+
+// Write implements Sink.
+func (j *Journald) Write(ctx context.Context, result Result) error {
+	fields := [][2]string{
+		{"MESSAGE", fmt.Sprintf("measurement %s: %s", result.ID, formatResult(result))},
+		{"MESSAGE_ID", ResultMessageID},
+		{"FASTCOM_MEASUREMENT_ID", result.ID},
+		{"FASTCOM_DOWNLOAD_BYTES_SECOND", strconv.FormatFloat(result.DownloadSpeed, 'f', 0, 64)},
+	}
+	if result.UploadSpeed > 0 {
+		fields = append(fields, [2]string{"FASTCOM_UPLOAD_BYTES_SECOND", strconv.FormatFloat(result.UploadSpeed, 'f', 0, 64)})
+	}
+	return j.send(ctx, fields)
+}
+
+// Lifecycle writes a lifecycle event of the exporter of the given version.
+func (j *Journald) Lifecycle(ctx context.Context, event Lifecycle, version string) error {
+	return j.send(ctx, [][2]string{
+		{"MESSAGE", fmt.Sprintf("fastcom-exporter %s %s", version, event)},
+		{"MESSAGE_ID", LifecycleMessageID},
+		{"FASTCOM_EVENT", string(event)},
+		{"FASTCOM_VERSION", version},
+	})
+}
+
+// send writes an entry with the given fields, along with the identifier and
+// priority.
+func (j *Journald) send(ctx context.Context, fields [][2]string) error {
+	var buf bytes.Buffer
+	fields = append(fields,
+		[2]string{"SYSLOG_IDENTIFIER", j.identifier},
+		[2]string{"PRIORITY", strconv.Itoa(priorityInfo)},
+	)
+	for _, f := range fields {
+		writeJournalField(&buf, f[0], f[1])
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unixgram", j.socket)
+	if err != nil {
+		return fmt.Errorf("could not connect to journald: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("could not write to journald: %w", err)
+	}
+	return nil
+}
+
+// writeJournalField writes a field in the native protocol: KEY=value lines,
+// or, for values with newlines, the key, a line break, the little endian
+// 64 bit length of the value and the value itself.
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(key + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
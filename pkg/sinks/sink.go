@@ -0,0 +1,97 @@
+// Package sinks pushes measurement results to external systems.
+package sinks
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Result is a measurement result, as written to sinks.
+type Result struct {
+	ID            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	DownloadSpeed float64   `json:"download_bytes_second"`
+	UploadSpeed   float64   `json:"upload_bytes_second,omitempty"`
+	// Trigger is how the measurement was triggered, e.g. schedule or api.
+	Trigger string `json:"trigger,omitempty"`
+	// Latency is the idle latency of the last probe, if any.
+	Latency time.Duration `json:"latency,omitempty"`
+}
+
+// Sink receives measurement results.
+type Sink interface {
+	Write(ctx context.Context, result Result) error
+}
+
+// OnlyChanges wraps the given sink so results are only written when the
+// download or upload speed changed more than the given fraction since the last written
+// result.
+func OnlyChanges(sink Sink, minChange float64) Sink {
+	return &changeSink{
+		sink:      sink,
+		minChange: minChange,
+	}
+}
+
+type changeSink struct {
+	sink      Sink
+	minChange float64
+
+	mutex sync.Mutex
+	last  *Result
+}
+
+func (s *changeSink) Write(ctx context.Context, result Result) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.last != nil &&
+		!changed(s.last.DownloadSpeed, result.DownloadSpeed, s.minChange) &&
+		!changed(s.last.UploadSpeed, result.UploadSpeed, s.minChange) {
+		return nil
+	}
+	if err := s.sink.Write(ctx, result); err != nil {
+		return err
+	}
+	s.last = &result
+	return nil
+}
+
+func changed(old, new, minChange float64) bool {
+	if old == 0 {
+		return new != 0
+	}
+	return math.Abs(new-old)/old > minChange
+}
+
+// OnlyBelow wraps the given sink so results are only written when the download
+// or upload speed, in B/s, is below the given thresholds.
+// Zero thresholds are not checked.
+func OnlyBelow(sink Sink, download, upload float64) Sink {
+	return &belowSink{
+		sink:     sink,
+		download: download,
+		upload:   upload,
+	}
+}
+
+type belowSink struct {
+	sink     Sink
+	download float64
+	upload   float64
+}
+
+func (s *belowSink) Write(ctx context.Context, result Result) error {
+	// results without upload speed are not upload breaches
+	uploadBreached := result.UploadSpeed > 0 && below(result.UploadSpeed, s.upload)
+	if !below(result.DownloadSpeed, s.download) && !uploadBreached {
+		return nil
+	}
+	return s.sink.Write(ctx, result)
+}
+
+func below(speed, threshold float64) bool {
+	return threshold > 0 && speed < threshold
+}
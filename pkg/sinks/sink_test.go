@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	results []Result
+}
+
+func (r *recorder) Write(_ context.Context, result Result) error {
+	r.results = append(r.results, result)
+	return nil
+}
+
+func TestOnlyChanges(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		minChange float64
+		speeds    []float64
+		written   []float64
+	}{
+		{name: "first", minChange: 0.1, speeds: []float64{100}, written: []float64{100}},
+		{name: "small changes", minChange: 0.1, speeds: []float64{100, 105, 95, 109}, written: []float64{100}},
+		{name: "large changes", minChange: 0.1, speeds: []float64{100, 120, 100, 80}, written: []float64{100, 120, 100, 80}},
+		// compared to the last written result, not the last one
+		{name: "drift", minChange: 0.1, speeds: []float64{100, 106, 112}, written: []float64{100, 112}},
+		{name: "from zero", minChange: 0.1, speeds: []float64{0, 0, 10}, written: []float64{0, 10}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			s := OnlyChanges(rec, tt.minChange)
+			for _, speed := range tt.speeds {
+				if err := s.Write(context.Background(), Result{DownloadSpeed: speed}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var written []float64
+			for _, r := range rec.results {
+				written = append(written, r.DownloadSpeed)
+			}
+			if len(written) != len(tt.written) {
+				t.Fatalf("expected %v to be written, got %v", tt.written, written)
+			}
+			for i := range written {
+				if written[i] != tt.written[i] {
+					t.Fatalf("expected %v to be written, got %v", tt.written, written)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Result
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer foo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	result := Result{Time: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC), DownloadSpeed: 12.5e6}
+	s := NewWebhook(srv.URL, map[string]string{"Authorization": "Bearer foo"})
+	if err := s.Write(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Time.Equal(result.Time) || got.DownloadSpeed != result.DownloadSpeed {
+		t.Fatalf("expected %+v to be posted, got %+v", result, got)
+	}
+
+	s = NewWebhook(srv.URL, nil)
+	if err := s.Write(context.Background(), result); err == nil {
+		t.Fatal("expected an error on a bad request")
+	}
+}
+
+func TestOnlyBelow(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		download, upload float64
+		result           Result
+		written          bool
+	}{
+		{name: "above", download: 100, upload: 10, result: Result{DownloadSpeed: 200, UploadSpeed: 20}},
+		{name: "download below", download: 100, upload: 10, result: Result{DownloadSpeed: 50, UploadSpeed: 20}, written: true},
+		{name: "upload below", download: 100, upload: 10, result: Result{DownloadSpeed: 200, UploadSpeed: 5}, written: true},
+		{name: "upload not measured", download: 100, upload: 10, result: Result{DownloadSpeed: 200}},
+		{name: "download not checked", upload: 10, result: Result{DownloadSpeed: 1, UploadSpeed: 20}},
+		{name: "upload not checked", download: 100, result: Result{DownloadSpeed: 200, UploadSpeed: 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			if err := OnlyBelow(rec, tt.download, tt.upload).Write(context.Background(), tt.result); err != nil {
+				t.Fatal(err)
+			}
+			if written := len(rec.results) == 1; written != tt.written {
+				t.Fatalf("expected written to be %v, got %v", tt.written, written)
+			}
+		})
+	}
+}
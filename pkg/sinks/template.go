@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+)
+
+// TemplateOptions configures the template sink.
+type TemplateOptions struct {
+	URL string
+	// Method defaults to POST.
+	Method  string
+	Headers map[string]string
+	// Body is a text/template rendering the Result, e.g. the JSON an IFTTT
+	// or Google Apps Script webhook expects, sent as JSON unless the headers
+	// set another Content-Type.
+	// Empty sends no body.
+	Body string
+}
+
+// NewTemplate returns a sink that sends results to the given URL, in a body
+// rendered from a template, so other endpoints do not need their own sink.
+func NewTemplate(opts TemplateOptions) (Sink, error) {
+	body, err := ParseTemplate(opts.Body)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Method == "" {
+		opts.Method = http.MethodPost
+	}
+	return &templateSink{opts: opts, body: body}, nil
+}
+
+// ParseTemplate parses a body template of the template sink, rendering it
+// once with an empty Result so unknown fields fail right away.
+// Besides the Result fields, templates can use mbps and human to format
+// speeds, e.g. {{ mbps .DownloadSpeed }}, and json to quote values.
+func ParseTemplate(body string) (*template.Template, error) {
+	t, err := template.New("body").Funcs(template.FuncMap{
+		"mbps": func(speed float64) float64 {
+			return units.BytesPerSecond(speed).Mbps()
+		},
+		"human": func(speed float64) string {
+			return units.BytesPerSecond(speed).Human()
+		},
+		"json": func(v interface{}) (string, error) {
+			bts, err := json.Marshal(v)
+			return string(bts), err
+		},
+	}).Option("missingkey=error").Parse(body)
+	if err == nil {
+		err = t.Execute(io.Discard, Result{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	return t, nil
+}
+
+type templateSink struct {
+	opts TemplateOptions
+	body *template.Template
+}
+
+func (s *templateSink) Write(ctx context.Context, result Result) error {
+	var body io.Reader
+	if s.opts.Body != "" {
+		var buf bytes.Buffer
+		if err := s.body.Execute(&buf, result); err != nil {
+			return fmt.Errorf("could not render body: %w", err)
+		}
+		body = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, s.opts.Method, s.opts.URL, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s", s.opts.Method, s.opts.URL, resp.Status)
+	}
+	return nil
+}
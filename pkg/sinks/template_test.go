@@ -0,0 +1,96 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		err  bool
+	}{
+		{name: "empty"},
+		{name: "fields", body: `{"value1": {{ json .ID }}, "value2": {{ mbps .DownloadSpeed }}}`},
+		{name: "human", body: `{{ human .DownloadSpeed }}`},
+		{name: "syntax", body: `{{ .ID `, err: true},
+		{name: "unknown field", body: `{{ .Speed }}`, err: true},
+		{name: "unknown function", body: `{{ kbps .DownloadSpeed }}`, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplate(tt.body)
+			if tt.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	type request struct {
+		method, contentType, token, body string
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bts, _ := io.ReadAll(r.Body)
+		requests <- request{r.Method, r.Header.Get("Content-Type"), r.Header.Get("X-Token"), string(bts)}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	result := Result{ID: "abc", DownloadSpeed: 12.5e6}
+	for _, tt := range []struct {
+		name string
+		opts TemplateOptions
+		want request
+		err  bool
+	}{
+		{
+			name: "json",
+			opts: TemplateOptions{URL: srv.URL, Body: `{"value1": {{ json .ID }}, "value2": {{ mbps .DownloadSpeed }}}`, Headers: map[string]string{"X-Token": "foo"}},
+			want: request{method: http.MethodPost, contentType: "application/json", token: "foo", body: `{"value1": "abc", "value2": 100}`},
+		},
+		{
+			name: "content type",
+			opts: TemplateOptions{URL: srv.URL, Method: http.MethodPut, Body: `{{ human .DownloadSpeed }}`, Headers: map[string]string{"Content-Type": "text/plain"}},
+			want: request{method: http.MethodPut, contentType: "text/plain", body: "100.0 Mbps"},
+		},
+		{
+			name: "without body",
+			opts: TemplateOptions{URL: srv.URL, Method: http.MethodGet},
+			want: request{method: http.MethodGet},
+		},
+		{
+			name: "failure",
+			opts: TemplateOptions{URL: srv.URL + "/fail"},
+			want: request{method: http.MethodPost},
+			err:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewTemplate(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Write(context.Background(), result)
+			if tt.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+			if got := <-requests; got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
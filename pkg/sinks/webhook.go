@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// NewWebhook returns a sink that POSTs results as JSON to the given URL.
+func NewWebhook(url string, headers map[string]string) Sink {
+	return &webhookSink{
+		url:     url,
+		headers: headers,
+	}
+}
+
+type webhookSink struct {
+	url     string
+	headers map[string]string
+}
+
+func (s *webhookSink) Write(ctx context.Context, result Result) error {
+	bts, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package units converts speeds between bytes and bits per second.
+//
+// The exported API follows semantic versioning along with the exporter: it
+// only changes in backwards-incompatible ways on major releases.
+package units
+
+import "fmt"
+
+// BytesPerSecond is a speed in B/s, the unit used by the exporter.
+type BytesPerSecond float64
+
+// FromBitsPerSecond converts a speed in bit/s.
+func FromBitsPerSecond(bps float64) BytesPerSecond {
+	return BytesPerSecond(bps / 8)
+}
+
+// FromMbps converts a speed in Mbps, e.g. from an internet plan.
+func FromMbps(mbps float64) BytesPerSecond {
+	return FromBitsPerSecond(mbps * 1e6)
+}
+
+// BitsPerSecond returns the speed in bit/s.
+func (s BytesPerSecond) BitsPerSecond() float64 {
+	return float64(s) * 8
+}
+
+// Mbps returns the speed in Mbps, 10^6 bit/s.
+func (s BytesPerSecond) Mbps() float64 {
+	return s.BitsPerSecond() / 1e6
+}
+
+// Gbps returns the speed in Gbps, 10^9 bit/s.
+func (s BytesPerSecond) Gbps() float64 {
+	return s.BitsPerSecond() / 1e9
+}
+
+// Human returns the speed in bits per second with the most readable unit,
+// e.g. "94.3 Mbps" or "1.25 Gbps".
+func (s BytesPerSecond) Human() string {
+	bps := s.BitsPerSecond()
+	switch {
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbps", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.1f Mbps", bps/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.1f kbps", bps/1e3)
+	}
+	return fmt.Sprintf("%.0f bps", bps)
+}
+
+// String implements fmt.Stringer, same as Human.
+func (s BytesPerSecond) String() string {
+	return s.Human()
+}
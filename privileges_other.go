@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "errors"
+
+// checkPrivileges does nothing, there is no root on Windows.
+func checkPrivileges() error {
+	return nil
+}
+
+// dropPrivileges is not supported on Windows.
+func dropPrivileges(name string) error {
+	return errors.New("run-as is not supported on Windows")
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// checkPrivileges refuses to run as root unless allowed, or needed by
+// --netns, which switches network namespaces for every measurement.
+// No other feature needs privileges once the listeners are bound.
+func checkPrivileges() error {
+	if os.Geteuid() != 0 || *allowRoot || *runAs != "" || *netnsName != "" {
+		return nil
+	}
+	return errors.New("refusing to run as root, pass --run-as to switch to another user once listening, or --allow-root")
+}
+
+// dropPrivileges switches to the given user and its primary group, dropping
+// the capabilities of root along with them, and sets HOME to its home
+// directory.
+func dropPrivileges(name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid of %s: %w", name, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid of %s: %w", name, err)
+	}
+	// the group must be changed first, as it can not be once not root.
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("could not set groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("could not set gid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("could not set uid: %w", err)
+	}
+	// so the default directories are the ones of the user, like su does.
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		os.Unsetenv(env)
+	}
+	return os.Setenv("HOME", u.HomeDir)
+}
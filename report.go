@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/internal/config"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/caarlos0/fastcom-exporter/pkg/units"
+)
+
+// worstHours is the number of hours of the day listed in reports.
+const worstHours = 3
+
+// nolint: gochecknoglobals
+var reportPeriods = map[string]time.Duration{
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+}
+
+type report struct {
+	// Period is empty for reports of arbitrary ranges.
+	Period       string
+	From         time.Time
+	To           time.Time
+	Measurements int
+	Anomalies    int
+	Download     speedStats
+	Upload       *speedStats
+	WorstHours   []hourStats
+	// OnDemand is the number of measurements triggered through the API or
+	// the one-shot mode, left out of the statistics.
+	OnDemand int
+	// ISPs and ClientIPs are the distinct ones seen in the range, as
+	// recorded in the history.
+	ISPs      []string
+	ClientIPs []string
+	// Entries are all the measurements in the range, oldest first, with the
+	// details ISPs ask for in disputes.
+	Entries []history.Entry
+	// Chart plots the download speed of the measurements.
+	Chart chart
+}
+
+// chart is a line chart of speeds over time, as SVG coordinates.
+type chart struct {
+	Width  int
+	Height int
+	// Points of the polyline, as "x,y x,y...".
+	Points string
+	// MaxMbps is the speed at the top of the chart.
+	MaxMbps float64
+	// TargetY is the height of the SLA target line, if a threshold is
+	// configured.
+	TargetY *float64
+}
+
+// speedStats summarizes speeds, in Mbps.
+type speedStats struct {
+	Average float64
+	P5      float64
+	P50     float64
+	P95     float64
+	// SLA is only set if a threshold is configured.
+	SLA *slaStats
+}
+
+type slaStats struct {
+	TargetMbps float64
+	Met        int
+	// Attainment is the percentage of measurements that met the target.
+	Attainment float64
+}
+
+type hourStats struct {
+	Hour         int
+	Measurements int
+	// Average download speed, in Mbps.
+	Average float64
+}
+
+// newReport summarizes the entries of the given period, ending at now.
+func newReport(entries []history.Entry, period string, now time.Time, thresholds config.Thresholds) (report, error) {
+	r, err := newRangeReport(entries, now.Add(-reportPeriods[period]), now, thresholds)
+	r.Period = period
+	return r, err
+}
+
+// newRangeReport summarizes the entries between from and to.
+// Hours are in the local time zone.
+func newRangeReport(entries []history.Entry, from, to time.Time, thresholds config.Thresholds) (report, error) {
+	var download, upload []float64
+	hours := map[int][]float64{}
+	isps := map[string]bool{}
+	ips := map[string]bool{}
+	r := report{
+		From: from,
+		To:   to,
+	}
+	for _, entry := range entries {
+		if entry.Time.Before(from) || entry.Time.After(to) {
+			continue
+		}
+		r.Entries = append(r.Entries, entry)
+		if entry.ISP != "" && !isps[entry.ISP] {
+			isps[entry.ISP] = true
+			r.ISPs = append(r.ISPs, entry.ISP)
+		}
+		if entry.ClientIP != "" && !ips[entry.ClientIP] {
+			ips[entry.ClientIP] = true
+			r.ClientIPs = append(r.ClientIPs, entry.ClientIP)
+		}
+		if collector.TriggerSource(entry.Trigger).OnDemand() {
+			r.OnDemand++
+			continue
+		}
+		r.Measurements++
+		if entry.Anomaly != nil {
+			r.Anomalies++
+		}
+		mbps := toMbps(entry.DownloadSpeed)
+		download = append(download, mbps)
+		hour := entry.Time.Local().Hour()
+		hours[hour] = append(hours[hour], mbps)
+		if entry.UploadSpeed > 0 {
+			upload = append(upload, toMbps(entry.UploadSpeed))
+		}
+	}
+	if r.Measurements == 0 {
+		return r, errors.New("no measurements in the period")
+	}
+
+	r.Download = newSpeedStats(download, thresholds.DownloadMbps)
+	if len(upload) > 0 {
+		stats := newSpeedStats(upload, thresholds.UploadMbps)
+		r.Upload = &stats
+	}
+	for hour, speeds := range hours {
+		r.WorstHours = append(r.WorstHours, hourStats{
+			Hour:         hour,
+			Measurements: len(speeds),
+			Average:      average(speeds),
+		})
+	}
+	sort.Slice(r.WorstHours, func(i, j int) bool {
+		return r.WorstHours[i].Average < r.WorstHours[j].Average
+	})
+	if len(r.WorstHours) > worstHours {
+		r.WorstHours = r.WorstHours[:worstHours]
+	}
+	sort.SliceStable(r.Entries, func(i, j int) bool {
+		return r.Entries[i].Time.Before(r.Entries[j].Time)
+	})
+	r.Chart = newChart(r.Entries, from, to, thresholds.DownloadMbps)
+	return r, nil
+}
+
+// newChart plots the download speed of the entries between from and to.
+func newChart(entries []history.Entry, from, to time.Time, target float64) chart {
+	c := chart{Width: 800, Height: 200, MaxMbps: target}
+	for _, entry := range entries {
+		c.MaxMbps = math.Max(c.MaxMbps, toMbps(entry.DownloadSpeed))
+	}
+	if c.MaxMbps == 0 {
+		return c
+	}
+	c.MaxMbps *= 1.1
+	y := func(mbps float64) float64 {
+		return float64(c.Height) * (1 - mbps/c.MaxMbps)
+	}
+	span := to.Sub(from).Seconds()
+	points := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		x := float64(c.Width) * entry.Time.Sub(from).Seconds() / span
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y(toMbps(entry.DownloadSpeed))))
+	}
+	c.Points = strings.Join(points, " ")
+	if target > 0 {
+		ty := y(target)
+		c.TargetY = &ty
+	}
+	return c
+}
+
+func newSpeedStats(speeds []float64, target float64) speedStats {
+	sorted := append([]float64(nil), speeds...)
+	sort.Float64s(sorted)
+	stats := speedStats{
+		Average: average(sorted),
+		P5:      percentile(sorted, 0.05),
+		P50:     percentile(sorted, 0.5),
+		P95:     percentile(sorted, 0.95),
+	}
+	if target > 0 {
+		sla := &slaStats{TargetMbps: target}
+		for _, speed := range sorted {
+			if speed >= target {
+				sla.Met++
+			}
+		}
+		sla.Attainment = 100 * float64(sla.Met) / float64(len(sorted))
+		stats.SLA = sla
+	}
+	return stats
+}
+
+func toMbps(bytesSecond float64) float64 {
+	return units.BytesPerSecond(bytesSecond).Mbps()
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// writeReport writes the report in the given format, markdown or html.
+func writeReport(w io.Writer, r report, format string) error {
+	switch format {
+	case "markdown":
+		return markdownReport.Execute(w, r)
+	case "html":
+		return htmlReport.Execute(w, r)
+	default:
+		return fmt.Errorf("invalid report format %q", format)
+	}
+}
+
+// nolint: gochecknoglobals
+var reportFuncs = map[string]interface{}{
+	"mbps": func(v float64) string { return fmt.Sprintf("%.1f Mbps", v) },
+	"pct":  func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04 MST") },
+	// timestamp is precise and unambiguous, as ISPs ask for in disputes.
+	"timestamp": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05 -0700") },
+	"speed": func(bytesSecond float64) string {
+		if bytesSecond == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f Mbps", toMbps(bytesSecond))
+	},
+	"orDash": func(v string) string {
+		if v == "" {
+			return "-"
+		}
+		return v
+	},
+	"join": strings.Join,
+}
+
+// nolint: gochecknoglobals
+var markdownReport = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(`# Internet speed report
+
+{{ .Measurements }} fast.com measurements from {{ date .From }} to {{ date .To }}{{ with .Period }} ({{ . }}){{ end }}{{ if .Anomalies }}, {{ .Anomalies }} of them anomalously slow{{ end }}.{{ if .OnDemand }} {{ .OnDemand }} on-demand measurements are left out.{{ end }}
+{{- with .ISPs }}
+
+ISP: {{ join . ", " }}
+{{- end }}
+{{- with .ClientIPs }}
+
+Public IP: {{ join . ", " }}
+{{- end }}
+
+| | Average | 5th percentile | Median | 95th percentile | SLA attainment |
+|---|---|---|---|---|---|
+{{- define "row" }} {{ mbps .Average }} | {{ mbps .P5 }} | {{ mbps .P50 }} | {{ mbps .P95 }} | {{ with .SLA }}{{ pct .Attainment }} of measurements at least {{ mbps .TargetMbps }}{{ else }}-{{ end }} |{{ end }}
+| Download |{{ template "row" .Download }}
+{{- with .Upload }}
+| Upload |{{ template "row" . }}
+{{- end }}
+
+## Worst hours
+
+| Hour | Average download | Measurements |
+|---|---|---|
+{{- range .WorstHours }}
+| {{ printf "%02d:00" .Hour }} | {{ mbps .Average }} | {{ .Measurements }} |
+{{- end }}
+
+## Measurements
+
+| Time | Download | Upload | Server | Server IP | Public IP | Method | Trigger |
+|---|---|---|---|---|---|---|---|
+{{- range .Entries }}
+| {{ timestamp .Time }} | {{ speed .DownloadSpeed }} | {{ speed .UploadSpeed }} | {{ orDash .Server }} | {{ orDash .ServerIP }} | {{ orDash .ClientIP }} | {{ orDash .Method }} | {{ orDash .Trigger }} |
+{{- end }}
+`))
+
+// nolint: gochecknoglobals
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(`<html>
+<head>
+	<title>Internet speed report</title>
+	<style>
+		body { font-family: sans-serif; }
+		table { border-collapse: collapse; }
+		td, th { border: 1px solid #ccc; padding: 2px 6px; }
+		@media print { tr { page-break-inside: avoid; } }
+	</style>
+</head>
+<body>
+	<h1>Internet speed report</h1>
+	<p>{{ .Measurements }} fast.com measurements from {{ date .From }} to {{ date .To }}{{ with .Period }} ({{ . }}){{ end }}{{ if .Anomalies }}, {{ .Anomalies }} of them anomalously slow{{ end }}.{{ if .OnDemand }} {{ .OnDemand }} on-demand measurements are left out.{{ end }}</p>
+	{{- with .ISPs }}
+	<p>ISP: {{ join . ", " }}</p>
+	{{- end }}
+	{{- with .ClientIPs }}
+	<p>Public IP: {{ join . ", " }}</p>
+	{{- end }}
+	{{- with .Chart }}{{ if .Points }}
+	<svg width="{{ .Width }}" height="{{ .Height }}" viewBox="0 0 {{ .Width }} {{ .Height }}" style="border: 1px solid #ccc">
+		<title>Download speed, up to {{ mbps .MaxMbps }}</title>
+		{{- with .TargetY }}
+		<line x1="0" y1="{{ . }}" x2="{{ $.Chart.Width }}" y2="{{ . }}" stroke="red" stroke-dasharray="4"/>
+		{{- end }}
+		<polyline points="{{ .Points }}" fill="none" stroke="steelblue" stroke-width="2"/>
+	</svg>
+	<p>Download speed from {{ date $.From }} to {{ date $.To }}, up to {{ mbps .MaxMbps }}{{ if .TargetY }}, the dashed line being the SLA target{{ end }}.</p>
+	{{- end }}{{ end }}
+	<table>
+		<tr><th></th><th>Average</th><th>5th percentile</th><th>Median</th><th>95th percentile</th><th>SLA attainment</th></tr>
+		{{- define "row" }}<td>{{ mbps .Average }}</td><td>{{ mbps .P5 }}</td><td>{{ mbps .P50 }}</td><td>{{ mbps .P95 }}</td><td>{{ with .SLA }}{{ pct .Attainment }} of measurements at least {{ mbps .TargetMbps }}{{ else }}-{{ end }}</td>{{ end }}
+		<tr><td>Download</td>{{ template "row" .Download }}</tr>
+		{{- with .Upload }}
+		<tr><td>Upload</td>{{ template "row" . }}</tr>
+		{{- end }}
+	</table>
+	<h2>Worst hours</h2>
+	<table>
+		<tr><th>Hour</th><th>Average download</th><th>Measurements</th></tr>
+		{{- range .WorstHours }}
+		<tr><td>{{ printf "%02d:00" .Hour }}</td><td>{{ mbps .Average }}</td><td>{{ .Measurements }}</td></tr>
+		{{- end }}
+	</table>
+	<h2>Measurements</h2>
+	<table>
+		<tr><th>Time</th><th>Download</th><th>Upload</th><th>Server</th><th>Server IP</th><th>Public IP</th><th>Method</th><th>Trigger</th></tr>
+		{{- range .Entries }}
+		<tr><td>{{ timestamp .Time }}</td><td>{{ speed .DownloadSpeed }}</td><td>{{ speed .UploadSpeed }}</td><td>{{ orDash .Server }}</td><td>{{ orDash .ServerIP }}</td><td>{{ orDash .ClientIP }}</td><td>{{ orDash .Method }}</td><td>{{ orDash .Trigger }}</td></tr>
+		{{- end }}
+	</table>
+</body>
+</html>
+`))
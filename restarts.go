@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// restarts records the exporter starts to a state file, counting the unclean
+// shutdowns: the starts after one that did not stop cleanly, i.e. crashed,
+// was killed or lost power.
+type restarts struct {
+	mutex sync.Mutex
+	path  string
+	state restartsState
+}
+
+type restartsState struct {
+	Starts  int  `json:"starts"`
+	Unclean int  `json:"unclean_shutdowns"`
+	Running bool `json:"running"`
+}
+
+// startRestarts records a start to path, which is marked as running until
+// exit is called.
+func startRestarts(path string) (*restarts, error) {
+	r := &restarts{path: path}
+	bts, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(bts) > 0 {
+		if err := json.Unmarshal(bts, &r.state); err != nil {
+			return nil, err
+		}
+	}
+	if r.state.Running {
+		r.state.Unclean++
+		log.Warn().Msg("the previous run did not shut down cleanly")
+	}
+	r.state.Starts++
+	r.state.Running = true
+	return r, r.write()
+}
+
+func (r *restarts) write() error {
+	bts, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, bts, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// handleShutdown calls exit on SIGINT and SIGTERM.
+func handleShutdown(exit func(code int)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Info().Msgf("got %s, shutting down", sig)
+		exit(0)
+	}()
+}
+
+// exit marks the shutdown as clean, if r is not nil, and exits.
+func (r *restarts) exit(code int) {
+	if r != nil {
+		r.mutex.Lock()
+		r.state.Running = false
+		if err := r.write(); err != nil {
+			log.Error().Err(err).Msg("could not record shutdown")
+		}
+		r.mutex.Unlock()
+	}
+	os.Exit(code)
+}
+
+// collectors returns the start time, restarts and unclean shutdowns metrics,
+// the counters only if r is not nil.
+func (r *restarts) collectors(start time.Time) []prometheus.Collector {
+	result := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "start_time_seconds",
+			Help:      "Start time of the exporter since unix epoch in seconds",
+		}, func() float64 { return float64(start.Unix()) }),
+	}
+	if r == nil {
+		return result
+	}
+	r.mutex.Lock()
+	state := r.state
+	r.mutex.Unlock()
+	return append(result,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "restarts_total",
+			Help:      "Number of times the exporter was restarted, as recorded in --restarts.file",
+		}, func() float64 { return float64(state.Starts - 1) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "fastcom",
+			Subsystem: "exporter",
+			Name:      "unclean_shutdowns_total",
+			Help:      "Number of times the exporter crashed, was killed or lost power before restarting, as recorded in --restarts.file",
+		}, func() float64 { return float64(state.Unclean) }),
+	)
+}
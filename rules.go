@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/internal/config"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/prometheus/common/model"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultAlertFor = time.Hour
+
+// degradingTrend is the daily fraction of the average download speed lost
+// that is considered a degradation.
+const degradingTrend = 0.02
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         model.Duration    `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// newRules returns recording and alerting rules for the metrics exported with
+// the given configuration, scoped by the static labels.
+func newRules(opts collector.Options, cfg *config.Config) ruleGroups {
+	names := make([]string, 0, len(cfg.Labels))
+	for name := range cfg.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", name, cfg.Labels[name]))
+	}
+	selector := ""
+	if len(matchers) > 0 {
+		selector = "{" + strings.Join(matchers, ",") + "}"
+	}
+
+	alertFor := cfg.Thresholds.For
+	if alertFor == 0 {
+		alertFor = defaultAlertFor
+	}
+
+	recording := []rule{
+		{
+			Record: "fastcom:download_bytes_second:avg_1d",
+			Expr:   "avg_over_time(fastcom_download_bytes_second" + selector + "[1d])",
+		},
+	}
+	if opts.Upload != nil {
+		recording = append(recording, rule{
+			Record: "fastcom:upload_bytes_second:avg_1d",
+			Expr:   "avg_over_time(fastcom_upload_bytes_second" + selector + "[1d])",
+		})
+	}
+
+	alerting := []rule{
+		{
+			Alert: "FastcomMeasurementFailing",
+			Expr:  "fastcom_up" + selector + " == 0",
+			For:   model.Duration(alertFor),
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary":     "fast.com measurements are failing on {{ $labels.instance }}",
+				"description": "Check fastcom_last_error_info, the exporter logs or /api/v1/status for the last error.",
+			},
+		},
+	}
+	if opts.History != nil {
+		alerting = append(alerting, rule{
+			Alert: "FastcomDownloadSpeedDegrading",
+			Expr: fmt.Sprintf(
+				"fastcom_download_trend_bytes_per_day%s < -%v * avg_over_time(fastcom_download_bytes_second%s[7d])",
+				selector, degradingTrend, selector,
+			),
+			For: model.Duration(alertFor),
+			Labels: map[string]string{
+				"severity": "info",
+			},
+			Annotations: map[string]string{
+				"summary":     "Download speed on {{ $labels.instance }} is getting slower by {{ $value | humanize }}B/s per day",
+				"description": "The download speed trend over the last 7 days is steadily negative.",
+			},
+		})
+	}
+	if mbps := cfg.Thresholds.DownloadMbps; mbps > 0 {
+		alerting = append(alerting, thresholdRule("Download", "fastcom_download_bytes_second", selector, mbps, alertFor))
+	}
+	if mbps := cfg.Thresholds.UploadMbps; mbps > 0 && opts.Upload != nil {
+		alerting = append(alerting, thresholdRule("Upload", "fastcom_upload_bytes_second", selector, mbps, alertFor))
+	}
+
+	return ruleGroups{
+		Groups: []ruleGroup{
+			{Name: "fastcom.rules", Rules: recording},
+			{Name: "fastcom.alerts", Rules: alerting},
+		},
+	}
+}
+
+// thresholdRule alerts when the given metric is below the threshold, ignoring
+// failed measurements, which are reported as zero.
+func thresholdRule(direction, metric, selector string, mbps float64, alertFor time.Duration) rule {
+	return rule{
+		Alert: "Fastcom" + direction + "SpeedLow",
+		Expr:  fmt.Sprintf("%s%s < %v and fastcom_up%s == 1", metric, selector, mbpsToBytes(mbps), selector),
+		For:   model.Duration(alertFor),
+		Labels: map[string]string{
+			"severity": "warning",
+		},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf(
+				"%s speed on {{ $labels.instance }} is below %vMbps: {{ $value | humanize }}B/s",
+				direction, mbps,
+			),
+		},
+	}
+}
+
+func rulesHandler(rules ruleGroups) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bts, err := yaml.Marshal(rules)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to render rules")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(bts)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/caarlos0/fastcom-exporter/internal/selfupdate"
+	"github.com/rs/zerolog/log"
+)
+
+func runSelfUpdate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	release, err := selfupdate.Latest(ctx, client)
+	if err != nil {
+		return err
+	}
+	newer, err := release.Newer(version)
+	if err != nil && !*updateForce {
+		return fmt.Errorf("%w, use --force to update anyway", err)
+	}
+	if !newer && !*updateForce {
+		log.Info().Msgf("fastcom-exporter %s is up to date, the latest release is %s", version, release.Version())
+		return nil
+	}
+	if *updateCheck {
+		log.Info().Msgf("fastcom-exporter %s is available, running %s", release.Version(), version)
+		return nil
+	}
+
+	opts := selfupdate.Options{
+		OS:            runtime.GOOS,
+		Arch:          *updateArch,
+		Current:       version,
+		Force:         *updateForce,
+		SkipSignature: *updateSkipSig,
+		Client:        client,
+	}
+	if !*updateSkipSig {
+		key, err := updatePublicKey()
+		if err != nil {
+			return err
+		}
+		opts.PublicKey = key
+	}
+	if err := selfupdate.Update(ctx, release, opts); err != nil {
+		return err
+	}
+	log.Info().Msgf("updated fastcom-exporter from %s to %s, restart it to use the new version", version, release.Version())
+	return nil
+}
+
+// updatePublicKey returns the PEM encoded key the release signature is
+// verified with: --public-key, or the release key the binary was built with.
+func updatePublicKey() ([]byte, error) {
+	if *updateKey != "" {
+		key, err := os.ReadFile(*updateKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not read public key: %w", err)
+		}
+		return key, nil
+	}
+	if releaseKey == "" {
+		return nil, fmt.Errorf("%w, this build has no release key: pass --public-key, or --insecure-skip-signature to verify the checksums only", selfupdate.ErrNoPublicKey)
+	}
+	der, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid release key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
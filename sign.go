@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/rs/zerolog/log"
+)
+
+// loadSigningKey reads a PEM encoded PKCS #8 Ed25519 private key.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key, got %T", path, key)
+	}
+	return ed, nil
+}
+
+// loadPublicKey reads a PEM encoded PKIX Ed25519 public key.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key, got %T", path, key)
+	}
+	return ed, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(bts)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block.Bytes, nil
+}
+
+// runVerify checks the signature of every entry in the history file, failing
+// if any of them does not match.
+func runVerify() error {
+	if *historyFile == "" {
+		return errors.New("verify requires --history.file")
+	}
+	key, err := loadPublicKey(*verifyKey)
+	if err != nil {
+		return err
+	}
+	entries, err := history.Load(*historyFile)
+	if err != nil {
+		return err
+	}
+	var valid, unsigned, invalid int
+	for _, entry := range entries {
+		err := entry.Verify(key)
+		switch {
+		case err == nil:
+			valid++
+		case errors.Is(err, history.ErrUnsigned):
+			unsigned++
+		default:
+			invalid++
+			log.Error().Err(err).Str("id", entry.ID).Msg("invalid entry")
+		}
+	}
+	fmt.Printf("%d valid, %d unsigned, %d invalid entries\n", valid, unsigned, invalid)
+	if invalid > 0 {
+		return fmt.Errorf("%d entries failed verification", invalid)
+	}
+	return nil
+}
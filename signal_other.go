@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package main
+
+// handlePauseSignals does nothing, there are no user signals on Windows.
+func handlePauseSignals(profiles []profile) {}
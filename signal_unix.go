@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// handlePauseSignals pauses the measurements of every profile on SIGUSR1 and
+// resumes them on SIGUSR2.
+func handlePauseSignals(profiles []profile) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			log.Debug().Msgf("got %s", sig)
+			for _, p := range profiles {
+				if sig == syscall.SIGUSR1 {
+					p.collector.Pause(0)
+				} else {
+					p.collector.Resume()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// tlsMode describes how the TLS certificates of fast.com are verified, as
+// exported in the build info metric.
+func tlsMode() string {
+	switch {
+	case *tlsInsecure:
+		return "insecure"
+	case len(*tlsCAFiles) > 0:
+		return "custom-ca"
+	default:
+		return "verified"
+	}
+}
+
+// configureTLS trusts the extra CAs and skips verification in transport,
+// according to the flags.
+func configureTLS(transport *http.Transport) error {
+	if tlsMode() == "verified" {
+		return nil
+	}
+	cfg := &tls.Config{
+		InsecureSkipVerify: *tlsInsecure, // nolint: gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	if len(*tlsCAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range *tlsCAFiles {
+			bts, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("could not read ca file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(bts) {
+				return fmt.Errorf("no certificates found in ca file %s", path)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	transport.TLSClientConfig = cfg
+	return nil
+}
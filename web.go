@@ -0,0 +1,542 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	// registers the profiling endpoints, only served with --web.pprof
+	_ "net/http/pprof" // nolint: gosec
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/caarlos0/fastcom-exporter/internal/config"
+	"github.com/caarlos0/fastcom-exporter/pkg/collector"
+	"github.com/caarlos0/fastcom-exporter/pkg/history"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"github.com/rs/zerolog/log"
+)
+
+const provider = "fast.com"
+
+// flag names containing any of these are redacted from the status output.
+// nolint: gochecknoglobals
+var secretFlagHints = []string{"password", "secret", "token", "key", "salt"}
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"built_by"`
+	GoVersion string `json:"goversion"`
+	Platform  string `json:"platform"`
+}
+
+type status struct {
+	Build           buildInfo         `json:"build"`
+	Provider        string            `json:"provider"`
+	Config          map[string]string `json:"config"`
+	LastMeasurement *time.Time        `json:"last_measurement,omitempty"`
+	LastID          string            `json:"last_measurement_id,omitempty"`
+	LastError       string            `json:"last_error,omitempty"`
+	NextRun         *time.Time        `json:"next_run,omitempty"`
+	Paused          bool              `json:"paused"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version,
+		Revision:  commit,
+		Date:      date,
+		BuiltBy:   builtBy,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// currentConfig returns the value of every flag, redacting secrets.
+func currentConfig() map[string]string {
+	config := map[string]string{}
+	for _, flag := range kingpin.CommandLine.Model().Flags {
+		switch flag.Name {
+		case "help", "help-long", "help-man", "version":
+			continue
+		}
+		if flag.Hidden {
+			continue
+		}
+		config[flag.Name] = redact(flag.Name, flag.String())
+	}
+	return config
+}
+
+func redact(name, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, hint := range secretFlagHints {
+		if strings.Contains(strings.ToLower(name), hint) {
+			return "<secret>"
+		}
+	}
+	return value
+}
+
+func currentStatus(c *collector.FastCollector) status {
+	cs := c.Status()
+	s := status{
+		Build:    currentBuildInfo(),
+		Provider: provider,
+		Config:   currentConfig(),
+	}
+	if !cs.LastRun.IsZero() {
+		s.LastMeasurement = &cs.LastRun
+		s.LastID = cs.LastID
+	}
+	if cs.LastError != nil {
+		s.LastError = cs.LastError.Error()
+	}
+	if !cs.NextRun.IsZero() {
+		s.NextRun = &cs.NextRun
+	}
+	s.Paused = cs.Paused
+	return s
+}
+
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout, in seconds.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// profile is a collector measuring with its own options and schedule.
+type profile struct {
+	name      string
+	collector *collector.FastCollector
+}
+
+// metricsHandler serves the given registry along with the collectors of
+// every profile, labeled by profile if there is more than one, capping
+// measurements on scrape to the scrape timeout sent by Prometheus.
+// The preset=burst query parameter measures in burst mode, if a measurement
+// is needed.
+// OpenMetrics is served when requested if openMetrics is set.
+func metricsHandler(registry *prometheus.Registry, profiles []profile, labels map[string]string, openMetrics bool) http.Handler {
+	return promhttp.InstrumentMetricHandler(registry, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var scrape collector.Scrape
+		if timeout, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64); err == nil && timeout > 0 {
+			scrape.Timeout = time.Duration(timeout * float64(time.Second))
+		}
+		switch preset := r.URL.Query().Get("preset"); preset {
+		case "":
+		case "burst":
+			scrape.Burst = true
+		default:
+			http.Error(w, "invalid preset "+strconv.Quote(preset), http.StatusBadRequest)
+			return
+		}
+		measurements := prometheus.NewRegistry()
+		for _, p := range profiles {
+			profileLabels := labels
+			if len(profiles) > 1 {
+				profileLabels = prometheus.Labels{"profile": p.name}
+				for k, v := range labels {
+					profileLabels[k] = v
+				}
+			}
+			prometheus.WrapRegistererWith(profileLabels, measurements).MustRegister(p.collector.ForScrape(scrape))
+		}
+		gatherers := prometheus.Gatherers{registry, measurements}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{EnableOpenMetrics: openMetrics}).ServeHTTP(w, r)
+	}))
+}
+
+func statusHandler(c *collector.FastCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(currentStatus(c)); err != nil {
+			log.Error().Err(err).Msg("failed to encode status")
+		}
+	}
+}
+
+// pauseHandler pauses the measurements of every profile, for the duration
+// in the for query parameter if set, or until resumed.
+func pauseHandler(profiles []profile) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var d time.Duration
+		if v := r.URL.Query().Get("for"); v != "" {
+			var err error
+			d, err = time.ParseDuration(v)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid duration "+strconv.Quote(v), http.StatusBadRequest)
+				return
+			}
+		}
+		for _, p := range profiles {
+			p.collector.Pause(d)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// resumeHandler resumes the measurements of every profile.
+func resumeHandler(profiles []profile) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		for _, p := range profiles {
+			p.collector.Resume()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func historyHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			http.Error(w, "history is disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(store.Entries()); err != nil {
+			log.Error().Err(err).Msg("failed to encode history")
+		}
+	}
+}
+
+// reportDates are the layouts accepted by the from and to query parameters
+// of the report, dates being in the local time zone.
+// nolint: gochecknoglobals
+var reportDates = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+// parseReportTime parses a from or to query parameter of the report, a date
+// meaning its end if end is set.
+func parseReportTime(v string, end bool) (time.Time, error) {
+	for _, layout := range reportDates {
+		t, err := time.ParseInLocation(layout, v, time.Local)
+		if err != nil {
+			continue
+		}
+		if end && layout == "2006-01-02" {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date or RFC 3339 time", v)
+}
+
+// reportHandler serves a report of the history between the from and to query
+// parameters, the last week by default, in the format query parameter, html
+// by default.
+// The whole history is read from path if set, as the store only keeps the
+// latest entries.
+func reportHandler(store *history.Store, path string, thresholds config.Thresholds) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			http.Error(w, "history is disabled", http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query()
+		to := time.Now()
+		from := to.Add(-reportPeriods["weekly"])
+		var err error
+		if v := query.Get("to"); v != "" {
+			if to, err = parseReportTime(v, true); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			from = to.Add(-reportPeriods["weekly"])
+		}
+		if v := query.Get("from"); v != "" {
+			if from, err = parseReportTime(v, false); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+		format := query.Get("format")
+		switch format {
+		case "":
+			format = "html"
+			fallthrough
+		case "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		case "markdown":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		default:
+			http.Error(w, "invalid format "+strconv.Quote(format), http.StatusBadRequest)
+			return
+		}
+
+		entries := store.Entries()
+		if path != "" {
+			if entries, err = history.Load(path); err != nil {
+				log.Error().Err(err).Msg("failed to load history")
+				http.Error(w, "could not load history", http.StatusInternalServerError)
+				return
+			}
+		}
+		report, err := newRangeReport(entries, from, to, thresholds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := writeReport(w, report, format); err != nil {
+			log.Error().Err(err).Msg("failed to render report")
+		}
+	}
+}
+
+func eventsHandler(events *history.Events) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if events == nil {
+			http.Error(w, "events are disabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(events.List()); err != nil {
+			log.Error().Err(err).Msg("failed to encode events")
+		}
+	}
+}
+
+// sdTarget is a target group in the Prometheus HTTP service discovery
+// format.
+type sdTarget struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// sdHandler serves this exporter as a Prometheus HTTP service discovery
+// target, along with the static labels, at the external URL if set.
+// The target is the address the request was sent to, or the external URL
+// host, unless set in the target query parameter.
+func sdHandler(labels map[string]string, external *url.URL) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			target = r.Host
+			if external.Host != "" {
+				target = external.Host
+			}
+		}
+		sdLabels := map[string]string{
+			model.MetricsPathLabel: strings.TrimSuffix(external.Path, "/") + "/metrics",
+		}
+		if external.Scheme != "" {
+			sdLabels[model.SchemeLabel] = external.Scheme
+		}
+		for k, v := range labels {
+			sdLabels[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode([]sdTarget{{
+			Targets: []string{target},
+			Labels:  sdLabels,
+		}}); err != nil {
+			log.Error().Err(err).Msg("failed to encode service discovery")
+		}
+	}
+}
+
+// requireToken wraps handler, requiring one of the bearer tokens if there are
+// any.
+func requireToken(tokens []string, handler http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") {
+			given := []byte(strings.TrimPrefix(auth, "Bearer "))
+			for _, token := range tokens {
+				if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fastcom-exporter"`)
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+	})
+}
+
+// pprofPrefix is the path the profiling endpoints are registered at.
+const pprofPrefix = "/debug/pprof/"
+
+// withPprof wraps handler, serving the profiling endpoints only if enabled,
+// and only with a valid token if any is configured, as profiles reveal
+// command line flags and memory contents.
+func withPprof(enabled bool, tokens []string, handler http.Handler) http.Handler {
+	guarded := requireToken(tokens, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, pprofPrefix) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if !enabled {
+			http.NotFound(w, r)
+			return
+		}
+		guarded.ServeHTTP(w, r)
+	})
+}
+
+// withCORS wraps handler, allowing browsers on the given origins to call the
+// /api/ endpoints, answering their preflight requests.
+func withCORS(origins []string, handler http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return handler
+	}
+	allowed := func(origin string) bool {
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") || !allowed(origin) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "3600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// withRoutePrefix wraps handler, serving it under the prefix only.
+func withRoutePrefix(prefix string, handler http.Handler) http.Handler {
+	stripped := http.StripPrefix(prefix, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// apiPaths wraps handler, only serving the /api/ paths if api is set, or only
+// the other ones otherwise.
+func apiPaths(handler http.Handler, api bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") != api {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// exitWhenIdle wraps handler, exiting once no requests were served for the
+// given duration, so a socket activated exporter frees its memory between
+// scrapes.
+func exitWhenIdle(handler http.Handler, idle time.Duration, exit func(code int)) http.Handler {
+	var inFlight int64
+	last := time.Now().UnixNano()
+	go func() {
+		ticker := time.NewTicker(idle / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			since := time.Since(time.Unix(0, atomic.LoadInt64(&last)))
+			if atomic.LoadInt64(&inFlight) == 0 && since >= idle {
+				log.Info().Msgf("exiting after %s without requests", idle)
+				exit(0)
+			}
+		}
+	}()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		defer func() { atomic.StoreInt64(&last, time.Now().UnixNano()) }()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// nolint: gochecknoglobals
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"sortedKeys": func(m map[string]string) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}).Parse(`<html>
+<head><title>Fast.com Exporter</title></head>
+<body>
+	<h1>Fast.com Exporter</h1>
+	<p><a href="{{ .Prefix }}/metrics">Metrics</a> | <a href="{{ .Prefix }}/api/v1/status">Status</a> | <a href="{{ .Prefix }}/api/v1/results/latest">Latest result</a> | <a href="{{ .Prefix }}/api/v1/history">History</a> | <a href="{{ .Prefix }}/api/v1/events">Events</a> | <a href="{{ .Prefix }}/report">Report</a> | <a href="{{ .Prefix }}/sd">Service discovery</a> | <a href="{{ .Prefix }}/grafana/dashboard.json">Grafana dashboard</a> | <a href="{{ .Prefix }}/rules.yaml">Prometheus rules</a></p>
+	<h2>Build</h2>
+	<table>
+		<tr><td>Version</td><td>{{ .Build.Version }}</td></tr>
+		<tr><td>Revision</td><td>{{ .Build.Revision }}</td></tr>
+		<tr><td>Build date</td><td>{{ .Build.Date }}</td></tr>
+		<tr><td>Built by</td><td>{{ .Build.BuiltBy }}</td></tr>
+		<tr><td>Go version</td><td>{{ .Build.GoVersion }}</td></tr>
+		<tr><td>Platform</td><td>{{ .Build.Platform }}</td></tr>
+	</table>
+	<h2>Status</h2>
+	<table>
+		<tr><td>Provider</td><td>{{ .Provider }}</td></tr>
+		<tr><td>Last measurement</td><td>{{ with .LastMeasurement }}{{ . }} ({{ $.LastID }}){{ else }}never{{ end }}</td></tr>
+		<tr><td>Last error</td><td>{{ with .LastError }}{{ . }}{{ else }}none{{ end }}</td></tr>
+		<tr><td>Next run</td><td>{{ with .NextRun }}{{ . }}{{ else }}on next scrape{{ end }}</td></tr>
+	</table>
+	<h2>Configuration</h2>
+	<table>
+		{{- $config := .Config }}
+		{{- range sortedKeys $config }}
+		<tr><td>{{ . }}</td><td>{{ index $config . }}</td></tr>
+		{{- end }}
+	</table>
+</body>
+</html>
+`))
+
+// indexHandler serves the landing page, whose links start with prefix.
+func indexHandler(c *collector.FastCollector, prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := struct {
+			status
+			Prefix string
+		}{currentStatus(c), prefix}
+		if err := indexTemplate.Execute(w, page); err != nil {
+			log.Error().Err(err).Msg("failed to render index")
+		}
+	}
+}
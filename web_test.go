@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitHandlers(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+	for _, tt := range []struct {
+		name     string
+		prefix   string
+		split    bool
+		api      bool
+		path     string
+		wantCode int
+	}{
+		{name: "web", path: "/metrics", wantCode: http.StatusOK},
+		{name: "web api", path: "/api/v1/measure", wantCode: http.StatusOK},
+		{name: "split web", split: true, path: "/metrics", wantCode: http.StatusOK},
+		{name: "split web without api", split: true, path: "/api/v1/measure", wantCode: http.StatusNotFound},
+		{name: "split api", split: true, api: true, path: "/api/v1/measure", wantCode: http.StatusOK},
+		{name: "split api without web", split: true, api: true, path: "/metrics", wantCode: http.StatusNotFound},
+		{name: "prefixed web", prefix: "/fastcom", path: "/fastcom/metrics", wantCode: http.StatusOK},
+		{name: "prefixed web outside prefix", prefix: "/fastcom", path: "/metrics", wantCode: http.StatusNotFound},
+		{name: "prefixed split web", prefix: "/fastcom", split: true, path: "/fastcom/metrics", wantCode: http.StatusOK},
+		{name: "prefixed split web without api", prefix: "/fastcom", split: true, path: "/fastcom/api/v1/measure", wantCode: http.StatusNotFound},
+		{name: "prefixed split api", prefix: "/fastcom", split: true, api: true, path: "/fastcom/api/v1/measure", wantCode: http.StatusOK},
+		{name: "prefixed split api without web", prefix: "/fastcom", split: true, api: true, path: "/fastcom/metrics", wantCode: http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			web, api := splitHandlers(ok, tt.prefix, tt.split)
+			if (api != nil) != tt.split {
+				t.Fatalf("expected an api handler only when split, got %v", api)
+			}
+			handler := web
+			if tt.api {
+				handler = api
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d for %s, got %d", tt.wantCode, tt.path, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for name, want := range map[string]string{
+		"public-ip.salt":      "<secret>",
+		"history.signing-key": "<secret>",
+		"bind":                "value",
+	} {
+		if got := redact(name, "value"); got != want {
+			t.Errorf("expected %s to be %q, got %q", name, want, got)
+		}
+	}
+	if got := redact("public-ip.salt", ""); got != "" {
+		t.Errorf("expected empty values to stay empty, got %q", got)
+	}
+}
+
+func TestCurrentConfigRedactsSalt(t *testing.T) {
+	old := *publicIPSalt
+	*publicIPSalt = "pepper"
+	defer func() { *publicIPSalt = old }()
+	if got := currentConfig()["public-ip.salt"]; got != "<secret>" {
+		t.Fatalf("expected the salt to be redacted, got %q", got)
+	}
+}